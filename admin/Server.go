@@ -1,20 +1,20 @@
 package admin
 
 import (
-  "os"
-  "log"
-  "fmt"
-  "net/http"
-
-  "github.com/gorilla/handlers"
   "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/server"
   . "github.com/confyrm/gorest/admin/routes"
 )
 
-func Server(config *config.Config)  {
-
-  router := RouteSet.New(config)
-  loggedRouter := handlers.CombinedLoggingHandler(os.Stdout, router)
-  log.Printf("Admin: Listening on %d...", config.GetInt("ADMIN_PORT"))
-  log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", config.GetInt("ADMIN_PORT")), loggedRouter))
+// New returns the admin server.Server.  Main starts and stops it alongside
+// the app server.
+func New(c *config.Config) *server.Server {
+  s := server.Server {
+    Config: c,
+    Name: "Admin",
+    Port: c.App().AdminPort,
+    PortOf: func(app *config.AppConfig) int { return app.AdminPort },
+    RouteSet: RouteSet,
+  }
+  return &s
 }
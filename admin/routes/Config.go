@@ -0,0 +1,20 @@
+package routes
+
+import (
+    "net/http"
+    "encoding/json"
+
+    "github.com/confyrm/gorest/config"
+    . "github.com/confyrm/gorest/errors"
+)
+
+// Config shows the value of every config key as JSON, and the layer it came
+// from: a flag, the env, the profile's file, the base file, or a default.
+// Secrets are redacted.
+func Config(config *config.Config, rw http.ResponseWriter, req *http.Request) error {
+    rw.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    if err := json.NewEncoder(rw).Encode(config.Sources()); err != nil {
+      return StatusError{http.StatusInternalServerError, err}
+    }
+    return nil
+}
@@ -1,16 +1,17 @@
 package routes
 
 import (
-    "os"
     "fmt"
     "net/http"
 
     "github.com/confyrm/gorest/config"
+    "github.com/confyrm/gorest/server"
 )
-// My first admin method.  This just causes the server to shud down in a
-// somewhat graceful fashion.
+// My first admin method.  This starts the same graceful shutdown as SIGTERM.
+// In-flight requests and long running commands are given SHUTDOWN_TIMEOUT to
+// finish before the process exits.
 func Exit(config *config.Config, rw http.ResponseWriter, req *http.Request) error {
     fmt.Fprintln(rw, "Exiting")
-    os.Exit(0)
+    server.Stop("admin /exit")
     return nil
 }
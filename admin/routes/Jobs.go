@@ -0,0 +1,24 @@
+package routes
+
+import (
+    "errors"
+    "net/http"
+    "encoding/json"
+
+    "github.com/confyrm/gorest/jobs"
+    "github.com/confyrm/gorest/config"
+    . "github.com/confyrm/gorest/errors"
+)
+
+// Jobs lists the queued, running and recently finished jobs as JSON.
+func Jobs(config *config.Config, rw http.ResponseWriter, req *http.Request) error {
+    if jobs.Default == nil {
+      return StatusError{http.StatusServiceUnavailable, errors.New("Job pool is not running")}
+    }
+
+    rw.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    if err := json.NewEncoder(rw).Encode(jobs.Default.Jobs()); err != nil {
+      return StatusError{http.StatusInternalServerError, err}
+    }
+    return nil
+}
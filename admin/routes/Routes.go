@@ -24,6 +24,18 @@ var RouteSet = router.Routes{
     "/exit",
     Exit,
   },
+  router.Route{
+    "Jobs",
+    "GET",
+    "/jobs",
+    Jobs,
+  },
+  router.Route{
+    "Config",
+    "GET",
+    "/config",
+    Config,
+  },
   router.Route{
     "Test",
     "POST",
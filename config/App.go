@@ -0,0 +1,150 @@
+package config
+
+import (
+  "fmt"
+  "time"
+)
+
+// AppConfig is the typed config of the app.  Each field is read from the key
+// in its config tag.  The tags are also where the defaults, the validation
+// rules and the --help text live.  See Decode for the tags.
+type AppConfig struct {
+  Name string `config:"APP_NAME" default:"devhub" help:"Name of the app server"`
+  Port int `config:"APP_PORT" default:"8080" validate:"port" help:"Port the app listens on"`
+  Root string `config:"APP_ROOT" default:"." help:"Root folder of the app"`
+  AdminPort int `config:"ADMIN_PORT" default:"8001" validate:"port" help:"Port the admin server listens on"`
+  ShutdownTimeout time.Duration `config:"SHUTDOWN_TIMEOUT" default:"30s" validate:"min=0s" help:"How long to wait for requests and jobs to finish on shutdown"`
+  RequestTimeout time.Duration `config:"REQUEST_TIMEOUT" default:"10s" validate:"min=1s" help:"Timeout for a request"`
+  SecretsKeyFile string `config:"SECRETS_KEY_FILE" help:"File with the base64 encoded AES-256 key for secret://aes/ references"`
+
+  Jobs JobsConfig
+  Store StoreConfig
+  Slack SlackConfig
+  Github GithubConfig
+}
+
+// JobsConfig sizes the pool that runs long slash commands.
+type JobsConfig struct {
+  Timeout time.Duration `config:"LONG_COMMAND_TIMEOUT" default:"5m" validate:"min=1s" help:"Timeout for a long running slash command"`
+  Workers int `config:"JOBS_WORKERS" default:"4" validate:"min=1" help:"Number of workers running slash commands"`
+  QueueSize int `config:"JOBS_QUEUE_SIZE" default:"32" validate:"min=0" help:"Number of slash commands that can wait for a worker"`
+  HistorySize int `config:"JOBS_HISTORY_SIZE" default:"50" validate:"min=0" help:"Number of finished jobs the admin server shows"`
+}
+
+// StoreConfig is where app state is kept.
+type StoreConfig struct {
+  Driver string `config:"STORE_DRIVER" default:"bolt" validate:"oneof=bolt memory" help:"Store for app state.  bolt or memory"`
+  Path string `config:"STORE_PATH" help:"The bolt file.  Defaults to APP_ROOT/devhub.db"`
+}
+
+// SlackConfig is how the app talks to Slack.  Either SigningSecret, or
+// AllowLegacyToken and Token, are required.  The Slack app's ClientId,
+// ClientSecret and RedirectUrl are for Sign in with Slack, which account
+// linking needs.
+type SlackConfig struct {
+  SigningSecret string `config:"SLACK_SIGNING_SECRET" secret:"true"`
+  Token string `config:"SLACK_TOKEN" secret:"true"`
+  AllowLegacyToken bool `config:"SLACK_ALLOW_LEGACY_TOKEN" help:"Accept requests with the legacy verification token"`
+  SignatureWindow time.Duration `config:"SLACK_SIGNATURE_WINDOW" default:"5m" validate:"min=1s" help:"How old a signed Slack request can be"`
+  BotToken string `config:"SLACK_BOT_TOKEN" secret:"true"`
+  ApiUrl string `config:"SLACK_API_URL" validate:"url" help:"Slack Web API url, for testing"`
+  UseBlocks bool `config:"SLACK_USE_BLOCKS" help:"Show issues with Block Kit"`
+  ThreadSync bool `config:"SLACK_THREAD_SYNC" help:"Post Slack thread replies as issue comments"`
+  GithubUsers map[string]string `config:"SLACK_GITHUB_USERS"`
+  ChannelRepos map[string]string `config:"SLACK_CHANNEL_REPOS"`
+  ClientId string `config:"SLACK_CLIENT_ID" help:"Slack app client id, for Sign in with Slack"`
+  ClientSecret string `config:"SLACK_CLIENT_SECRET" secret:"true"`
+  RedirectUrl string `config:"SLACK_OAUTH_REDIRECT_URL" validate:"url" help:"Sign in with Slack redirect url"`
+}
+
+// GithubConfig is how the app talks to GitHub.  ClientId turns on account
+// linking, which also needs ClientSecret, RedirectUrl and TokenKey, and
+// Sign in with Slack.
+type GithubConfig struct {
+  Token string `config:"GITHUB_TOKEN" secret:"true" validate:"required"`
+  ApiUrl string `config:"GITHUB_API_URL" validate:"url" help:"GitHub API url, for GitHub Enterprise or testing"`
+  DefaultOwner string `config:"GITHUB_DEFAULT_OWNER" default:"confyrm" help:"Owner used when a command does not give one"`
+  DefaultRepo string `config:"GITHUB_DEFAULT_REPO" default:"devhub" help:"Repo used when a command does not give one"`
+  DefaultChannel string `config:"GITHUB_DEFAULT_CHANNEL" help:"Channel for webhook events from unmapped repos"`
+  Channels map[string]string `config:"GITHUB_CHANNELS"`
+  MergeUsers []string `config:"GITHUB_MERGE_USERS" help:"Slack users allowed to merge pull requests"`
+  WebhookSecret string `config:"GITHUB_WEBHOOK_SECRET" secret:"true"`
+  ClientId string `config:"GITHUB_CLIENT_ID" help:"GitHub OAuth app client id"`
+  ClientSecret string `config:"GITHUB_CLIENT_SECRET" secret:"true"`
+  RedirectUrl string `config:"GITHUB_OAUTH_REDIRECT_URL" validate:"url" help:"GitHub OAuth redirect url"`
+  TokenKey string `config:"GITHUB_TOKEN_KEY" secret:"true" validate:"base64=32"`
+  TokenFallback bool `config:"GITHUB_TOKEN_FALLBACK" help:"Use GITHUB_TOKEN for users who have not linked an account"`
+}
+
+// LoadApp decodes and validates the AppConfig.  The error is an Errors with
+// every problem found, not just the first.
+func LoadApp(c *Config) (*AppConfig, error) {
+  app := &AppConfig{}
+  c.mu.RLock()
+  errs := append(Errors{}, c.secretErrs...)
+  c.mu.RUnlock()
+  if err := c.Decode(app); err != nil {
+    errs = append(errs, err.(Errors)...)
+  }
+  errs = append(errs, app.check()...)
+  if len(errs) > 0 {
+    return app, errs
+  }
+  return app, nil
+}
+
+// ValidateApp is LoadApp as a validator, so that a reloaded config file has
+// to pass the same checks as the one the app started with.
+func ValidateApp(c *Config) error {
+  _, err := LoadApp(c)
+  return err
+}
+
+// check is for the rules that span more than one field.
+func (app *AppConfig) check() Errors {
+  var errs Errors
+  if app.Slack.SigningSecret == "" {
+    if !app.Slack.AllowLegacyToken {
+      errs = append(errs, fmt.Errorf("SLACK_SIGNING_SECRET: Is required, unless SLACK_ALLOW_LEGACY_TOKEN is set"))
+    } else if app.Slack.Token == "" {
+      errs = append(errs, fmt.Errorf("SLACK_TOKEN: Is required by SLACK_ALLOW_LEGACY_TOKEN, when there is no SLACK_SIGNING_SECRET"))
+    }
+  }
+  if app.Github.ClientId != "" {
+    for _, required := range []struct{ key, value string } {
+      {"GITHUB_CLIENT_SECRET", app.Github.ClientSecret},
+      {"GITHUB_OAUTH_REDIRECT_URL", app.Github.RedirectUrl},
+      {"GITHUB_TOKEN_KEY", app.Github.TokenKey},
+      {"SLACK_CLIENT_ID", app.Slack.ClientId},
+      {"SLACK_CLIENT_SECRET", app.Slack.ClientSecret},
+      {"SLACK_OAUTH_REDIRECT_URL", app.Slack.RedirectUrl},
+    } {
+      if required.value == "" {
+        errs = append(errs, fmt.Errorf("%s: Is required by GITHUB_CLIENT_ID", required.key))
+      }
+    }
+  }
+  return errs
+}
+
+// App returns the AppConfig, decoded from the current values.  Problems
+// are left out; they are reported by LoadApp when the app starts, or the
+// config file is reloaded.  The result is cached until the config changes,
+// and must not be modified.  Env vars that change after it is cached are
+// not seen.
+func (c *Config) App() *AppConfig {
+  c.mu.RLock()
+  app, gen := c.app, c.gen
+  c.mu.RUnlock()
+  if app != nil {
+    return app
+  }
+  app = &AppConfig{}
+  c.Decode(app)
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  if c.gen == gen {
+    c.app = app
+  }
+  return app
+}
@@ -6,9 +6,10 @@ import (
   "bytes"
   "time"
   "strings"
+  "sync"
   "path/filepath"
-  "github.com/fsnotify/fsnotify"
   "github.com/spf13/cast"
+  "github.com/spf13/pflag"
   "github.com/spf13/viper"
 )
 
@@ -17,8 +18,33 @@ import (
 //     c := config.New(...)
 //     c.Get("value")
 // Viper.Get is actually called.
+// The config file is watched, and reloaded when it changes.  Use Subscribe to
+// be told about new values.
 type Config struct {
   viper.Viper
+
+  mu sync.RWMutex
+  defaults map[string]interface{}
+  pflags map[string]*pflag.Flag
+  // The AppConfig, decoded when it is first asked for.  gen counts changes,
+  // so that a decode that raced with one is not cached.
+  app *AppConfig
+  gen int
+  // Resolved secrets, by reference, and the references that could not be.
+  secrets map[string]string
+  secretErrs Errors
+  // The profile, the files read for it, and the keys that have been Set.
+  profile string
+  layers []layer
+  overrides map[string]bool
+
+  // Held while reloading.  Guards the slices below.
+  subMu sync.Mutex
+  validators []subscription
+  subscribers []subscription
+  done chan struct{}
+  closeOnce sync.Once
+  watching sync.WaitGroup
 }
 
 // Creates a new Config/Viper instance, and reads the config file.
@@ -26,8 +52,21 @@ type Config struct {
 // searches for the files of the types it supports.  Which includes
 // json, yaml, toml, props, properties, etc..
 func New(fileName *string, defaults *map[string]interface{}) *Config {
+  return NewProfile(fileName, "", defaults)
+}
 
-  c := Config{*viper.New()}
+// NewProfile is New, with the profile's file, such as config.prod.yml, read
+// over the config file.  There does not have to be a file for the profile.
+func NewProfile(fileName *string, profile string, defaults *map[string]interface{}) *Config {
+
+  c := Config{
+    Viper: *viper.New(),
+    defaults: make(map[string]interface{}),
+    pflags: make(map[string]*pflag.Flag),
+    overrides: make(map[string]bool),
+    profile: profile,
+    done: make(chan struct{}),
+  }
 
   // Always look in the ENV for values, as well as the config file
   c.AutomaticEnv()
@@ -62,14 +101,28 @@ func New(fileName *string, defaults *map[string]interface{}) *Config {
   }
 
   log.Printf("Loading config file: [%s]  from [%s]", config, path)
-  err := c.ReadInConfig() // Find and read the config file
+  if profile != "" {
+    log.Printf("Using the %s profile", profile)
+  }
+  // Find and read the config file, and the profile's file
+  layers, err := readLayers(&c.Viper, profile)
   if err != nil { // Handle errors reading the config file
     log.Printf("Error reading config file: %s \n", err)
   } else {
-    c.WatchConfig()
-    c.OnConfigChange(func(e fsnotify.Event) {
-      log.Println("Config file changed:", e.Name)
-    })
+    c.layers = layers
+    if len(layers) > 1 {
+      log.Printf("Read %s over %s", layers[1].file, layers[0].file)
+    }
+  }
+  // Watch the file if it was found, so that a bad file can be fixed.
+  if c.ConfigFileUsed() != "" {
+    if err := c.watch(); err != nil {
+      log.Printf("Error watching config file: %s", err.Error())
+    }
+  }
+  // Secrets can be referenced from the env, as well as the file.
+  if err := c.resolveSecrets(); err != nil {
+    log.Printf("Error resolving secrets: %s", err.Error())
   }
 
   return &c
@@ -0,0 +1,223 @@
+package config
+
+import (
+  "fmt"
+  "time"
+  "strings"
+  "reflect"
+  "net/url"
+  "encoding/base64"
+  "github.com/spf13/cast"
+)
+
+// Errors is every problem found in a config, so that they can all be fixed
+// at once.
+type Errors []error
+
+func (errs Errors) Error() string {
+  lines := make([]string, len(errs))
+  for i, err := range errs {
+    lines[i] = "  " + err.Error()
+  }
+  problems := "problems"
+  if len(errs) == 1 {
+    problems = "problem"
+  }
+  return fmt.Sprintf("Found %d %s with the config:\n%s", len(errs), problems, strings.Join(lines, "\n"))
+}
+
+// Decode fills in the struct that v points to.  Each field is set from the
+// key in its config tag, or from its default tag if the key is not set.
+// Struct fields without a config tag are decoded in turn.  The validate tag
+// is a comma separated list of:
+//     required     The key must be set
+//     port         1 to 65535
+//     url          An absolute http or https url
+//     min=N        At least N, for ints and durations, such as min=1s
+//     oneof=A B    One of the space separated values
+//     base64=N     N bytes, base64 encoded
+// Only required looks at keys that are not set.  A field with a secret tag
+// of "true" gets no flag from StructFlags.  Every problem is returned, as
+// Errors.
+func (c *Config) Decode(v interface{}) error {
+  var errs Errors
+  eachField(reflect.ValueOf(v).Elem(), func(field reflect.StructField, value reflect.Value) {
+    key := field.Tag.Get("config")
+    raw := c.Get(key)
+    set := raw != nil && raw != ""
+    if !set {
+      raw = field.Tag.Get("default")
+    }
+    if raw != "" {
+      if err := setField(value, raw); err != nil {
+        errs = append(errs, fmt.Errorf("%s: %s", key, err.Error()))
+        return
+      }
+    }
+    for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+      if rule == "" || (rule != "required" && !set && isZero(value)) {
+        continue
+      }
+      if err := check(rule, value); err != nil {
+        errs = append(errs, fmt.Errorf("%s: %s", key, err.Error()))
+      }
+    }
+  })
+  if len(errs) > 0 {
+    return errs
+  }
+  return nil
+}
+
+// StructFlags declares a flag for each field of the struct v that has a
+// config tag.  Secrets and maps are left out.  The flag's help is the help
+// tag.
+func StructFlags(v interface{}) ConfigFlags {
+  var flags ConfigFlags
+  eachField(reflect.New(reflect.TypeOf(v)).Elem(), func(field reflect.StructField, value reflect.Value) {
+    if field.Tag.Get("secret") == "true" || value.Kind() == reflect.Map {
+      return
+    }
+    flag := ConfigFlag{Name: field.Tag.Get("config"), Type: flagType(value), Help: field.Tag.Get("help")}
+    if def := field.Tag.Get("default"); def != "" {
+      if err := setField(value, def); err != nil {
+        panic(fmt.Sprintf("Bad default for %s: %s", flag.Name, err.Error()))
+      }
+      flag.Default = value.Interface()
+    }
+    flags = append(flags, flag)
+  })
+  return flags
+}
+
+// eachField calls f with every field of the struct s that has a config tag.
+func eachField(s reflect.Value, f func(field reflect.StructField, value reflect.Value)) {
+  t := s.Type()
+  for i := 0; i < t.NumField(); i++ {
+    field := t.Field(i)
+    if field.Tag.Get("config") != "" {
+      f(field, s.Field(i))
+    } else if field.Type.Kind() == reflect.Struct {
+      eachField(s.Field(i), f)
+    }
+  }
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func flagType(value reflect.Value) string {
+  if value.Type() == durationType {
+    return DurationFlag
+  }
+  switch value.Kind() {
+  case reflect.String:
+    return StringFlag
+  case reflect.Int:
+    return IntFlag
+  case reflect.Bool:
+    return BoolFlag
+  case reflect.Float64:
+    return Float64Flag
+  case reflect.Slice:
+    return StringSliceFlag
+  }
+  return value.Type().String()
+}
+
+// setField converts raw to the type of value, and sets it.
+func setField(value reflect.Value, raw interface{}) error {
+  var (
+    v interface{}
+    err error
+  )
+  switch value.Interface().(type) {
+  case string:
+    v, err = cast.ToStringE(raw)
+  case int:
+    v, err = cast.ToIntE(raw)
+  case bool:
+    v, err = cast.ToBoolE(raw)
+  case float64:
+    v, err = cast.ToFloat64E(raw)
+  case time.Duration:
+    v, err = cast.ToDurationE(raw)
+  case []string:
+    // Env vars and flags come through as a single comma separated string.
+    if s, ok := raw.(string); ok {
+      var list []string
+      for _, item := range strings.Split(s, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+          list = append(list, item)
+        }
+      }
+      raw = list
+    }
+    v, err = cast.ToStringSliceE(raw)
+  case map[string]string:
+    v, err = cast.ToStringMapStringE(raw)
+  default:
+    return fmt.Errorf("Can not decode a %s", value.Type())
+  }
+  if err != nil {
+    return fmt.Errorf("%v is not a valid %s", raw, value.Type())
+  }
+  value.Set(reflect.ValueOf(v))
+  return nil
+}
+
+func isZero(value reflect.Value) bool {
+  switch value.Kind() {
+  case reflect.Slice, reflect.Map:
+    return value.Len() == 0
+  }
+  return value.Interface() == reflect.Zero(value.Type()).Interface()
+}
+
+// check applies one validate rule to value.
+func check(rule string, value reflect.Value) error {
+  name, arg := rule, ""
+  if i := strings.Index(rule, "="); i >= 0 {
+    name, arg = rule[:i], rule[i+1:]
+  }
+  switch name {
+  case "required":
+    if isZero(value) {
+      return fmt.Errorf("Is required")
+    }
+  case "port":
+    if port := value.Int(); port < 1 || port > 65535 {
+      return fmt.Errorf("%d is not a port.  Use 1 to 65535", port)
+    }
+  case "url":
+    u, err := url.Parse(value.String())
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+      return fmt.Errorf("%q is not an http or https url", value.String())
+    }
+  case "min":
+    limit := reflect.New(value.Type()).Elem()
+    if err := setField(limit, arg); err != nil {
+      return fmt.Errorf("Bad rule %q", rule)
+    }
+    if value.Int() < limit.Int() {
+      return fmt.Errorf("%v is less than %s", value.Interface(), arg)
+    }
+  case "oneof":
+    for _, allowed := range strings.Fields(arg) {
+      if value.String() == allowed {
+        return nil
+      }
+    }
+    return fmt.Errorf("%q is not one of %s", value.String(), strings.Join(strings.Fields(arg), ", "))
+  case "base64":
+    raw, err := base64.StdEncoding.DecodeString(value.String())
+    if err != nil {
+      return fmt.Errorf("Is not base64 encoded")
+    }
+    if size := cast.ToInt(arg); len(raw) != size {
+      return fmt.Errorf("Is %d bytes.  It must be %d", len(raw), size)
+    }
+  default:
+    return fmt.Errorf("Unknown rule %q", rule)
+  }
+  return nil
+}
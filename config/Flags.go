@@ -1,24 +1,130 @@
 package config
 
 import (
-  "log"
-  //"github.com/spf13/pflag"
+  "os"
+  "fmt"
+  "strings"
+  "github.com/spf13/cast"
+  "github.com/spf13/pflag"
 )
 
+// Flag types that a ConfigFlag can have.
+const (
+  StringFlag = "string"
+  IntFlag = "int"
+  BoolFlag = "bool"
+  Float64Flag = "float64"
+  DurationFlag = "duration"
+  StringSliceFlag = "stringSlice"
+)
+
+// ConfigFlag declares a config key that can also be set on the command
+// line.  The flag is named after the key, so APP_PORT is --app-port.
 type ConfigFlag struct {
+  // The config key, such as APP_PORT.
   Name string
+  // One of the Flag types above.
   Type string
+  // Used if the key is not set anywhere else.
   Default interface{}
+  // One line description for --help.
   Help string
 }
 
 type ConfigFlags []ConfigFlag
 
+// FlagName turns a config key into its flag name: APP_PORT is app-port.
+func FlagName(key string) string {
+  return strings.Replace(strings.ToLower(key), "_", "-", -1)
+}
 
-func (c *ConfigFlags) Bind() {
-  //flagSet := pflag.NewFlagSet("config", pflag.ContinueOnError)
+// Defaults returns the default of every flag, keyed by config key, for New.
+func (flags ConfigFlags) Defaults() map[string]interface{} {
+  defaults := make(map[string]interface{}, len(flags))
+  for _, flag := range flags {
+    if flag.Default != nil {
+      defaults[flag.Name] = flag.Default
+    }
+  }
+  return defaults
+}
+
+// FlagSet returns a pflag.FlagSet with a flag for every ConfigFlag.  The
+// --help text is generated from the declarations.
+func (flags ConfigFlags) FlagSet(name string, errorHandling pflag.ErrorHandling) (*pflag.FlagSet, error) {
+  fs := pflag.NewFlagSet(name, errorHandling)
+  fs.SortFlags = false
+  for _, flag := range flags {
+    if err := flag.register(fs); err != nil {
+      return nil, err
+    }
+  }
+  fs.Usage = func() {
+    fmt.Fprintf(os.Stderr, "%s", flags.Usage(name, fs))
+  }
+  return fs, nil
+}
+
+// Usage is the --help text for fs, the FlagSet of the program name.
+func (flags ConfigFlags) Usage(name string, fs *pflag.FlagSet) string {
+  return fmt.Sprintf("Usage of %s:\n%s\n%s", name, fs.FlagUsages(),
+    "Each flag can also be set by its key, in the environment or the config file.\n" +
+    "Flags take precedence over the environment, which takes precedence over\n" +
+    "the config file.\n")
+}
+
+func (flag ConfigFlag) register(fs *pflag.FlagSet) error {
+  name := FlagName(flag.Name)
+  usage := fmt.Sprintf("%s (%s)", flag.Help, flag.Name)
+  switch flag.Type {
+  case StringFlag:
+    fs.String(name, cast.ToString(flag.Default), usage)
+  case IntFlag:
+    fs.Int(name, cast.ToInt(flag.Default), usage)
+  case BoolFlag:
+    fs.Bool(name, cast.ToBool(flag.Default), usage)
+  case Float64Flag:
+    fs.Float64(name, cast.ToFloat64(flag.Default), usage)
+  case DurationFlag:
+    fs.Duration(name, cast.ToDuration(flag.Default), usage)
+  case StringSliceFlag:
+    var def []string
+    if flag.Default != nil {
+      def = cast.ToStringSlice(flag.Default)
+    }
+    fs.StringSlice(name, def, usage)
+  default:
+    return fmt.Errorf("Flag %s has unknown type %q", flag.Name, flag.Type)
+  }
+  return nil
+}
+
+// Bind makes the flags in fs set their keys in c.  Only flags given on the
+// command line are used, so the precedence is flag, then env, then the
+// config file, then the default.  The binding is kept across reloads.
+// Secret references are resolved again, since a flag can be one, or can
+// hide one.  Problems with them are left for LoadApp to report.
+func (flags ConfigFlags) Bind(c *Config, fs *pflag.FlagSet) error {
+  for _, flag := range flags {
+    pf := fs.Lookup(FlagName(flag.Name))
+    if pf == nil {
+      return fmt.Errorf("There is no --%s flag to bind to %s", FlagName(flag.Name), flag.Name)
+    }
+    if err := c.BindPFlag(flag.Name, pf); err != nil {
+      return err
+    }
+  }
+  c.resolveSecrets()
+  return nil
+}
 
-  for _, flag := range *c {
-    log.Printf("Flags: %#v", flag)
+// Changed returns the value of the flag for key, if it was given on the
+// command line.  It is for the few keys, such as the config file name, that
+// are needed before there is a Config.
+func (flags ConfigFlags) Changed(fs *pflag.FlagSet, key string) (string, bool) {
+  pf := fs.Lookup(FlagName(key))
+  if pf == nil || !pf.Changed {
+    return "", false
   }
+  return pf.Value.String(), true
 }
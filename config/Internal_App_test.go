@@ -0,0 +1,118 @@
+package config
+
+import (
+  "time"
+  "testing"
+  "github.com/spf13/pflag"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+const testTokenKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func TestLoadApp(t *testing.T) {
+  Convey("Given a config with just the required secrets", t, func() {
+    c := New(nil, &map[string]interface{} {
+      "SLACK_SIGNING_SECRET": "secret",
+      "GITHUB_TOKEN": "token",
+    })
+
+    Convey("The app config should be filled in from the defaults", func() {
+      app, err := LoadApp(c)
+      So(err, ShouldBeNil)
+      So(app.Port, ShouldEqual, 8080)
+      So(app.Jobs.Timeout, ShouldEqual, 5 * time.Minute)
+      So(app.Store.Driver, ShouldEqual, "bolt")
+      So(app.Github.Token, ShouldEqual, "token")
+      So(app.Github.DefaultOwner, ShouldEqual, "confyrm")
+      So(app.Github.MergeUsers, ShouldBeEmpty)
+    })
+
+    Convey("Values should be converted to the field types", func() {
+      c.Set("APP_PORT", "9000")
+      c.Set("SLACK_SIGNATURE_WINDOW", "1m")
+      c.Set("GITHUB_MERGE_USERS", "alice, U2")
+      c.Set("GITHUB_CHANNELS", map[string]interface{}{"o/r": "C1"})
+      app, err := LoadApp(c)
+      So(err, ShouldBeNil)
+      So(app.Port, ShouldEqual, 9000)
+      So(app.Slack.SignatureWindow, ShouldEqual, time.Minute)
+      So(app.Github.MergeUsers, ShouldResemble, []string{"alice", "U2"})
+      So(app.Github.Channels, ShouldResemble, map[string]string{"o/r": "C1"})
+    })
+
+    Convey("Every problem should be reported at once", func() {
+      c.Set("SLACK_SIGNING_SECRET", "")
+      c.Set("GITHUB_TOKEN", "")
+      c.Set("APP_PORT", 70000)
+      c.Set("JOBS_WORKERS", "lots")
+      c.Set("GITHUB_API_URL", "api.github.com")
+      c.Set("STORE_DRIVER", "mongo")
+      c.Set("GITHUB_CLIENT_ID", "client")
+      c.Set("GITHUB_TOKEN_KEY", "c2hvcnQ=")
+      _, err := LoadApp(c)
+      So(err, ShouldNotBeNil)
+      errs := err.(Errors)
+      So(len(errs), ShouldEqual, 12)
+      report := err.Error()
+      So(report, ShouldStartWith, "Found 12 problems with the config:")
+      So(report, ShouldContainSubstring, "APP_PORT: 70000 is not a port")
+      So(report, ShouldContainSubstring, "JOBS_WORKERS: lots is not a valid int")
+      So(report, ShouldContainSubstring, "GITHUB_TOKEN: Is required")
+      So(report, ShouldContainSubstring, "GITHUB_API_URL: \"api.github.com\" is not an http or https url")
+      So(report, ShouldContainSubstring, "STORE_DRIVER: \"mongo\" is not one of bolt, memory")
+      So(report, ShouldContainSubstring, "GITHUB_TOKEN_KEY: Is 5 bytes.  It must be 32")
+      So(report, ShouldContainSubstring, "SLACK_SIGNING_SECRET: Is required, unless SLACK_ALLOW_LEGACY_TOKEN is set")
+      So(report, ShouldContainSubstring, "GITHUB_CLIENT_SECRET: Is required by GITHUB_CLIENT_ID")
+      So(report, ShouldContainSubstring, "GITHUB_OAUTH_REDIRECT_URL: Is required by GITHUB_CLIENT_ID")
+      So(report, ShouldContainSubstring, "SLACK_CLIENT_ID: Is required by GITHUB_CLIENT_ID")
+      So(report, ShouldContainSubstring, "SLACK_CLIENT_SECRET: Is required by GITHUB_CLIENT_ID")
+      So(report, ShouldContainSubstring, "SLACK_OAUTH_REDIRECT_URL: Is required by GITHUB_CLIENT_ID")
+    })
+
+    Convey("The legacy token should stand in for the signing secret, if allowed", func() {
+      c.Set("SLACK_SIGNING_SECRET", "")
+      c.Set("SLACK_ALLOW_LEGACY_TOKEN", true)
+      So(ValidateApp(c).Error(), ShouldContainSubstring, "SLACK_TOKEN: Is required")
+      c.Set("SLACK_TOKEN", "legacy")
+      So(ValidateApp(c), ShouldBeNil)
+    })
+
+    Convey("Account linking should pass with all of its keys", func() {
+      c.Set("GITHUB_CLIENT_ID", "client")
+      c.Set("GITHUB_CLIENT_SECRET", "secret")
+      c.Set("GITHUB_OAUTH_REDIRECT_URL", "https://example.com/auth/github")
+      c.Set("GITHUB_TOKEN_KEY", testTokenKey)
+      So(ValidateApp(c).Error(), ShouldContainSubstring, "SLACK_CLIENT_ID: Is required by GITHUB_CLIENT_ID")
+      c.Set("SLACK_CLIENT_ID", "slack-client")
+      c.Set("SLACK_CLIENT_SECRET", "slack-secret")
+      c.Set("SLACK_OAUTH_REDIRECT_URL", "https://example.com/slack/oauth")
+      So(ValidateApp(c), ShouldBeNil)
+    })
+
+    Convey("App should be cached until the config changes", func() {
+      app := c.App()
+      So(c.App(), ShouldPointTo, app)
+      c.Set("GITHUB_DEFAULT_REPO", "other")
+      So(c.App(), ShouldNotPointTo, app)
+      So(c.App().Github.DefaultRepo, ShouldEqual, "other")
+    })
+  })
+}
+
+func TestStructFlags(t *testing.T) {
+  Convey("The AppConfig flags should come from its tags", t, func() {
+    flags := map[string]ConfigFlag{}
+    for _, flag := range StructFlags(AppConfig{}) {
+      flags[flag.Name] = flag
+    }
+    So(flags["APP_PORT"], ShouldResemble, ConfigFlag{"APP_PORT", IntFlag, 8080, "Port the app listens on"})
+    So(flags["SHUTDOWN_TIMEOUT"].Default, ShouldEqual, 30 * time.Second)
+    So(flags["GITHUB_MERGE_USERS"].Type, ShouldEqual, StringSliceFlag)
+    So(flags["GITHUB_API_URL"].Default, ShouldBeNil)
+    So(flags, ShouldNotContainKey, "GITHUB_TOKEN")
+    So(flags, ShouldNotContainKey, "GITHUB_CHANNELS")
+
+    _, err := StructFlags(AppConfig{}).FlagSet("test", pflag.ContinueOnError)
+    So(err, ShouldBeNil)
+  })
+}
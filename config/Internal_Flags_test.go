@@ -0,0 +1,86 @@
+package config
+
+import (
+  "os"
+  "time"
+  "testing"
+  "github.com/spf13/pflag"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+var testFlags = ConfigFlags {
+  {"FLAG_FILE_SETTING", IntFlag, 1, "Set in the file"},
+  {"FLAG_ENV_SETTING", StringFlag, "default", "Set in the env"},
+  {"FLAG_DEFAULT_SETTING", DurationFlag, time.Minute, "Only has a default"},
+  {"FLAG_BOOL_SETTING", BoolFlag, false, "A switch"},
+  {"FLAG_SLICE_SETTING", StringSliceFlag, nil, "A list"},
+}
+
+func TestFlagName(t *testing.T) {
+  Convey("Flags should be named after their keys", t, func() {
+    So(FlagName("APP_PORT"), ShouldEqual, "app-port")
+    So(FlagName("NAME"), ShouldEqual, "name")
+  })
+}
+
+func TestFlags(t *testing.T) {
+  Convey("Given flags bound to a config file and the env", t, func() {
+    os.Setenv("FLAG_ENV_SETTING", "env")
+    defer os.Unsetenv("FLAG_ENV_SETTING")
+    defaults := testFlags.Defaults()
+    c, file, cleanup := newReloadConfig("FLAG_FILE_SETTING: 2\nFLAG_ENV_SETTING: file\n")
+    defer cleanup()
+    c.Close()
+    for key, value := range defaults {
+      c.SetDefault(key, value)
+    }
+    fs, err := testFlags.FlagSet("test", pflag.ContinueOnError)
+    So(err, ShouldBeNil)
+
+    Convey("When no flags are given", func() {
+      So(fs.Parse([]string{}), ShouldBeNil)
+      So(testFlags.Bind(c, fs), ShouldBeNil)
+
+      Convey("The env, then the file, then the default should be used", func() {
+        So(c.GetString("FLAG_ENV_SETTING"), ShouldEqual, "env")
+        So(c.GetInt("FLAG_FILE_SETTING"), ShouldEqual, 2)
+        So(c.GetDuration("FLAG_DEFAULT_SETTING"), ShouldEqual, time.Minute)
+        So(c.GetBool("FLAG_BOOL_SETTING"), ShouldBeFalse)
+        So(c.GetStringSlice("FLAG_SLICE_SETTING"), ShouldBeEmpty)
+      })
+    })
+
+    Convey("When every flag is given", func() {
+      So(fs.Parse([]string{"--flag-file-setting=3", "--flag-env-setting", "flag",
+        "--flag-default-setting=5s", "--flag-bool-setting", "--flag-slice-setting=a,b"}), ShouldBeNil)
+      So(testFlags.Bind(c, fs), ShouldBeNil)
+
+      Convey("The flags should win", func() {
+        So(c.GetInt("FLAG_FILE_SETTING"), ShouldEqual, 3)
+        So(c.GetString("FLAG_ENV_SETTING"), ShouldEqual, "flag")
+        So(c.GetDuration("FLAG_DEFAULT_SETTING"), ShouldEqual, 5 * time.Second)
+        So(c.GetBool("FLAG_BOOL_SETTING"), ShouldBeTrue)
+        So(c.GetStringSlice("FLAG_SLICE_SETTING"), ShouldResemble, []string{"a", "b"})
+      })
+
+      Convey("And they should still win after a reload", func() {
+        writeConfig(file, "FLAG_FILE_SETTING: 4\n")
+        So(c.Reload(), ShouldBeNil)
+        So(c.GetInt("FLAG_FILE_SETTING"), ShouldEqual, 3)
+        So(c.GetString("FLAG_ENV_SETTING"), ShouldEqual, "flag")
+      })
+    })
+
+    Convey("The usage should come from the declarations", func() {
+      usage := testFlags.Usage("test", fs)
+      So(usage, ShouldContainSubstring, "--flag-file-setting int")
+      So(usage, ShouldContainSubstring, "Set in the file (FLAG_FILE_SETTING)")
+      So(usage, ShouldContainSubstring, "(default 1m0s)")
+    })
+  })
+
+  Convey("A flag with an unknown type should not be registered", t, func() {
+    _, err := ConfigFlags{{"FLAG_MAP_SETTING", "map", nil, "A map"}}.FlagSet("test", pflag.ContinueOnError)
+    So(err, ShouldNotBeNil)
+  })
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+  "os"
+  "time"
+  "testing"
+  "io/ioutil"
+  "path/filepath"
+  "github.com/spf13/pflag"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+// newProfileConfig writes base to config.yml, and overlay to
+// config.prod.yml if it is not empty, and loads them with the prod profile.
+func newProfileConfig(base string, overlay string) (*Config, string, func()) {
+  dir, err := ioutil.TempDir("", "profiles")
+  if err != nil {
+    panic(err)
+  }
+  writeConfig(filepath.Join(dir, "config.yml"), base)
+  if overlay != "" {
+    writeConfig(filepath.Join(dir, "config.prod.yml"), overlay)
+  }
+  name := filepath.Join(dir, "config")
+  c := NewProfile(&name, "prod", &map[string]interface{} {"DEFAULT_SETTING": "default"})
+  return c, dir, func() {
+    c.Close()
+    os.RemoveAll(dir)
+  }
+}
+
+const baseConfig = `
+APP_NAME: devhub
+APP_PORT: 8080
+SLACK_CHANNEL_REPOS:
+  general: o/r
+  random: o/other
+NESTED:
+  LIMITS:
+    SIZE: 1
+    COUNT: 2
+`
+
+func TestProfiles(t *testing.T) {
+  Convey("Given a base config file and a profile", t, func() {
+    c, dir, cleanup := newProfileConfig(baseConfig, `
+APP_PORT: "9000"
+SLACK_CHANNEL_REPOS:
+  random: o/prod
+NESTED:
+  LIMITS:
+    SIZE: 10
+`)
+    defer cleanup()
+    c.Close()
+
+    Convey("The profile should be read over the base", func() {
+      So(c.GetString("APP_NAME"), ShouldEqual, "devhub")
+      So(c.GetInt("APP_PORT"), ShouldEqual, 9000)
+    })
+
+    Convey("Nested maps should be merged", func() {
+      So(c.GetStringMapString("SLACK_CHANNEL_REPOS"), ShouldResemble, map[string]string{"general": "o/r", "random": "o/prod"})
+      So(c.GetInt("NESTED.LIMITS.SIZE"), ShouldEqual, 10)
+      So(c.GetInt("NESTED.LIMITS.COUNT"), ShouldEqual, 2)
+    })
+
+    Convey("The source of each key should be known", func() {
+      os.Setenv("APP_NAME", "from-env")
+      defer os.Unsetenv("APP_NAME")
+      flags := ConfigFlags{{"APP_ROOT", StringFlag, nil, ""}}
+      fs, err := flags.FlagSet("test", pflag.ContinueOnError)
+      So(err, ShouldBeNil)
+      So(fs.Parse([]string{"--app-root=/srv"}), ShouldBeNil)
+      So(flags.Bind(c, fs), ShouldBeNil)
+      c.Set("GITHUB_TOKEN", "set-by-test-token")
+
+      sources := c.Sources()
+      So(sources["APP_ROOT"], ShouldResemble, Source{"/srv", "flag"})
+      So(sources["APP_NAME"], ShouldResemble, Source{"from-env", "env"})
+      So(sources["APP_PORT"], ShouldResemble, Source{"9000", "file " + filepath.Join(dir, "config.prod.yml")})
+      So(sources["NESTED.LIMITS.COUNT"].Layer, ShouldEqual, "file " + filepath.Join(dir, "config.yml"))
+      So(sources["SLACK_CHANNEL_REPOS.RANDOM"].Layer, ShouldEqual, "file " + filepath.Join(dir, "config.prod.yml"))
+      So(sources["SLACK_CHANNEL_REPOS.GENERAL"].Layer, ShouldEqual, "file " + filepath.Join(dir, "config.yml"))
+      So(sources, ShouldNotContainKey, "SLACK_CHANNEL_REPOS")
+      So(sources["DEFAULT_SETTING"], ShouldResemble, Source{"default", "default"})
+      So(sources["GITHUB_TOKEN"], ShouldResemble, Source{Redacted, "override"})
+      So(sources, ShouldNotContainKey, "GITHUB_API_URL")
+    })
+
+    Convey("When the profile's file changes, it should be read again on reload", func() {
+      writeConfig(filepath.Join(dir, "config.prod.yml"), "APP_PORT: 9001\n")
+      So(c.Reload(), ShouldBeNil)
+      So(c.GetInt("APP_PORT"), ShouldEqual, 9001)
+      So(c.GetStringMapString("SLACK_CHANNEL_REPOS")["random"], ShouldEqual, "o/other")
+    })
+
+    Convey("When the profile's file is removed, the base should be used on reload", func() {
+      So(os.Remove(filepath.Join(dir, "config.prod.yml")), ShouldBeNil)
+      So(c.Reload(), ShouldBeNil)
+      So(c.GetInt("APP_PORT"), ShouldEqual, 8080)
+      So(c.Sources()["APP_PORT"].Layer, ShouldEqual, "file " + filepath.Join(dir, "config.yml"))
+    })
+  })
+
+  Convey("Given a profile with no file", t, func() {
+    c, _, cleanup := newProfileConfig(baseConfig, "")
+    defer cleanup()
+
+    Convey("The base should be used", func() {
+      So(c.GetInt("APP_PORT"), ShouldEqual, 8080)
+    })
+  })
+
+  Convey("Given a watched profile", t, func() {
+    defer func(delay time.Duration) { ReloadDelay = delay }(ReloadDelay)
+    ReloadDelay = 10 * time.Millisecond
+    c, dir, cleanup := newProfileConfig(baseConfig, "")
+    defer cleanup()
+    reloaded := make(chan int, 1)
+    c.Subscribe("recorder", func(c *Config) error {
+      reloaded <- c.GetInt("APP_PORT")
+      return nil
+    })
+
+    Convey("When the profile's file is created, it should be read", func() {
+      writeConfig(filepath.Join(dir, "config.prod.yml"), "APP_PORT: 9002\n")
+      select {
+      case port := <-reloaded:
+        So(port, ShouldEqual, 9002)
+      case <-time.After(5 * time.Second):
+        So("no reload", ShouldBeNil)
+      }
+    })
+  })
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+  "os"
+  "time"
+  "errors"
+  "testing"
+  "io/ioutil"
+  "path/filepath"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+// newReloadConfig writes contents to config.yaml in a new directory, and
+// loads it.  The file is watched until the returned func is called.
+func newReloadConfig(contents string) (*Config, string, func()) {
+  dir, err := ioutil.TempDir("", "config")
+  if err != nil {
+    panic(err)
+  }
+  file := filepath.Join(dir, "config.yaml")
+  writeConfig(file, contents)
+  name := filepath.Join(dir, "config")
+  c := New(&name, &map[string]interface{} {"DEFAULT_SETTING": "default"})
+  return c, file, func() {
+    c.Close()
+    os.RemoveAll(dir)
+  }
+}
+
+func writeConfig(file string, contents string) {
+  if err := ioutil.WriteFile(file, []byte(contents), 0600); err != nil {
+    panic(err)
+  }
+}
+
+func TestReload(t *testing.T) {
+  Convey("Given a config file with a subscriber", t, func() {
+    c, file, cleanup := newReloadConfig("NUMBER_SETTING: 1\nSTRING_SETTING: one\n")
+    defer cleanup()
+    // Reload by hand, not when the watcher sees the file change.
+    c.Close()
+    seen := []int{}
+    c.Subscribe("recorder", func(c *Config) error {
+      seen = append(seen, c.GetInt("NUMBER_SETTING"))
+      return nil
+    })
+
+    So(c.GetInt("NUMBER_SETTING"), ShouldEqual, 1)
+
+    Convey("When the file is changed and reloaded", func() {
+      writeConfig(file, "NUMBER_SETTING: 2\n")
+      err := c.Reload()
+
+      Convey("The new values should be swapped in, and the subscriber told", func() {
+        So(err, ShouldBeNil)
+        So(c.GetInt("NUMBER_SETTING"), ShouldEqual, 2)
+        So(c.IsSet("STRING_SETTING"), ShouldBeFalse)
+        So(c.GetString("DEFAULT_SETTING"), ShouldEqual, "default")
+        So(seen, ShouldResemble, []int{2})
+      })
+    })
+
+    Convey("When the new file can not be parsed", func() {
+      writeConfig(file, "NUMBER_SETTING: [2\n")
+      err := c.Reload()
+
+      Convey("The old values should be kept", func() {
+        So(err, ShouldNotBeNil)
+        So(c.GetInt("NUMBER_SETTING"), ShouldEqual, 1)
+        So(c.GetString("STRING_SETTING"), ShouldEqual, "one")
+        So(seen, ShouldBeEmpty)
+      })
+    })
+
+    Convey("When a validator rejects the new file", func() {
+      c.AddValidator("positive", func(next *Config) error {
+        if next.GetInt("NUMBER_SETTING") < 0 {
+          return errors.New("NUMBER_SETTING must not be negative")
+        }
+        return nil
+      })
+      writeConfig(file, "NUMBER_SETTING: -2\n")
+      err := c.Reload()
+
+      Convey("The old values should be kept, and no one told", func() {
+        So(err, ShouldNotBeNil)
+        So(err.Error(), ShouldContainSubstring, "positive")
+        So(c.GetInt("NUMBER_SETTING"), ShouldEqual, 1)
+        So(seen, ShouldBeEmpty)
+      })
+    })
+
+    Convey("When a later subscriber can not take the new file", func() {
+      c.Subscribe("picky", func(c *Config) error {
+        if c.GetInt("NUMBER_SETTING") == 3 {
+          return errors.New("no threes")
+        }
+        return nil
+      })
+      writeConfig(file, "NUMBER_SETTING: 3\n")
+      err := c.Reload()
+
+      Convey("It should be rolled back, and the earlier subscriber told again", func() {
+        So(err, ShouldNotBeNil)
+        So(err.Error(), ShouldContainSubstring, "picky")
+        So(c.GetInt("NUMBER_SETTING"), ShouldEqual, 1)
+        So(seen, ShouldResemble, []int{3, 1})
+      })
+    })
+  })
+}
+
+func TestWatch(t *testing.T) {
+  Convey("Given a watched config file", t, func() {
+    defer func(delay time.Duration) { ReloadDelay = delay }(ReloadDelay)
+    ReloadDelay = 10 * time.Millisecond
+    c, file, cleanup := newReloadConfig("NUMBER_SETTING: 1\n")
+    defer cleanup()
+    reloaded := make(chan int, 1)
+    c.Subscribe("recorder", func(c *Config) error {
+      reloaded <- c.GetInt("NUMBER_SETTING")
+      return nil
+    })
+
+    Convey("When the file is written", func() {
+      writeConfig(file, "NUMBER_SETTING: 2\n")
+
+      Convey("It should be reloaded", func() {
+        select {
+        case n := <-reloaded:
+          So(n, ShouldEqual, 2)
+        case <-time.After(5 * time.Second):
+          So("no reload", ShouldBeNil)
+        }
+        So(c.GetInt("NUMBER_SETTING"), ShouldEqual, 2)
+      })
+    })
+  })
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+  "os"
+  "bytes"
+  "testing"
+  "io/ioutil"
+  "path/filepath"
+  "github.com/spf13/pflag"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+// resetRedactions forgets the secrets other tests loaded, which are short
+// words, such as "token", that would otherwise be redacted here too.
+func resetRedactions() {
+  redactions.Lock()
+  defer redactions.Unlock()
+  redactions.values = nil
+}
+
+func TestSecrets(t *testing.T) {
+  Convey("Given secrets in a file, the env and a sealed file", t, func() {
+    resetRedactions()
+    dir, err := ioutil.TempDir("", "secrets")
+    So(err, ShouldBeNil)
+    defer os.RemoveAll(dir)
+    writeConfig(filepath.Join(dir, "github_token"), "file-secret-value\n")
+    os.Setenv("TEST_SLACK_SECRET", "env-secret-value")
+    defer os.Unsetenv("TEST_SLACK_SECRET")
+    keyFile := filepath.Join(dir, "test.key")
+    writeConfig(keyFile, testTokenKey + "\n")
+    sealed, err := SealSecret(keyFile, "sealed-secret-value")
+    So(err, ShouldBeNil)
+    writeConfig(filepath.Join(dir, "bot_token"), sealed)
+
+    c, file, cleanup := newReloadConfig(
+      "GITHUB_TOKEN: secret://file/" + filepath.Join(dir, "github_token") + "\n" +
+      "SLACK_SIGNING_SECRET: secret://env/TEST_SLACK_SECRET\n" +
+      "SLACK_BOT_TOKEN: secret://aes/" + filepath.Join(dir, "bot_token") + "\n" +
+      "SECRETS_KEY_FILE: " + keyFile + "\n")
+    defer cleanup()
+    c.Close()
+
+    Convey("The getters should return the secrets", func() {
+      So(c.GetString("GITHUB_TOKEN"), ShouldEqual, "file-secret-value")
+      So(c.GetString("SLACK_SIGNING_SECRET"), ShouldEqual, "env-secret-value")
+      So(c.Get("SLACK_BOT_TOKEN"), ShouldEqual, "sealed-secret-value")
+      app, err := LoadApp(c)
+      So(err, ShouldBeNil)
+      So(app.Slack.BotToken, ShouldEqual, "sealed-secret-value")
+    })
+
+    Convey("The secrets should be redacted from logs and dumps", func() {
+      var out bytes.Buffer
+      w := RedactWriter{&out}
+      w.Write([]byte("token file-secret-value and sealed-secret-value\n"))
+      So(out.String(), ShouldEqual, "token [redacted] and [redacted]\n")
+
+      settings := RedactSettings(c.AllSettings())
+      So(settings["github_token"], ShouldEqual, Redacted)
+      So(settings["secrets_key_file"], ShouldEqual, keyFile)
+    })
+
+    Convey("A flag should still take precedence over a reference", func() {
+      flags := ConfigFlags{{"SLACK_SIGNING_SECRET", StringFlag, nil, ""}}
+      fs, err := flags.FlagSet("test", pflag.ContinueOnError)
+      So(err, ShouldBeNil)
+      So(fs.Parse([]string{"--slack-signing-secret=flag-secret-value"}), ShouldBeNil)
+      So(flags.Bind(c, fs), ShouldBeNil)
+      So(c.GetString("SLACK_SIGNING_SECRET"), ShouldEqual, "flag-secret-value")
+    })
+
+    Convey("When a secret changes, it should be read again on reload", func() {
+      writeConfig(filepath.Join(dir, "github_token"), "new-secret-value")
+      writeConfig(file, "GITHUB_TOKEN: secret://file/" + filepath.Join(dir, "github_token") + "\n")
+      So(c.Reload(), ShouldBeNil)
+      So(c.GetString("GITHUB_TOKEN"), ShouldEqual, "new-secret-value")
+    })
+
+    Convey("Short secrets should be redacted too", func() {
+      writeConfig(filepath.Join(dir, "github_token"), "gh1")
+      writeConfig(file, "GITHUB_TOKEN: secret://file/" + filepath.Join(dir, "github_token") + "\n" +
+        "SLACK_CLIENT_SECRET: sc2\n")
+      So(c.Reload(), ShouldBeNil)
+      var out bytes.Buffer
+      w := RedactWriter{&out}
+      w.Write([]byte("token gh1 and sc2\n"))
+      So(out.String(), ShouldEqual, "token [redacted] and [redacted]\n")
+    })
+
+    Convey("When a reloaded reference can not be resolved", func() {
+      writeConfig(file, "GITHUB_TOKEN: secret://file/" + filepath.Join(dir, "missing") + "\n")
+      err := c.Reload()
+
+      Convey("The old config should be kept", func() {
+        So(err, ShouldNotBeNil)
+        So(err.Error(), ShouldContainSubstring, "GITHUB_TOKEN")
+        So(c.GetString("GITHUB_TOKEN"), ShouldEqual, "file-secret-value")
+      })
+    })
+  })
+
+  Convey("References that can not be resolved should be reported by LoadApp", t, func() {
+    c := New(nil, &map[string]interface{} {
+      "SLACK_SIGNING_SECRET": "secret://vault/slack",
+      "GITHUB_TOKEN": "secret://env/TEST_NOT_SET",
+      "SLACK_BOT_TOKEN": "secret://aes/bot_token",
+    })
+    _, err := LoadApp(c)
+    So(err, ShouldNotBeNil)
+    So(err.Error(), ShouldContainSubstring, "SLACK_SIGNING_SECRET: Unknown secret provider \"vault\"")
+    So(err.Error(), ShouldContainSubstring, "GITHUB_TOKEN: Could not read secret://env/TEST_NOT_SET: TEST_NOT_SET is not set")
+    So(err.Error(), ShouldContainSubstring, "SLACK_BOT_TOKEN: Could not read secret://aes/bot_token: SECRETS_KEY_FILE is not set")
+  })
+}
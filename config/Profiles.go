@@ -0,0 +1,200 @@
+package config
+
+import (
+  "os"
+  "fmt"
+  "bytes"
+  "strings"
+  "path/filepath"
+  "encoding/json"
+  "github.com/spf13/cast"
+  "github.com/spf13/viper"
+)
+
+// ProfileKey names the profile, such as prod or dev.  The profile's file,
+// such as config.prod.yml next to config.yml, is read over the base file.
+const ProfileKey = "GOREST_PROFILE"
+
+// layer is a config file, and the keys it sets.
+type layer struct {
+  file string
+  keys map[string]bool
+}
+
+// readLayers reads the config file into v, and then the profile's file, if
+// there is one, over it.  Nested maps are merged, so the profile only needs
+// the keys it changes.  Anything else in the profile replaces the base.
+func readLayers(v *viper.Viper, profile string) ([]layer, error) {
+  if err := v.ReadInConfig(); err != nil {
+    return nil, err
+  }
+  files := []string{v.ConfigFileUsed()}
+  if overlay := findProfile(files[0], profile); overlay != "" {
+    files = append(files, overlay)
+  }
+  if len(files) == 1 {
+    return []layer{{files[0], keySet(v.AllKeys())}}, nil
+  }
+
+  // Viper's own merge skips values whose types differ between the files,
+  // so the files are merged here, and handed to Viper as one.
+  merged := map[string]interface{}{}
+  layers := make([]layer, len(files))
+  for i, file := range files {
+    f := viper.New()
+    f.SetConfigFile(file)
+    if err := f.ReadInConfig(); err != nil {
+      return nil, fmt.Errorf("Could not read %s: %s", file, err.Error())
+    }
+    deepMerge(merged, f.AllSettings())
+    layers[i] = layer{file, keySet(f.AllKeys())}
+  }
+  data, err := json.Marshal(merged)
+  if err != nil {
+    return nil, err
+  }
+  v.SetConfigType("json")
+  if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+    return nil, err
+  }
+  return layers, nil
+}
+
+// profileFiles returns the files the profile could be in, such as
+// config.prod.yml and config.prod.json for config.yml.
+func profileFiles(base string, profile string) []string {
+  if profile == "" || base == "" {
+    return nil
+  }
+  name := strings.TrimSuffix(base, filepath.Ext(base))
+  files := make([]string, len(viper.SupportedExts))
+  for i, ext := range viper.SupportedExts {
+    files[i] = name + "." + profile + "." + ext
+  }
+  return files
+}
+
+// findProfile returns the profile's file, or "" if there is not one.
+func findProfile(base string, profile string) string {
+  for _, file := range profileFiles(base, profile) {
+    if _, err := os.Stat(file); err == nil {
+      return file
+    }
+  }
+  return ""
+}
+
+// deepMerge merges src into dst.  Maps in both are merged, and anything
+// else in src replaces what is in dst.
+func deepMerge(dst map[string]interface{}, src map[string]interface{}) {
+  for key, value := range src {
+    if srcMap, ok := toMap(value); ok {
+      if dstMap, ok := toMap(dst[key]); ok {
+        deepMerge(dstMap, srcMap)
+        dst[key] = dstMap
+        continue
+      }
+      dst[key] = srcMap
+      continue
+    }
+    dst[key] = value
+  }
+}
+
+func toMap(value interface{}) (map[string]interface{}, bool) {
+  switch value.(type) {
+  case map[string]interface{}, map[interface{}]interface{}:
+    m, err := cast.ToStringMapE(value)
+    return m, err == nil
+  }
+  return nil, false
+}
+
+func keySet(keys []string) map[string]bool {
+  set := make(map[string]bool, len(keys))
+  for _, key := range keys {
+    set[strings.ToLower(key)] = true
+  }
+  return set
+}
+
+// Source is the value of a key, and the layer it came from: override,
+// flag, env, file <name>, default, or flag default.
+type Source struct {
+  Value interface{} `json:"value"`
+  Layer string `json:"layer"`
+}
+
+// Sources returns where the value of every key came from, for the admin
+// server.  Maps from the config files are listed by their entries, such as
+// SLACK_CHANNEL_REPOS.GENERAL, since each entry can come from a different
+// file.  Secrets are redacted.
+func (c *Config) Sources() map[string]Source {
+  keys, secret := appKeys()
+  leaves := c.AllKeys()
+  keys = append(keys, leaves...)
+
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  sources := map[string]Source{}
+  for _, key := range keys {
+    key = strings.ToUpper(key)
+    if _, done := sources[key]; done || hasEntries(key, leaves) {
+      continue
+    }
+    layer := c.layerOf(key)
+    if layer == "" {
+      continue
+    }
+    value := c.get(key)
+    if secret[key] {
+      if s := cast.ToString(value); s != "" {
+        value = Redacted
+      }
+    } else {
+      value = redactValue(value)
+    }
+    sources[key] = Source{value, layer}
+  }
+  return sources
+}
+
+func hasEntries(key string, leaves []string) bool {
+  prefix := strings.ToLower(key) + "."
+  for _, leaf := range leaves {
+    if strings.HasPrefix(leaf, prefix) {
+      return true
+    }
+  }
+  return false
+}
+
+// layerOf returns the layer the value of key comes from, in Viper's order.
+// c.mu must be held.
+func (c *Config) layerOf(key string) string {
+  lower := strings.ToLower(key)
+  if c.overrides[lower] {
+    return "override"
+  }
+  flag, bound := c.pflags[key]
+  if bound && flag.Changed {
+    return "flag"
+  }
+  if os.Getenv(strings.ToUpper(key)) != "" {
+    return "env"
+  }
+  for i := len(c.layers) - 1; i >= 0; i-- {
+    if c.layers[i].keys[lower] {
+      return "file " + c.layers[i].file
+    }
+  }
+  for k := range c.defaults {
+    if strings.EqualFold(k, key) {
+      return "default"
+    }
+  }
+  if bound {
+    return "flag default"
+  }
+  return ""
+}
@@ -0,0 +1,318 @@
+package config
+
+import (
+  "fmt"
+  "log"
+  "time"
+  "strings"
+  "path/filepath"
+  "github.com/fsnotify/fsnotify"
+  "github.com/spf13/cast"
+  "github.com/spf13/pflag"
+  "github.com/spf13/viper"
+)
+
+// ReloadFunc is told about a new config.  Validators get the candidate
+// before it is swapped in.  Subscribers get the Config after, and should
+// re-read whatever they copied out of it.  Either can return an error to
+// reject the new config.
+type ReloadFunc func(c *Config) error
+
+type subscription struct {
+  name string
+  f ReloadFunc
+}
+
+// AddValidator registers f to check every new config file before it is
+// swapped in.  If f returns an error, the current config is kept.
+func (c *Config) AddValidator(name string, f ReloadFunc) {
+  c.subMu.Lock()
+  defer c.subMu.Unlock()
+  c.validators = append(c.validators, subscription{name, f})
+}
+
+// Subscribe registers f to be called each time a new config file has been
+// swapped in.  Subscribers are called in the order they subscribed.  If f
+// returns an error, the previous config is swapped back, and the
+// subscribers that already took the new config are called again.
+func (c *Config) Subscribe(name string, f ReloadFunc) {
+  c.subMu.Lock()
+  defer c.subMu.Unlock()
+  c.subscribers = append(c.subscribers, subscription{name, f})
+}
+
+// Reload reads the config file again.  The new config is validated, then
+// swapped in as a whole, so readers see either all of the old values or all
+// of the new ones.  New calls this when the file changes.
+func (c *Config) Reload() error {
+  c.subMu.Lock()
+  defer c.subMu.Unlock()
+
+  file := c.ConfigFileUsed()
+  if file == "" {
+    return fmt.Errorf("No config file to reload")
+  }
+  next := c.load(file)
+  layers, err := readLayers(next, c.profile)
+  if err != nil {
+    log.Printf("Config: Keeping the current config.  Could not read %s: %s", file, err.Error())
+    return err
+  }
+
+  candidate := &Config{Viper: *next, defaults: c.defaults, pflags: c.pflags,
+    profile: c.profile, layers: layers, overrides: make(map[string]bool)}
+  if err := candidate.resolveSecrets(); err != nil {
+    log.Printf("Config: Keeping the current config.  Could not resolve the secrets in %s: %s", file, err.Error())
+    return err
+  }
+  for _, v := range c.validators {
+    if err := v.f(candidate); err != nil {
+      log.Printf("Config: Keeping the current config.  %s rejected %s: %s", v.name, file, err.Error())
+      return fmt.Errorf("%s: %s", v.name, err.Error())
+    }
+  }
+
+  old := c.swap(loaded{*next, candidate.secrets, layers, candidate.overrides})
+  for i, s := range c.subscribers {
+    if err := s.f(c); err != nil {
+      log.Printf("Config: %s could not take %s: %s.  Rolling back.", s.name, file, err.Error())
+      c.swap(old)
+      for _, prev := range c.subscribers[:i] {
+        if err := prev.f(c); err != nil {
+          log.Printf("Config: %s could not roll back: %s", prev.name, err.Error())
+        }
+      }
+      return fmt.Errorf("%s: %s", s.name, err.Error())
+    }
+  }
+  log.Printf("Config: Reloaded %s", file)
+  return nil
+}
+
+// load returns a Viper set up like the current one, for file.
+func (c *Config) load(file string) *viper.Viper {
+  v := viper.New()
+  v.AutomaticEnv()
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  for key, value := range c.defaults {
+    v.SetDefault(key, value)
+  }
+  for key, flag := range c.pflags {
+    v.BindPFlag(key, flag)
+  }
+  v.SetConfigFile(file)
+  return v
+}
+
+// loaded is everything that is read from the config file, and swapped as a
+// whole on reload.
+type loaded struct {
+  viper viper.Viper
+  secrets map[string]string
+  layers []layer
+  overrides map[string]bool
+}
+
+func (c *Config) swap(next loaded) loaded {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  old := loaded{c.Viper, c.secrets, c.layers, c.overrides}
+  c.Viper, c.secrets, c.layers, c.overrides = next.viper, next.secrets, next.layers, next.overrides
+  c.changed()
+  return old
+}
+
+// ReloadDelay lets an editor finish saving before the file is read.  Most
+// save a file with more than one write.
+var ReloadDelay = 100 * time.Millisecond
+
+// watch reloads the config file when it, or the profile's file, changes,
+// until Close is called.  The whole directory is watched, so files that are
+// saved by renaming a new file over them are seen, as is a profile's file
+// that is created later.
+func (c *Config) watch() error {
+  file := filepath.Clean(c.ConfigFileUsed())
+  files := map[string]bool{file: true}
+  for _, profileFile := range profileFiles(file, c.profile) {
+    files[profileFile] = true
+  }
+  watcher, err := fsnotify.NewWatcher()
+  if err != nil {
+    return err
+  }
+  if err := watcher.Add(filepath.Dir(file)); err != nil {
+    watcher.Close()
+    return err
+  }
+
+  delay := ReloadDelay
+  c.watching.Add(1)
+  go func() {
+    defer c.watching.Done()
+    defer watcher.Close()
+    var pending <-chan time.Time
+    for {
+      select {
+      case event := <-watcher.Events:
+        if files[filepath.Clean(event.Name)] && event.Op & (fsnotify.Write | fsnotify.Create | fsnotify.Remove | fsnotify.Rename) != 0 {
+          log.Println("Config file changed:", event.Name)
+          pending = time.After(delay)
+        }
+      case err := <-watcher.Errors:
+        log.Printf("Config: Error watching %s: %s", file, err.Error())
+      case <-pending:
+        pending = nil
+        c.Reload()
+      case <-c.done:
+        return
+      }
+    }
+  }()
+  return nil
+}
+
+// Close stops watching the config file.  A reload that has already started
+// is finished first.
+func (c *Config) Close() {
+  c.closeOnce.Do(func() {
+    close(c.done)
+  })
+  c.watching.Wait()
+}
+
+// The Viper getters are wrapped so that they never see a config that is
+// half swapped, and so that they return secrets rather than references.
+
+func (c *Config) Get(key string) interface{} {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return c.get(key)
+}
+
+func (c *Config) GetString(key string) string {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return cast.ToString(c.get(key))
+}
+
+func (c *Config) GetBool(key string) bool {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return cast.ToBool(c.get(key))
+}
+
+func (c *Config) GetInt(key string) int {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return cast.ToInt(c.get(key))
+}
+
+func (c *Config) GetInt64(key string) int64 {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return cast.ToInt64(c.get(key))
+}
+
+func (c *Config) GetFloat64(key string) float64 {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return cast.ToFloat64(c.get(key))
+}
+
+func (c *Config) GetDuration(key string) time.Duration {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return cast.ToDuration(c.get(key))
+}
+
+func (c *Config) GetTime(key string) time.Time {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return cast.ToTime(c.get(key))
+}
+
+func (c *Config) GetStringSlice(key string) []string {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return cast.ToStringSlice(c.get(key))
+}
+
+func (c *Config) GetStringMap(key string) map[string]interface{} {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return cast.ToStringMap(c.get(key))
+}
+
+func (c *Config) GetStringMapString(key string) map[string]string {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return cast.ToStringMapString(c.get(key))
+}
+
+func (c *Config) IsSet(key string) bool {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return c.Viper.IsSet(key)
+}
+
+func (c *Config) AllKeys() []string {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return c.Viper.AllKeys()
+}
+
+func (c *Config) AllSettings() map[string]interface{} {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return c.Viper.AllSettings()
+}
+
+func (c *Config) ConfigFileUsed() string {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return c.Viper.ConfigFileUsed()
+}
+
+func (c *Config) UnmarshalKey(key string, rawVal interface{}) error {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return c.Viper.UnmarshalKey(key, rawVal)
+}
+
+// Set overrides key until the next reload.
+func (c *Config) Set(key string, value interface{}) {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  c.overrides[strings.ToLower(key)] = true
+  c.Viper.Set(key, value)
+  c.changed()
+}
+
+// SetDefault sets a default that is kept across reloads.
+func (c *Config) SetDefault(key string, value interface{}) {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  c.defaults[key] = value
+  c.Viper.SetDefault(key, value)
+  c.changed()
+}
+
+// BindPFlag makes flag set key, if it was given on the command line.  The
+// binding is kept across reloads.
+func (c *Config) BindPFlag(key string, flag *pflag.Flag) error {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  if err := c.Viper.BindPFlag(key, flag); err != nil {
+    return err
+  }
+  c.pflags[strings.ToUpper(key)] = flag
+  c.changed()
+  return nil
+}
+
+// changed drops the cached AppConfig.  c.mu must be held.
+func (c *Config) changed() {
+  c.gen++
+  c.app = nil
+}
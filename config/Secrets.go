@@ -0,0 +1,294 @@
+package config
+
+import (
+  "io"
+  "os"
+  "fmt"
+  "sync"
+  "errors"
+  "strings"
+  "reflect"
+  "io/ioutil"
+  "crypto/aes"
+  "crypto/rand"
+  "crypto/cipher"
+  "encoding/base64"
+)
+
+// SecretScheme starts a reference to a secret, such as
+// secret://file/secrets/github_token.  Any value in the config, from the
+// file, the env or a flag, can be a reference.  References are resolved
+// when the config is loaded, and again when it is reloaded, so code that
+// reads the config only ever sees the secret.
+const SecretScheme = "secret://"
+
+// SecretsKeyFile is the config key for the AES-256 key used by the aes
+// provider.  The file holds the key, base64 encoded.
+const SecretsKeyFile = "SECRETS_KEY_FILE"
+
+// Redacted is shown in place of a secret in logs and config dumps.
+const Redacted = "[redacted]"
+
+// SecretProvider returns the secret at path.  The path is everything after
+// secret://<provider>/, so relative paths are relative to the working
+// folder, and absolute paths start with a second slash, as in
+// secret://file//run/secrets/github_token.  c is the config being loaded.
+type SecretProvider func(c *Config, path string) (string, error)
+
+// SecretProviders are the providers that can be named in a reference.  Add
+// to it to fetch secrets from somewhere else.
+//     file  The contents of a file, without a trailing newline
+//     env   An env var, as in secret://env/GITHUB_TOKEN_PROD
+//     aes   A file sealed with SealSecret, and the key in SECRETS_KEY_FILE
+var SecretProviders = map[string]SecretProvider {
+  "file": FileSecret,
+  "env": EnvSecret,
+  "aes": AesSecret,
+}
+
+// FileSecret reads the secret from a file.
+func FileSecret(c *Config, path string) (string, error) {
+  data, err := ioutil.ReadFile(path)
+  if err != nil {
+    return "", err
+  }
+  return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvSecret reads the secret from an env var.
+func EnvSecret(c *Config, name string) (string, error) {
+  value, ok := os.LookupEnv(name)
+  if !ok {
+    return "", fmt.Errorf("%s is not set", name)
+  }
+  return value, nil
+}
+
+// AesSecret reads a file sealed by SealSecret, and opens it with the key in
+// SECRETS_KEY_FILE.
+func AesSecret(c *Config, path string) (string, error) {
+  aead, err := secretsCipher(c.GetString(SecretsKeyFile))
+  if err != nil {
+    return "", err
+  }
+  data, err := ioutil.ReadFile(path)
+  if err != nil {
+    return "", err
+  }
+  sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+  if err != nil {
+    return "", fmt.Errorf("%s is not base64 encoded", path)
+  }
+  if len(sealed) < aead.NonceSize() {
+    return "", fmt.Errorf("%s is too short", path)
+  }
+  nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+  secret, err := aead.Open(nil, nonce, sealed, nil)
+  if err != nil {
+    return "", fmt.Errorf("%s could not be opened with %s", path, SecretsKeyFile)
+  }
+  return string(secret), nil
+}
+
+// SealSecret encrypts secret with the key in keyFile, for a file that the
+// aes provider can read.  See the seal command.
+func SealSecret(keyFile string, secret string) (string, error) {
+  aead, err := secretsCipher(keyFile)
+  if err != nil {
+    return "", err
+  }
+  nonce := make([]byte, aead.NonceSize())
+  if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+    return "", err
+  }
+  return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func secretsCipher(file string) (cipher.AEAD, error) {
+  if file == "" {
+    return nil, fmt.Errorf("%s is not set", SecretsKeyFile)
+  }
+  data, err := ioutil.ReadFile(file)
+  if err != nil {
+    return nil, err
+  }
+  key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+  if err != nil || len(key) != 32 {
+    return nil, fmt.Errorf("%s must hold a base64 encoded, 32 byte key", SecretsKeyFile)
+  }
+  block, err := aes.NewCipher(key)
+  if err != nil {
+    return nil, err
+  }
+  return cipher.NewGCM(block)
+}
+
+// resolveSecrets resolves every reference in c, and keeps the secrets for
+// the getters.  The keys looked at are the ones Viper knows of, and the ones
+// in AppConfig, since keys that are only in the env are not listed by
+// Viper.  The values of the AppConfig secrets are redacted, even if they
+// were not references.  References that can not be resolved are left as
+// they are, and returned, as well as reported by LoadApp.
+func (c *Config) resolveSecrets() error {
+  keys, secret := appKeys()
+  keys = append(keys, c.AllKeys()...)
+  resolved := map[string]string{}
+  var errs Errors
+  seen := map[string]bool{}
+  for _, key := range keys {
+    key = strings.ToUpper(key)
+    if seen[key] {
+      continue
+    }
+    seen[key] = true
+    c.mu.RLock()
+    value, ok := c.Viper.Get(key).(string)
+    c.mu.RUnlock()
+    if ok && strings.HasPrefix(value, SecretScheme) {
+      if _, done := resolved[value]; done {
+        continue
+      }
+      s, err := ResolveSecret(c, value)
+      if err != nil {
+        errs = append(errs, fmt.Errorf("%s: %s", key, err.Error()))
+        continue
+      }
+      resolved[value] = s
+      addRedaction(s)
+    } else if ok && secret[key] {
+      addRedaction(value)
+    }
+  }
+
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  c.secrets = resolved
+  c.secretErrs = errs
+  c.changed()
+  if len(errs) > 0 {
+    return errs
+  }
+  return nil
+}
+
+// get is Viper.Get, with references replaced by their secrets.  c.mu must
+// be held.
+func (c *Config) get(key string) interface{} {
+  value := c.Viper.Get(key)
+  if ref, ok := value.(string); ok && strings.HasPrefix(ref, SecretScheme) {
+    if secret, ok := c.secrets[ref]; ok {
+      return secret
+    }
+  }
+  return value
+}
+
+// ResolveSecret returns the secret a reference points to.
+func ResolveSecret(c *Config, ref string) (string, error) {
+  parts := strings.SplitN(strings.TrimPrefix(ref, SecretScheme), "/", 2)
+  provider, ok := SecretProviders[parts[0]]
+  if !ok {
+    return "", fmt.Errorf("Unknown secret provider %q", parts[0])
+  }
+  if len(parts) < 2 || parts[1] == "" {
+    return "", errors.New("The secret reference has no path")
+  }
+  secret, err := provider(c, parts[1])
+  if err != nil {
+    return "", fmt.Errorf("Could not read %s: %s", ref, err.Error())
+  }
+  return secret, nil
+}
+
+// appKeys returns every key in AppConfig, and which of them are secret.
+func appKeys() ([]string, map[string]bool) {
+  var keys []string
+  secret := map[string]bool{}
+  eachField(reflect.ValueOf(&AppConfig{}).Elem(), func(field reflect.StructField, value reflect.Value) {
+    key := field.Tag.Get("config")
+    keys = append(keys, key)
+    secret[key] = field.Tag.Get("secret") == "true"
+  })
+  return keys, secret
+}
+
+var redactions = struct {
+  sync.RWMutex
+  values []string
+}{}
+
+// addRedaction has Redact hide secret.  Every secret is hidden, however
+// short, except the empty string, which is in every string.
+func addRedaction(secret string) {
+  if secret == "" {
+    return
+  }
+  redactions.Lock()
+  defer redactions.Unlock()
+  for _, value := range redactions.values {
+    if value == secret {
+      return
+    }
+  }
+  redactions.values = append(redactions.values, secret)
+}
+
+// Redact replaces every secret the config has loaded with Redacted.
+func Redact(s string) string {
+  redactions.RLock()
+  defer redactions.RUnlock()
+  for _, value := range redactions.values {
+    s = strings.Replace(s, value, Redacted, -1)
+  }
+  return s
+}
+
+// RedactSettings returns settings, such as from AllSettings, with the
+// secrets redacted, for dumping the config.
+func RedactSettings(settings map[string]interface{}) map[string]interface{} {
+  _, secret := appKeys()
+  redacted := make(map[string]interface{}, len(settings))
+  for key, value := range settings {
+    if secret[strings.ToUpper(key)] {
+      redacted[key] = Redacted
+    } else {
+      redacted[key] = redactValue(value)
+    }
+  }
+  return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+  switch v := value.(type) {
+  case string:
+    return Redact(v)
+  case map[string]interface{}:
+    return RedactSettings(v)
+  case []interface{}:
+    list := make([]interface{}, len(v))
+    for i, item := range v {
+      list[i] = redactValue(item)
+    }
+    return list
+  case []string:
+    list := make([]string, len(v))
+    for i, item := range v {
+      list[i] = Redact(item)
+    }
+    return list
+  }
+  return value
+}
+
+// RedactWriter redacts secrets from everything written to w.  main sends
+// the log through it.
+type RedactWriter struct {
+  W io.Writer
+}
+
+func (r RedactWriter) Write(p []byte) (int, error) {
+  if _, err := io.WriteString(r.W, Redact(string(p))); err != nil {
+    return 0, err
+  }
+  return len(p), nil
+}
@@ -5,6 +5,11 @@ github accepts the following commands:
 - update: Update an issue
 - close: Mark an issue as closed.
 - help: this text
+
+Keys can be given as key=value, key:value or --key value.  Quote values
+that contain '=', or words that look like keys: title="a = b".  A backslash
+escapes the next character.  Repeating a key adds to a list: labels=a labels=b.
+Unknown keys are an error.
 EOF
 
 new = <<EOF
@@ -19,12 +24,10 @@ Here are the supported keys:
 * assignee:   Optional.  Github user to assign the issue to
 * repo:       Optional. If not provided, the default repo will be used.
 
-Do not use the '=' character within a value.
-Unrecognized keys are ignored.
-
 Examples:
 * /github new title = Here is my title  labels = label1, label2
 * /github new title = Here is my title  labels = label1, label2 repo = my repo
+* /github new title:"Fix a = b" --labels bug --labels ui
 EOF
 
 get = <<EOF
@@ -34,9 +37,6 @@ Here are the supported keys:
 - number:      Required. Issue Number.  Either provided after "get", or as a KV
 - repo:        Optional. If not provided, the default repo will be used.
 
-Do not use the '=' character within a value.
-Unrecognized keys are ignored.
-
 Examples:
   /github get 152
   /github get 152 repo = my repo
@@ -56,9 +56,6 @@ Here are the supported keys:
 * assignee:   Optional.  Github user to assign the issue to
 * repo:       Optional. If not provided, the default repo will be used.
 
-Do not use the '=' character within a value.
-Unrecognized keys are ignored.
-
 Examples:
 * /github update 617 title = Here is my updated title
 * /github update 617 title = Here is my updated title repo = my repo
@@ -75,9 +72,6 @@ Here are the supported keys:
 - number:      Required. Issue Number.  Either provided after "get", or as a KV
 - repo:        Optional. If not provided, the default repo will be used.
 
-Do not use the '=' character within a value.
-Unrecognized keys are ignored.
-
 Examples:
   /github close 152
   /github close 152 repo = my repo
@@ -1,9 +1,23 @@
-
 if [ "$#" -ne 1 ]; then
     echo "You forgot to mention which env you want.  Prod?  Dev?"
 else
-  export SLACK_TOKEN=`sneaker $1 d /console/slack/token`
-  export GITHUB_TOKEN=`sneaker $1 d /console/github/token`
   export APP_ROOT=./dist
   export GOREST_CONFIG=$APP_ROOT/config
+  # config.yml is read first, then config.$1.yml over it, if there is one.
+  export GOREST_PROFILE=$1
+  # The tokens are sealed with ./seal, and opened by the app when it loads
+  # the config.  Only the key has to be kept out of the repo.  Until an env
+  # has been moved over (see Secrets in the README), its tokens still come
+  # from sneaker.
+  export SECRETS_KEY_FILE=${SECRETS_KEY_FILE:-$HOME/.devhub/$1.key}
+  if [ -f "$SECRETS_KEY_FILE" ] && [ -f $APP_ROOT/secrets/$1/slack_token ]; then
+    export SLACK_TOKEN=secret://aes/$APP_ROOT/secrets/$1/slack_token
+  else
+    export SLACK_TOKEN=`sneaker $1 d /console/slack/token`
+  fi
+  if [ -f "$SECRETS_KEY_FILE" ] && [ -f $APP_ROOT/secrets/$1/github_token ]; then
+    export GITHUB_TOKEN=secret://aes/$APP_ROOT/secrets/$1/github_token
+  else
+    export GITHUB_TOKEN=`sneaker $1 d /console/github/token`
+  fi
 fi
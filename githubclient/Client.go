@@ -0,0 +1,69 @@
+package githubclient
+
+import (
+  "github.com/google/go-github/github"
+)
+
+// IssuesService is the part of the GitHub issues API that the commands use,
+// including labels and milestones.  *github.IssuesService implements it.
+type IssuesService interface {
+  Get(owner string, repo string, number int) (*github.Issue, *github.Response, error)
+  Create(owner string, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error)
+  Edit(owner string, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error)
+  ListByRepo(owner string, repo string, opt *github.IssueListByRepoOptions) ([]*github.Issue, *github.Response, error)
+  ListComments(owner string, repo string, number int, opt *github.IssueListCommentsOptions) ([]*github.IssueComment, *github.Response, error)
+  CreateComment(owner string, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error)
+  ListLabels(owner string, repo string, opt *github.ListOptions) ([]*github.Label, *github.Response, error)
+  AddLabelsToIssue(owner string, repo string, number int, labels []string) ([]*github.Label, *github.Response, error)
+  RemoveLabelForIssue(owner string, repo string, number int, label string) (*github.Response, error)
+  ListMilestones(owner string, repo string, opt *github.MilestoneListOptions) ([]*github.Milestone, *github.Response, error)
+  ListAssignees(owner string, repo string, opt *github.ListOptions) ([]*github.User, *github.Response, error)
+}
+
+// PullRequestsService is the part of the GitHub pull requests API that the
+// commands use.  *github.PullRequestsService implements it.
+type PullRequestsService interface {
+  List(owner string, repo string, opt *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error)
+  Get(owner string, repo string, number int) (*github.PullRequest, *github.Response, error)
+  ListReviews(owner string, repo string, number int) ([]*github.PullRequestReview, *github.Response, error)
+  Merge(owner string, repo string, number int, commitMessage string, options *github.PullRequestOptions) (*github.PullRequestMergeResult, *github.Response, error)
+  RequestReviewers(owner string, repo string, number int, logins []string) (*github.PullRequest, *github.Response, error)
+}
+
+// RepositoriesService is used for commit statuses.
+type RepositoriesService interface {
+  GetCombinedStatus(owner string, repo string, ref string, opt *github.ListOptions) (*github.CombinedStatus, *github.Response, error)
+}
+
+// SearchService is used for issue and pull request search.
+type SearchService interface {
+  Issues(query string, opt *github.SearchOptions) (*github.IssuesSearchResult, *github.Response, error)
+}
+
+// UsersService is used to look up the authenticated user.
+type UsersService interface {
+  Get(user string) (*github.User, *github.Response, error)
+}
+
+// Client is the GitHub API, as the commands see it.  It has the same shape
+// as *github.Client, so calls read the same, but each service is an
+// interface and can be swapped for a fake in tests.  To test against HTTP
+// instead, see the githubtest package.
+type Client struct {
+  Issues IssuesService
+  PullRequests PullRequestsService
+  Repositories RepositoriesService
+  Search SearchService
+  Users UsersService
+}
+
+// Wrap returns a Client that calls through to c.
+func Wrap(c *github.Client) *Client {
+  return &Client{
+    Issues: c.Issues,
+    PullRequests: c.PullRequests,
+    Repositories: c.Repositories,
+    Search: c.Search,
+    Users: c.Users,
+  }
+}
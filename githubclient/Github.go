@@ -4,6 +4,10 @@ package githubclient
 import (
   "fmt"
   "errors"
+  "strings"
+  "context"
+  "net/url"
+  "net/http"
   "golang.org/x/oauth2"
   "github.com/google/go-github/github"
 )
@@ -11,6 +15,10 @@ import (
 const DefaultOwner = "GITHUB_DEFAULT_OWNER"
 const DefaultRepo = "GITHUB_DEFAULT_REPO"
 
+// ApiUrl is the config key for the GitHub API base url.  It is only needed
+// for GitHub Enterprise, or to point at a fake server in tests.
+const ApiUrl = "GITHUB_API_URL"
+
 type GithubClient struct {
     ClientId string
     ClientSecret string
@@ -39,6 +47,42 @@ func New(token string) *GithubClient {
   return &gh
 }
 
+// NewClient returns a Client that authenticates with token.  Every call made
+// with the client is bound to ctx, so cancelling ctx, or letting its deadline
+// pass, aborts any GitHub request that is still in flight.  baseUrl may be
+// empty, for api.github.com.
+func NewClient(ctx context.Context, token string, baseUrl string) (*Client, error) {
+  ts := oauth2.StaticTokenSource(
+    &oauth2.Token{AccessToken: token},
+  )
+  base := &http.Client{Transport: contextTransport{ctx, http.DefaultTransport}}
+  tc := oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, base), ts)
+  client := github.NewClient(tc)
+  if baseUrl != "" {
+    // go-github resolves paths against the base url, so it needs the slash.
+    if !strings.HasSuffix(baseUrl, "/") {
+      baseUrl += "/"
+    }
+    u, err := url.Parse(baseUrl)
+    if err != nil || !u.IsAbs() {
+      return nil, fmt.Errorf("Bad %s [%s]", ApiUrl, baseUrl)
+    }
+    client.BaseURL = u
+  }
+  return Wrap(client), nil
+}
+
+// contextTransport attaches ctx to each outgoing request.  go-github does not
+// take a context, so this is how deadlines and cancellation reach it.
+type contextTransport struct {
+  ctx context.Context
+  base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+  return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
 func (client GithubClient) AddTicket(owner string, repo string, ir *github.IssueRequest) (*github.Issue, error) {
 
   issue, _, err := client.client.Issues.Create(owner, repo, ir)
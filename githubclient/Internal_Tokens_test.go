@@ -0,0 +1,100 @@
+package githubclient
+
+import (
+  "time"
+  "testing"
+
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+// A base64 encoded 32 byte key.
+const testKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func TestEncryptedStore(t *testing.T) {
+  Convey("Given an EncryptedStore", t, func() {
+    blobs := NewMemoryBlobStore()
+    store, err := NewEncryptedStore(testKey, blobs)
+    So(err, ShouldBeNil)
+
+    Convey("An unknown user is not linked", func() {
+      _, err := store.Get("U1")
+      So(err, ShouldEqual, ErrNotLinked)
+    })
+
+    Convey("A stored account comes back, but is not stored in the clear", func() {
+      So(store.Put("U1", &LinkedAccount{Login: "steve", Token: "gho_secret"}), ShouldBeNil)
+      account, err := store.Get("U1")
+      So(err, ShouldBeNil)
+      So(*account, ShouldResemble, LinkedAccount{Login: "steve", Token: "gho_secret"})
+
+      sealed, _, _ := blobs.Get("U1")
+      So(string(sealed), ShouldNotContainSubstring, "gho_secret")
+
+      Convey("It can't be moved to another user", func() {
+        blobs.Put("U2", sealed)
+        _, err := store.Get("U2")
+        So(err, ShouldNotBeNil)
+      })
+      Convey("Delete unlinks it", func() {
+        So(store.Delete("U1"), ShouldBeNil)
+        _, err := store.Get("U1")
+        So(err, ShouldEqual, ErrNotLinked)
+      })
+    })
+  })
+
+  Convey("A key that is not 32 bytes is refused", t, func() {
+    _, err := NewEncryptedStore("c2hvcnQ=", NewMemoryBlobStore())
+    So(err, ShouldNotBeNil)
+  })
+}
+
+func TestState(t *testing.T) {
+  now := time.Unix(1500000000, 0)
+  login := LoginState{"U123", "n0nce", now.Add(StateTTL)}
+  Convey("Given a signed state", t, func() {
+    state := SignState("secret", login)
+
+    Convey("It verifies, and carries the user id and nonce", func() {
+      s, err := VerifyState("secret", state, now)
+      So(err, ShouldBeNil)
+      So(s, ShouldResemble, login)
+    })
+    Convey("It fails with another secret", func() {
+      _, err := VerifyState("other", state, now)
+      So(err, ShouldNotBeNil)
+    })
+    Convey("It fails once it has expired", func() {
+      _, err := VerifyState("secret", state, now.Add(StateTTL + time.Second))
+      So(err, ShouldNotBeNil)
+    })
+    Convey("It fails if it was changed", func() {
+      _, err := VerifyState("secret", "X" + state, now)
+      So(err, ShouldNotBeNil)
+    })
+    Convey("It can not be used as a session", func() {
+      _, err := VerifySession("secret", state, now)
+      So(err, ShouldNotBeNil)
+    })
+  })
+
+  Convey("Given a signed session", t, func() {
+    session := SignSession("secret", login)
+
+    Convey("It verifies, but not as a state", func() {
+      s, err := VerifySession("secret", session, now)
+      So(err, ShouldBeNil)
+      So(s, ShouldResemble, login)
+      _, err = VerifyState("secret", session, now)
+      So(err, ShouldNotBeNil)
+    })
+  })
+
+  Convey("Nonces should not repeat", t, func() {
+    a, err := NewNonce()
+    So(err, ShouldBeNil)
+    b, _ := NewNonce()
+    So(a, ShouldNotEqual, b)
+    So(len(a), ShouldEqual, 32)
+  })
+}
@@ -0,0 +1,152 @@
+package githubclient
+
+import (
+  "fmt"
+  "time"
+  "errors"
+  "strconv"
+  "strings"
+  "context"
+  "crypto/hmac"
+  "crypto/rand"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/base64"
+  "golang.org/x/oauth2"
+  oauthgithub "golang.org/x/oauth2/github"
+)
+
+// Config keys for linking Slack users to GitHub accounts.  Linking is on
+// when ClientId is set.
+const (
+  // The GitHub OAuth app.
+  ClientId = "GITHUB_CLIENT_ID"
+  ClientSecret = "GITHUB_CLIENT_SECRET"
+  // The public url of the /github/oauth route.  It must match the
+  // OAuth app's callback url.
+  RedirectUrl = "GITHUB_OAUTH_REDIRECT_URL"
+  // A base64 encoded 32 byte key used to encrypt stored tokens.
+  TokenKey = "GITHUB_TOKEN_KEY"
+  // If true, users that have not linked an account act as the bot, with
+  // GITHUB_TOKEN.
+  TokenFallback = "GITHUB_TOKEN_FALLBACK"
+)
+
+// OAuthScopes are the scopes asked for.  repo is needed for issues in
+// private repos.
+var OAuthScopes = []string{"repo", "read:user"}
+
+// StateTTL is how long a login link is good for, from /devhub login until
+// the account is linked.
+const StateTTL = 10 * time.Minute
+
+// OAuthConfig returns the config for the GitHub OAuth web flow.
+func OAuthConfig(clientId string, clientSecret string, redirectUrl string) *oauth2.Config {
+  return &oauth2.Config{
+    ClientID: clientId,
+    ClientSecret: clientSecret,
+    RedirectURL: redirectUrl,
+    Scopes: OAuthScopes,
+    Endpoint: oauthgithub.Endpoint,
+  }
+}
+
+// LoginState is carried through a login by the OAuth state, and by the
+// session cookie.  Nonce is stored when the link is made, and is used up
+// when the account is linked, so each link works once.
+type LoginState struct {
+  UserId string
+  Nonce string
+  Expires time.Time
+}
+
+// SessionCookie is set once the user has signed in with Slack, and is
+// checked by the GitHub callback.  It ties the end of the login to the
+// browser, and the Slack user, that started it.
+const SessionCookie = "devhub_login"
+
+// What a signature is for, so a state can not be used as a session.
+const (
+  statePurpose = "state"
+  sessionPurpose = "session"
+)
+
+// NewNonce returns a random nonce for a LoginState.
+func NewNonce() (string, error) {
+  b := make([]byte, 16)
+  if _, err := rand.Read(b); err != nil {
+    return "", err
+  }
+  return hex.EncodeToString(b), nil
+}
+
+// SignState makes the OAuth state for a login, signed with secret.
+func SignState(secret string, s LoginState) string {
+  return sign(secret, statePurpose, s)
+}
+
+// VerifyState checks a state made by SignState.
+func VerifyState(secret string, state string, now time.Time) (LoginState, error) {
+  return verify(secret, statePurpose, state, now)
+}
+
+// SignSession makes the value of the SessionCookie.
+func SignSession(secret string, s LoginState) string {
+  return sign(secret, sessionPurpose, s)
+}
+
+// VerifySession checks a SessionCookie made by SignSession.
+func VerifySession(secret string, session string, now time.Time) (LoginState, error) {
+  return verify(secret, sessionPurpose, session, now)
+}
+
+func sign(secret string, purpose string, s LoginState) string {
+  payload := base64.RawURLEncoding.EncodeToString(
+    []byte(fmt.Sprintf("%s|%s|%d", s.UserId, s.Nonce, s.Expires.Unix())))
+  return payload + "." + stateMAC(secret, purpose, payload)
+}
+
+func verify(secret string, purpose string, value string, now time.Time) (LoginState, error) {
+  invalid := fmt.Errorf("Invalid login %s", purpose)
+  parts := strings.SplitN(value, ".", 2)
+  if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(stateMAC(secret, purpose, parts[0]))) {
+    return LoginState{}, invalid
+  }
+  raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+  if err != nil {
+    return LoginState{}, invalid
+  }
+  fields := strings.SplitN(string(raw), "|", 3)
+  if len(fields) != 3 || fields[0] == "" || fields[1] == "" {
+    return LoginState{}, invalid
+  }
+  expires, err := strconv.ParseInt(fields[2], 10, 64)
+  if err != nil {
+    return LoginState{}, invalid
+  }
+  if now.Unix() > expires {
+    return LoginState{}, errors.New("The login link has expired.  Run /devhub login again.")
+  }
+  return LoginState{fields[0], fields[1], time.Unix(expires, 0)}, nil
+}
+
+func stateMAC(secret string, purpose string, payload string) string {
+  mac := hmac.New(sha256.New, []byte(secret))
+  mac.Write([]byte(purpose + "|" + payload))
+  return hex.EncodeToString(mac.Sum(nil))
+}
+
+type tokenKey struct{}
+
+// WithToken returns a copy of ctx that carries a GitHub token.  Clients
+// made by NewGithubClient with the returned context use it, instead of
+// GITHUB_TOKEN.
+func WithToken(ctx context.Context, token string) context.Context {
+  return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// TokenFromContext returns the token set by WithToken, if there is one.
+func TokenFromContext(ctx context.Context) (string, bool) {
+  token, ok := ctx.Value(tokenKey{}).(string)
+  return token, ok && token != ""
+}
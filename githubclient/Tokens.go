@@ -0,0 +1,137 @@
+package githubclient
+
+import (
+  "io"
+  "sync"
+  "errors"
+  "crypto/aes"
+  "crypto/rand"
+  "crypto/cipher"
+  "encoding/json"
+  "encoding/base64"
+)
+
+// ErrNotLinked is returned by a TokenStore for a Slack user that has not
+// linked a GitHub account.
+var ErrNotLinked = errors.New("No GitHub account is linked")
+
+// LinkedAccount is the GitHub account a Slack user linked with /devhub login.
+type LinkedAccount struct {
+  Login string `json:"login"`
+  Token string `json:"token"`
+}
+
+// TokenStore keeps the LinkedAccount of each Slack user, keyed by user id.
+type TokenStore interface {
+  Get(userId string) (*LinkedAccount, error)
+  Put(userId string, account *LinkedAccount) error
+  Delete(userId string) error
+}
+
+// BlobStore is where an EncryptedStore keeps its sealed accounts.  Get
+// returns ok false for a missing key.
+type BlobStore interface {
+  Get(key string) (value []byte, ok bool, err error)
+  Put(key string, value []byte) error
+  Delete(key string) error
+}
+
+// EncryptedStore is a TokenStore that seals each account with AES-GCM
+// before it reaches the BlobStore, so the blobs are safe to keep anywhere.
+type EncryptedStore struct {
+  aead cipher.AEAD
+  blobs BlobStore
+}
+
+// NewEncryptedStore returns an EncryptedStore.  key is a base64 encoded
+// 32 byte AES-256 key, such as the output of "openssl rand -base64 32".
+func NewEncryptedStore(key string, blobs BlobStore) (*EncryptedStore, error) {
+  raw, err := base64.StdEncoding.DecodeString(key)
+  if err != nil {
+    return nil, errors.New("The token key must be base64 encoded")
+  }
+  if len(raw) != 32 {
+    return nil, errors.New("The token key must be 32 bytes")
+  }
+  block, err := aes.NewCipher(raw)
+  if err != nil {
+    return nil, err
+  }
+  aead, err := cipher.NewGCM(block)
+  if err != nil {
+    return nil, err
+  }
+  return &EncryptedStore{aead, blobs}, nil
+}
+
+func (s *EncryptedStore) Get(userId string) (*LinkedAccount, error) {
+  sealed, ok, err := s.blobs.Get(userId)
+  if err != nil {
+    return nil, err
+  }
+  if !ok {
+    return nil, ErrNotLinked
+  }
+  size := s.aead.NonceSize()
+  if len(sealed) < size {
+    return nil, errors.New("Stored token is corrupt")
+  }
+  // The user id is the additional data, so a blob can't be moved to
+  // another user.
+  plain, err := s.aead.Open(nil, sealed[:size], sealed[size:], []byte(userId))
+  if err != nil {
+    return nil, errors.New("Stored token could not be decrypted.  Was the token key changed?")
+  }
+  account := &LinkedAccount{}
+  if err := json.Unmarshal(plain, account); err != nil {
+    return nil, err
+  }
+  return account, nil
+}
+
+func (s *EncryptedStore) Put(userId string, account *LinkedAccount) error {
+  plain, err := json.Marshal(account)
+  if err != nil {
+    return err
+  }
+  nonce := make([]byte, s.aead.NonceSize())
+  if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+    return err
+  }
+  return s.blobs.Put(userId, s.aead.Seal(nonce, nonce, plain, []byte(userId)))
+}
+
+func (s *EncryptedStore) Delete(userId string) error {
+  return s.blobs.Delete(userId)
+}
+
+// MemoryBlobStore is a BlobStore that forgets everything on restart.
+type MemoryBlobStore struct {
+  mu sync.Mutex
+  blobs map[string][]byte
+}
+
+func NewMemoryBlobStore() *MemoryBlobStore {
+  return &MemoryBlobStore{blobs: make(map[string][]byte)}
+}
+
+func (m *MemoryBlobStore) Get(key string) ([]byte, bool, error) {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  value, ok := m.blobs[key]
+  return value, ok, nil
+}
+
+func (m *MemoryBlobStore) Put(key string, value []byte) error {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  m.blobs[key] = value
+  return nil
+}
+
+func (m *MemoryBlobStore) Delete(key string) error {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  delete(m.blobs, key)
+  return nil
+}
@@ -0,0 +1,895 @@
+// Package githubtest is an in-process fake of the parts of the GitHub API
+// that the commands use.  Issues, pull requests, labels and so on are kept
+// in memory, per repo.  Point GITHUB_API_URL (githubclient.ApiUrl) at the
+// Server's URL to use it.
+package githubtest
+
+import (
+  "fmt"
+  "sort"
+  "sync"
+  "path"
+  "time"
+  "strconv"
+  "strings"
+  "context"
+  "net/http"
+  "encoding/json"
+  "net/http/httptest"
+  "github.com/gorilla/mux"
+  "github.com/google/go-github/github"
+  "github.com/confyrm/gorest/githubclient"
+)
+
+// DefaultPerPage is GitHub's page size when per_page is not given.
+const DefaultPerPage = 30
+
+// Server is a fake GitHub API.  Repos that have not been set up with Repo
+// are 404s, like a private repo would be.
+type Server struct {
+  *httptest.Server
+  // Login is the authenticated user.  New issues, comments and merges are
+  // made as this user.
+  Login string
+
+  mu sync.Mutex
+  repos map[string]*Repo
+  failures []failure
+  requests []string
+}
+
+type failure struct {
+  method string
+  pattern string
+  status int
+}
+
+// Repo is the state of one fake repo.  Use the Add methods to set it up,
+// and read the fields to check what a command did.
+type Repo struct {
+  Owner string
+  Name string
+  Issues map[int]*github.Issue
+  Comments map[int][]*github.IssueComment
+  PullRequests map[int]*github.PullRequest
+  Reviews map[int][]*github.PullRequestReview
+  // Requested reviewers, by pull request number.
+  Reviewers map[int][]string
+  // Combined statuses, by commit sha.
+  Statuses map[string]*github.CombinedStatus
+  Labels []*github.Label
+  Milestones []*github.Milestone
+  Assignees []*github.User
+
+  s *Server
+  next int
+}
+
+// NewServer starts a fake GitHub.  Close it when done.
+func NewServer() *Server {
+  s := &Server{Login: "devhub-bot", repos: make(map[string]*Repo)}
+  s.Server = httptest.NewServer(s.router())
+  return s
+}
+
+// Client returns a client for the server.
+func (s *Server) Client(ctx context.Context) *githubclient.Client {
+  client, err := githubclient.NewClient(ctx, "test-token", s.URL)
+  if err != nil {
+    panic(err)
+  }
+  return client
+}
+
+// Repo returns the fake owner/name repo, creating it if needed.
+func (s *Server) Repo(owner string, name string) *Repo {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  key := owner + "/" + name
+  r, ok := s.repos[key]
+  if !ok {
+    r = &Repo{
+      Owner: owner,
+      Name: name,
+      Issues: make(map[int]*github.Issue),
+      Comments: make(map[int][]*github.IssueComment),
+      PullRequests: make(map[int]*github.PullRequest),
+      Reviews: make(map[int][]*github.PullRequestReview),
+      Reviewers: make(map[int][]string),
+      Statuses: make(map[string]*github.CombinedStatus),
+      s: s,
+    }
+    s.repos[key] = r
+  }
+  return r
+}
+
+// Fail makes requests that match method and pattern fail with status.
+// pattern is matched against the url path with path.Match, as in
+// "/repos/o/r/issues/*".  An empty method matches any method.
+func (s *Server) Fail(method string, pattern string, status int) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.failures = append(s.failures, failure{method, pattern, status})
+}
+
+// Requests returns the requests made so far, as "METHOD /path".
+func (s *Server) Requests() []string {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return append([]string{}, s.requests...)
+}
+
+// AddIssue adds an open issue, made by the server's Login.
+func (r *Repo) AddIssue(title string) *github.Issue {
+  r.s.mu.Lock()
+  defer r.s.mu.Unlock()
+  return r.addIssue(title, "", r.s.Login)
+}
+
+// AddPullRequest adds an open, mergeable pull request whose head is sha.
+// Like on GitHub, it is also an issue, with the same number.
+func (r *Repo) AddPullRequest(title string, sha string) *github.PullRequest {
+  r.s.mu.Lock()
+  defer r.s.mu.Unlock()
+  issue := r.addIssue(title, "", r.s.Login)
+  number := *issue.Number
+  htmlUrl := fmt.Sprintf("https://github.com/%s/%s/pull/%d", r.Owner, r.Name, number)
+  issue.PullRequestLinks = &github.PullRequestLinks{HTMLURL: github.String(htmlUrl)}
+  pr := &github.PullRequest{
+    Number: github.Int(number),
+    State: github.String("open"),
+    Title: github.String(title),
+    User: user(r.s.Login),
+    Merged: github.Bool(false),
+    Mergeable: github.Bool(true),
+    HTMLURL: github.String(htmlUrl),
+    CreatedAt: issue.CreatedAt,
+    Head: &github.PullRequestBranch{Ref: github.String("feature"), SHA: github.String(sha)},
+    Base: &github.PullRequestBranch{Ref: github.String("master")},
+  }
+  r.PullRequests[number] = pr
+  return pr
+}
+
+// AddLabel adds a label that issues can be given.
+func (r *Repo) AddLabel(name string) *github.Label {
+  r.s.mu.Lock()
+  defer r.s.mu.Unlock()
+  return r.label(name)
+}
+
+// AddMilestone adds an open milestone.
+func (r *Repo) AddMilestone(title string) *github.Milestone {
+  r.s.mu.Lock()
+  defer r.s.mu.Unlock()
+  m := &github.Milestone{Number: github.Int(len(r.Milestones) + 1), State: github.String("open"), Title: github.String(title)}
+  r.Milestones = append(r.Milestones, m)
+  return m
+}
+
+// AddAssignee adds a user that issues can be assigned to.
+func (r *Repo) AddAssignee(login string) *github.User {
+  r.s.mu.Lock()
+  defer r.s.mu.Unlock()
+  u := user(login)
+  r.Assignees = append(r.Assignees, u)
+  return u
+}
+
+// AddComment adds a comment on an issue.
+func (r *Repo) AddComment(number int, login string, body string) *github.IssueComment {
+  r.s.mu.Lock()
+  defer r.s.mu.Unlock()
+  return r.addComment(number, login, body)
+}
+
+// AddReview adds a review, such as "APPROVED", of a pull request.
+func (r *Repo) AddReview(number int, login string, state string) *github.PullRequestReview {
+  r.s.mu.Lock()
+  defer r.s.mu.Unlock()
+  review := &github.PullRequestReview{ID: github.Int(len(r.Reviews[number]) + 1), User: user(login), State: github.String(state)}
+  r.Reviews[number] = append(r.Reviews[number], review)
+  return review
+}
+
+// SetStatus sets the status of a commit.  Each context is a check with
+// state, and the combined state is state too.
+func (r *Repo) SetStatus(sha string, state string, contexts ...string) {
+  r.s.mu.Lock()
+  defer r.s.mu.Unlock()
+  status := &github.CombinedStatus{State: github.String(state), SHA: github.String(sha), TotalCount: github.Int(len(contexts))}
+  for _, c := range contexts {
+    status.Statuses = append(status.Statuses, github.RepoStatus{State: github.String(state), Context: github.String(c)})
+  }
+  r.Statuses[sha] = status
+}
+
+func (r *Repo) addIssue(title string, body string, login string) *github.Issue {
+  r.next++
+  now := time.Now().UTC()
+  issue := &github.Issue{
+    Number: github.Int(r.next),
+    State: github.String("open"),
+    Title: github.String(title),
+    User: user(login),
+    Comments: github.Int(0),
+    CreatedAt: &now,
+    UpdatedAt: &now,
+    HTMLURL: github.String(fmt.Sprintf("https://github.com/%s/%s/issues/%d", r.Owner, r.Name, r.next)),
+  }
+  if body != "" {
+    issue.Body = github.String(body)
+  }
+  r.Issues[r.next] = issue
+  return issue
+}
+
+func (r *Repo) addComment(number int, login string, body string) *github.IssueComment {
+  id := 0
+  for _, comments := range r.Comments {
+    id += len(comments)
+  }
+  now := time.Now().UTC()
+  comment := &github.IssueComment{
+    ID: github.Int(id + 1),
+    Body: github.String(body),
+    User: user(login),
+    CreatedAt: &now,
+    HTMLURL: github.String(fmt.Sprintf("https://github.com/%s/%s/issues/%d#issuecomment-%d", r.Owner, r.Name, number, id + 1)),
+  }
+  r.Comments[number] = append(r.Comments[number], comment)
+  if issue, ok := r.Issues[number]; ok {
+    issue.Comments = github.Int(len(r.Comments[number]))
+  }
+  return comment
+}
+
+// label returns the repo's label, adding it if needed.  GitHub makes
+// labels that an issue is given but the repo does not have yet.
+func (r *Repo) label(name string) *github.Label {
+  for _, l := range r.Labels {
+    if strings.EqualFold(*l.Name, name) {
+      return l
+    }
+  }
+  l := &github.Label{Name: github.String(name), Color: github.String("ededed")}
+  r.Labels = append(r.Labels, l)
+  return l
+}
+
+func (r *Repo) milestone(number int) *github.Milestone {
+  for _, m := range r.Milestones {
+    if *m.Number == number {
+      return m
+    }
+  }
+  return nil
+}
+
+func user(login string) *github.User {
+  return &github.User{
+    Login: github.String(login),
+    HTMLURL: github.String("https://github.com/" + login),
+  }
+}
+
+func (s *Server) router() http.Handler {
+  m := mux.NewRouter()
+  m.HandleFunc("/user", s.getUser).Methods("GET")
+  m.HandleFunc("/users/{login}", s.getUser).Methods("GET")
+  m.HandleFunc("/search/issues", s.searchIssues).Methods("GET")
+
+  r := m.PathPrefix("/repos/{owner}/{repo}").Subrouter()
+  r.HandleFunc("/issues", s.repo(listIssues)).Methods("GET")
+  r.HandleFunc("/issues", s.repo(createIssue)).Methods("POST")
+  r.HandleFunc("/issues/{number:[0-9]+}", s.issue(getIssue)).Methods("GET")
+  r.HandleFunc("/issues/{number:[0-9]+}", s.issue(editIssue)).Methods("PATCH")
+  r.HandleFunc("/issues/{number:[0-9]+}/comments", s.issue(listComments)).Methods("GET")
+  r.HandleFunc("/issues/{number:[0-9]+}/comments", s.issue(createComment)).Methods("POST")
+  r.HandleFunc("/issues/{number:[0-9]+}/labels", s.issue(addLabels)).Methods("POST")
+  r.HandleFunc("/issues/{number:[0-9]+}/labels/{label}", s.issue(removeLabel)).Methods("DELETE")
+  r.HandleFunc("/labels", s.repo(listLabels)).Methods("GET")
+  r.HandleFunc("/milestones", s.repo(listMilestones)).Methods("GET")
+  r.HandleFunc("/assignees", s.repo(listAssignees)).Methods("GET")
+  r.HandleFunc("/pulls", s.repo(listPullRequests)).Methods("GET")
+  r.HandleFunc("/pulls/{number:[0-9]+}", s.pull(getPullRequest)).Methods("GET")
+  r.HandleFunc("/pulls/{number:[0-9]+}/reviews", s.pull(listReviews)).Methods("GET")
+  r.HandleFunc("/pulls/{number:[0-9]+}/requested_reviewers", s.pull(requestReviewers)).Methods("POST")
+  r.HandleFunc("/pulls/{number:[0-9]+}/merge", s.pull(mergePullRequest)).Methods("PUT")
+  r.HandleFunc("/commits/{ref}/status", s.repo(getCombinedStatus)).Methods("GET")
+
+  m.NotFoundHandler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+    writeError(rw, http.StatusNotFound, "Not Found")
+  })
+  return s.record(m)
+}
+
+// record logs each request, and fails the ones that were set up with Fail.
+// The lock is held for the whole request, so handlers can use the repos.
+func (s *Server) record(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.requests = append(s.requests, req.Method + " " + req.URL.Path)
+    for _, f := range s.failures {
+      if f.method != "" && f.method != req.Method {
+        continue
+      }
+      if ok, _ := path.Match(f.pattern, req.URL.Path); ok {
+        writeError(rw, f.status, http.StatusText(f.status))
+        return
+      }
+    }
+    next.ServeHTTP(rw, req)
+  })
+}
+
+type repoHandler func(s *Server, r *Repo, rw http.ResponseWriter, req *http.Request)
+type issueHandler func(s *Server, r *Repo, issue *github.Issue, rw http.ResponseWriter, req *http.Request)
+type pullHandler func(s *Server, r *Repo, pr *github.PullRequest, rw http.ResponseWriter, req *http.Request)
+
+func (s *Server) repo(h repoHandler) http.HandlerFunc {
+  return func(rw http.ResponseWriter, req *http.Request) {
+    vars := mux.Vars(req)
+    r, ok := s.repos[vars["owner"] + "/" + vars["repo"]]
+    if !ok {
+      writeError(rw, http.StatusNotFound, "Not Found")
+      return
+    }
+    h(s, r, rw, req)
+  }
+}
+
+func (s *Server) issue(h issueHandler) http.HandlerFunc {
+  return s.repo(func(s *Server, r *Repo, rw http.ResponseWriter, req *http.Request) {
+    number, _ := strconv.Atoi(mux.Vars(req)["number"])
+    issue, ok := r.Issues[number]
+    if !ok {
+      writeError(rw, http.StatusNotFound, "Not Found")
+      return
+    }
+    h(s, r, issue, rw, req)
+  })
+}
+
+func (s *Server) pull(h pullHandler) http.HandlerFunc {
+  return s.repo(func(s *Server, r *Repo, rw http.ResponseWriter, req *http.Request) {
+    number, _ := strconv.Atoi(mux.Vars(req)["number"])
+    pr, ok := r.PullRequests[number]
+    if !ok {
+      writeError(rw, http.StatusNotFound, "Not Found")
+      return
+    }
+    h(s, r, pr, rw, req)
+  })
+}
+
+func (s *Server) getUser(rw http.ResponseWriter, req *http.Request) {
+  login, ok := mux.Vars(req)["login"]
+  if !ok {
+    login = s.Login
+  }
+  writeJSON(rw, http.StatusOK, user(login))
+}
+
+func getIssue(s *Server, r *Repo, issue *github.Issue, rw http.ResponseWriter, req *http.Request) {
+  writeJSON(rw, http.StatusOK, issue)
+}
+
+func createIssue(s *Server, r *Repo, rw http.ResponseWriter, req *http.Request) {
+  input := &github.IssueRequest{}
+  if err := json.NewDecoder(req.Body).Decode(input); err != nil {
+    writeError(rw, http.StatusBadRequest, "Problems parsing JSON")
+    return
+  }
+  if input.Title == nil || *input.Title == "" {
+    writeError(rw, http.StatusUnprocessableEntity, "Validation Failed")
+    return
+  }
+  if input.Milestone != nil && r.milestone(*input.Milestone) == nil {
+    writeError(rw, http.StatusUnprocessableEntity, "Validation Failed")
+    return
+  }
+  body := ""
+  if input.Body != nil {
+    body = *input.Body
+  }
+  issue := r.addIssue(*input.Title, body, s.Login)
+  input.Title = nil
+  input.Body = nil
+  applyIssueRequest(r, issue, input)
+  writeJSON(rw, http.StatusCreated, issue)
+}
+
+func editIssue(s *Server, r *Repo, issue *github.Issue, rw http.ResponseWriter, req *http.Request) {
+  input := &github.IssueRequest{}
+  if err := json.NewDecoder(req.Body).Decode(input); err != nil {
+    writeError(rw, http.StatusBadRequest, "Problems parsing JSON")
+    return
+  }
+  if input.State != nil && *input.State != "open" && *input.State != "closed" {
+    writeError(rw, http.StatusUnprocessableEntity, "Validation Failed")
+    return
+  }
+  if input.Milestone != nil && r.milestone(*input.Milestone) == nil {
+    writeError(rw, http.StatusUnprocessableEntity, "Validation Failed")
+    return
+  }
+  applyIssueRequest(r, issue, input)
+  writeJSON(rw, http.StatusOK, issue)
+}
+
+func applyIssueRequest(r *Repo, issue *github.Issue, input *github.IssueRequest) {
+  now := time.Now().UTC()
+  issue.UpdatedAt = &now
+  if input.Title != nil {
+    issue.Title = input.Title
+  }
+  if input.Body != nil {
+    issue.Body = input.Body
+  }
+  if input.State != nil {
+    issue.State = input.State
+    if *input.State == "closed" {
+      issue.ClosedAt = &now
+    } else {
+      issue.ClosedAt = nil
+    }
+  }
+  if input.Labels != nil {
+    issue.Labels = nil
+    for _, name := range *input.Labels {
+      issue.Labels = append(issue.Labels, *r.label(name))
+    }
+  }
+  if input.Assignee != nil {
+    if *input.Assignee == "" {
+      issue.Assignee = nil
+    } else {
+      issue.Assignee = user(*input.Assignee)
+    }
+  }
+  if input.Milestone != nil {
+    issue.Milestone = r.milestone(*input.Milestone)
+  }
+}
+
+func listIssues(s *Server, r *Repo, rw http.ResponseWriter, req *http.Request) {
+  q := req.URL.Query()
+  state := q.Get("state")
+  if state == "" {
+    state = "open"
+  }
+  issues := []*github.Issue{}
+  for _, issue := range r.Issues {
+    if state != "all" && *issue.State != state {
+      continue
+    }
+    if !matchUser(q.Get("assignee"), issue.Assignee) || !matchUser(q.Get("creator"), issue.User) {
+      continue
+    }
+    if !matchMilestone(q.Get("milestone"), issue.Milestone) || !hasLabels(issue, q.Get("labels")) {
+      continue
+    }
+    issues = append(issues, issue)
+  }
+  sortIssues(issues)
+  writePage(rw, req, len(issues), func(from int, to int) interface{} { return issues[from:to] })
+}
+
+func listComments(s *Server, r *Repo, issue *github.Issue, rw http.ResponseWriter, req *http.Request) {
+  comments := r.Comments[*issue.Number]
+  writePage(rw, req, len(comments), func(from int, to int) interface{} { return comments[from:to] })
+}
+
+func createComment(s *Server, r *Repo, issue *github.Issue, rw http.ResponseWriter, req *http.Request) {
+  input := &github.IssueComment{}
+  if err := json.NewDecoder(req.Body).Decode(input); err != nil {
+    writeError(rw, http.StatusBadRequest, "Problems parsing JSON")
+    return
+  }
+  if input.Body == nil || *input.Body == "" {
+    writeError(rw, http.StatusUnprocessableEntity, "Validation Failed")
+    return
+  }
+  writeJSON(rw, http.StatusCreated, r.addComment(*issue.Number, s.Login, *input.Body))
+}
+
+func addLabels(s *Server, r *Repo, issue *github.Issue, rw http.ResponseWriter, req *http.Request) {
+  names := []string{}
+  if err := json.NewDecoder(req.Body).Decode(&names); err != nil {
+    writeError(rw, http.StatusBadRequest, "Problems parsing JSON")
+    return
+  }
+  for _, name := range names {
+    if !hasLabels(issue, name) {
+      issue.Labels = append(issue.Labels, *r.label(name))
+    }
+  }
+  writeJSON(rw, http.StatusOK, issue.Labels)
+}
+
+func removeLabel(s *Server, r *Repo, issue *github.Issue, rw http.ResponseWriter, req *http.Request) {
+  name := mux.Vars(req)["label"]
+  for i, l := range issue.Labels {
+    if strings.EqualFold(*l.Name, name) {
+      issue.Labels = append(issue.Labels[:i], issue.Labels[i+1:]...)
+      writeJSON(rw, http.StatusOK, issue.Labels)
+      return
+    }
+  }
+  writeError(rw, http.StatusNotFound, "Label does not exist")
+}
+
+func listLabels(s *Server, r *Repo, rw http.ResponseWriter, req *http.Request) {
+  writePage(rw, req, len(r.Labels), func(from int, to int) interface{} { return r.Labels[from:to] })
+}
+
+func listMilestones(s *Server, r *Repo, rw http.ResponseWriter, req *http.Request) {
+  state := req.URL.Query().Get("state")
+  if state == "" {
+    state = "open"
+  }
+  milestones := []*github.Milestone{}
+  for _, m := range r.Milestones {
+    if state == "all" || *m.State == state {
+      milestones = append(milestones, m)
+    }
+  }
+  writePage(rw, req, len(milestones), func(from int, to int) interface{} { return milestones[from:to] })
+}
+
+func listAssignees(s *Server, r *Repo, rw http.ResponseWriter, req *http.Request) {
+  writePage(rw, req, len(r.Assignees), func(from int, to int) interface{} { return r.Assignees[from:to] })
+}
+
+func listPullRequests(s *Server, r *Repo, rw http.ResponseWriter, req *http.Request) {
+  q := req.URL.Query()
+  state := q.Get("state")
+  if state == "" {
+    state = "open"
+  }
+  pulls := []*github.PullRequest{}
+  for _, pr := range r.PullRequests {
+    if state != "all" && *pr.State != state {
+      continue
+    }
+    if base := q.Get("base"); base != "" && (pr.Base == nil || pr.Base.Ref == nil || *pr.Base.Ref != base) {
+      continue
+    }
+    pulls = append(pulls, pr)
+  }
+  sort.Sort(pullsByNumber(pulls))
+  writePage(rw, req, len(pulls), func(from int, to int) interface{} { return pulls[from:to] })
+}
+
+func getPullRequest(s *Server, r *Repo, pr *github.PullRequest, rw http.ResponseWriter, req *http.Request) {
+  writeJSON(rw, http.StatusOK, pr)
+}
+
+func listReviews(s *Server, r *Repo, pr *github.PullRequest, rw http.ResponseWriter, req *http.Request) {
+  reviews := r.Reviews[*pr.Number]
+  if reviews == nil {
+    reviews = []*github.PullRequestReview{}
+  }
+  writeJSON(rw, http.StatusOK, reviews)
+}
+
+func requestReviewers(s *Server, r *Repo, pr *github.PullRequest, rw http.ResponseWriter, req *http.Request) {
+  input := struct {
+    Reviewers []string `json:"reviewers"`
+  }{}
+  if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+    writeError(rw, http.StatusBadRequest, "Problems parsing JSON")
+    return
+  }
+  if len(input.Reviewers) == 0 {
+    writeError(rw, http.StatusUnprocessableEntity, "Validation Failed")
+    return
+  }
+  // Only collaborators can be asked for a review.
+  for _, login := range input.Reviewers {
+    found := false
+    for _, a := range r.Assignees {
+      found = found || strings.EqualFold(*a.Login, login)
+    }
+    if !found {
+      writeError(rw, http.StatusUnprocessableEntity, "Reviews may only be requested from collaborators")
+      return
+    }
+  }
+  r.Reviewers[*pr.Number] = append(r.Reviewers[*pr.Number], input.Reviewers...)
+  writeJSON(rw, http.StatusCreated, pr)
+}
+
+func mergePullRequest(s *Server, r *Repo, pr *github.PullRequest, rw http.ResponseWriter, req *http.Request) {
+  var input struct {
+    SHA string `json:"sha"`
+  }
+  if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+    writeError(rw, http.StatusBadRequest, err.Error())
+    return
+  }
+  if *pr.State != "open" || (pr.Mergeable != nil && !*pr.Mergeable) {
+    writeError(rw, http.StatusMethodNotAllowed, "Pull Request is not mergeable")
+    return
+  }
+  if input.SHA != "" && input.SHA != *pr.Head.SHA {
+    writeError(rw, http.StatusConflict, "Head branch was modified. Review and try the merge again.")
+    return
+  }
+  now := time.Now().UTC()
+  pr.State = github.String("closed")
+  pr.Merged = github.Bool(true)
+  pr.MergedAt = &now
+  pr.ClosedAt = &now
+  pr.MergedBy = user(s.Login)
+  if issue, ok := r.Issues[*pr.Number]; ok {
+    issue.State = github.String("closed")
+    issue.ClosedAt = &now
+  }
+  writeJSON(rw, http.StatusOK, &github.PullRequestMergeResult{
+    SHA: github.String(fmt.Sprintf("merge%d", *pr.Number)),
+    Merged: github.Bool(true),
+    Message: github.String("Pull Request successfully merged"),
+  })
+}
+
+func getCombinedStatus(s *Server, r *Repo, rw http.ResponseWriter, req *http.Request) {
+  ref := mux.Vars(req)["ref"]
+  status, ok := r.Statuses[ref]
+  if !ok {
+    // A commit with no statuses is pending.
+    status = &github.CombinedStatus{State: github.String("pending"), SHA: github.String(ref), TotalCount: github.Int(0)}
+  }
+  writeJSON(rw, http.StatusOK, status)
+}
+
+// searchIssues supports the qualifiers the commands use: repo, is, state,
+// label, author, assignee, milestone and no.  Anything else is text that
+// must be in the title or body.
+func (s *Server) searchIssues(rw http.ResponseWriter, req *http.Request) {
+  terms := searchTerms(req.URL.Query().Get("q"))
+  var r *Repo
+  for _, t := range terms {
+    if t.key == "repo" {
+      r = s.repos[t.value]
+    }
+  }
+  if r == nil {
+    writeError(rw, http.StatusUnprocessableEntity, "Validation Failed")
+    return
+  }
+
+  issues := []*github.Issue{}
+  for _, issue := range r.Issues {
+    if matchSearch(issue, terms) {
+      issues = append(issues, issue)
+    }
+  }
+  sortIssues(issues)
+  from, to := pageBounds(req, len(issues))
+  result := &github.IssuesSearchResult{Total: github.Int(len(issues)), Issues: []github.Issue{}}
+  for _, issue := range issues[from:to] {
+    result.Issues = append(result.Issues, *issue)
+  }
+  writeJSON(rw, http.StatusOK, result)
+}
+
+type searchTerm struct {
+  key string
+  value string
+}
+
+// searchTerms splits a query on spaces, except inside double quotes.
+func searchTerms(q string) []searchTerm {
+  terms := []searchTerm{}
+  var word []rune
+  quoted := false
+  flush := func() {
+    if len(word) == 0 {
+      return
+    }
+    w := string(word)
+    word = nil
+    if i := strings.Index(w, ":"); i > 0 {
+      terms = append(terms, searchTerm{w[:i], strings.Trim(w[i+1:], `"`)})
+    } else {
+      terms = append(terms, searchTerm{"", strings.Trim(w, `"`)})
+    }
+  }
+  for _, c := range q {
+    switch {
+    case c == '"':
+      quoted = !quoted
+      word = append(word, c)
+    case c == ' ' && !quoted:
+      flush()
+    default:
+      word = append(word, c)
+    }
+  }
+  flush()
+  return terms
+}
+
+func matchSearch(issue *github.Issue, terms []searchTerm) bool {
+  for _, t := range terms {
+    switch t.key {
+    case "repo":
+    case "is":
+      switch t.value {
+      case "issue":
+        if issue.PullRequestLinks != nil {
+          return false
+        }
+      case "pr":
+        if issue.PullRequestLinks == nil {
+          return false
+        }
+      case "open", "closed":
+        if *issue.State != t.value {
+          return false
+        }
+      }
+    case "state":
+      if *issue.State != t.value {
+        return false
+      }
+    case "label":
+      if !hasLabels(issue, t.value) {
+        return false
+      }
+    case "author":
+      if !matchUser(t.value, issue.User) {
+        return false
+      }
+    case "assignee":
+      if !matchUser(t.value, issue.Assignee) {
+        return false
+      }
+    case "milestone":
+      if issue.Milestone == nil || !strings.EqualFold(*issue.Milestone.Title, t.value) {
+        return false
+      }
+    case "no":
+      if (t.value == "assignee" && issue.Assignee != nil) || (t.value == "milestone" && issue.Milestone != nil) ||
+        (t.value == "label" && len(issue.Labels) > 0) {
+        return false
+      }
+    default:
+      text := strings.ToLower(*issue.Title)
+      if issue.Body != nil {
+        text += " " + strings.ToLower(*issue.Body)
+      }
+      if !strings.Contains(text, strings.ToLower(t.value)) {
+        return false
+      }
+    }
+  }
+  return true
+}
+
+func matchUser(filter string, u *github.User) bool {
+  switch filter {
+  case "":
+    return true
+  case "none":
+    return u == nil
+  case "*":
+    return u != nil
+  }
+  return u != nil && strings.EqualFold(*u.Login, filter)
+}
+
+func matchMilestone(filter string, m *github.Milestone) bool {
+  switch filter {
+  case "":
+    return true
+  case "none":
+    return m == nil
+  case "*":
+    return m != nil
+  }
+  return m != nil && strconv.Itoa(*m.Number) == filter
+}
+
+// hasLabels is true if the issue has all of the comma separated labels.
+func hasLabels(issue *github.Issue, labels string) bool {
+  if labels == "" {
+    return true
+  }
+  for _, name := range strings.Split(labels, ",") {
+    found := false
+    for _, l := range issue.Labels {
+      found = found || strings.EqualFold(*l.Name, strings.TrimSpace(name))
+    }
+    if !found {
+      return false
+    }
+  }
+  return true
+}
+
+// sortIssues sorts newest first, which is GitHub's default.
+func sortIssues(issues []*github.Issue) {
+  sort.Sort(issuesByNumber(issues))
+}
+
+type issuesByNumber []*github.Issue
+
+func (a issuesByNumber) Len() int { return len(a) }
+func (a issuesByNumber) Swap(i int, j int) { a[i], a[j] = a[j], a[i] }
+func (a issuesByNumber) Less(i int, j int) bool { return *a[i].Number > *a[j].Number }
+
+type pullsByNumber []*github.PullRequest
+
+func (a pullsByNumber) Len() int { return len(a) }
+func (a pullsByNumber) Swap(i int, j int) { a[i], a[j] = a[j], a[i] }
+func (a pullsByNumber) Less(i int, j int) bool { return *a[i].Number > *a[j].Number }
+
+// pageBounds returns the slice bounds of the page asked for.
+func pageBounds(req *http.Request, total int) (int, int) {
+  page, perPage := pageParams(req)
+  from := (page - 1) * perPage
+  if from > total {
+    from = total
+  }
+  to := from + perPage
+  if to > total {
+    to = total
+  }
+  return from, to
+}
+
+func pageParams(req *http.Request) (int, int) {
+  q := req.URL.Query()
+  page, err := strconv.Atoi(q.Get("page"))
+  if err != nil || page < 1 {
+    page = 1
+  }
+  perPage, err := strconv.Atoi(q.Get("per_page"))
+  if err != nil || perPage < 1 {
+    perPage = DefaultPerPage
+  }
+  return page, perPage
+}
+
+// writePage writes one page of a list, with a Link header for the next and
+// last pages, the way GitHub does.
+func writePage(rw http.ResponseWriter, req *http.Request, total int, slice func(from int, to int) interface{}) {
+  page, perPage := pageParams(req)
+  lastPage := (total + perPage - 1) / perPage
+  if lastPage > 1 {
+    link := func(p int, rel string) string {
+      u := *req.URL
+      u.Scheme = "http"
+      u.Host = req.Host
+      q := u.Query()
+      q.Set("page", strconv.Itoa(p))
+      u.RawQuery = q.Encode()
+      return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+    }
+    links := []string{}
+    if page < lastPage {
+      links = append(links, link(page + 1, "next"), link(lastPage, "last"))
+    }
+    if page > 1 {
+      links = append(links, link(page - 1, "prev"), link(1, "first"))
+    }
+    rw.Header().Set("Link", strings.Join(links, ", "))
+  }
+  from, to := pageBounds(req, total)
+  writeJSON(rw, http.StatusOK, slice(from, to))
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+  rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+  rw.WriteHeader(status)
+  json.NewEncoder(rw).Encode(v)
+}
+
+func writeError(rw http.ResponseWriter, status int, message string) {
+  writeJSON(rw, status, map[string]string{"message": message})
+}
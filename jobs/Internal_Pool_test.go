@@ -0,0 +1,143 @@
+package jobs
+
+import (
+  "time"
+  "errors"
+  "testing"
+  "context"
+
+  "github.com/confyrm/gorest/router/handler"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+// waitFor polls until the job with id has finished.
+func waitFor(p *Pool, id string) Status {
+  for i := 0; i < 200; i++ {
+    for _, s := range p.Jobs() {
+      if s.ID == id && s.FinishedAt != nil {
+        return s
+      }
+    }
+    time.Sleep(5 * time.Millisecond)
+  }
+  return Status{}
+}
+
+func TestPool(t *testing.T) {
+  Convey("Given a started pool", t, func() {
+    p := New(2, 4, 10, time.Second)
+    p.Start()
+
+    Convey("When a job succeeds", func() {
+      id, err := p.Submit(context.Background(), Job{Name: "ok", Run: func(ctx context.Context) error {
+        return nil
+      }})
+      So(err, ShouldBeNil)
+      Convey("The outcome should be succeeded", func() {
+        So(waitFor(p, id).State, ShouldEqual, Succeeded)
+      })
+    })
+
+    Convey("When a job fails", func() {
+      id, _ := p.Submit(context.Background(), Job{Name: "fail", Run: func(ctx context.Context) error {
+        return errors.New("nope")
+      }})
+      s := waitFor(p, id)
+      Convey("The outcome should be failed, with the error", func() {
+        So(s.State, ShouldEqual, Failed)
+        So(s.Error, ShouldEqual, "nope")
+      })
+    })
+
+    Convey("When a job panics", func() {
+      recovered := make(chan error, 1)
+      id, _ := p.Submit(context.Background(), Job{
+        Name: "panic",
+        Run: func(ctx context.Context) error {
+          panic("boom")
+        },
+        Recover: func(ctx context.Context, err error) {
+          recovered <- err
+        },
+      })
+      s := waitFor(p, id)
+      Convey("The outcome should be panicked, and Recover should be called", func() {
+        So(s.State, ShouldEqual, Panicked)
+        So((<-recovered).Error(), ShouldEqual, "boom")
+      })
+    })
+
+    Convey("When a job panics after it timed out", func() {
+      short := New(1, 1, 10, 10 * time.Millisecond)
+      short.Start()
+      defer short.Shutdown(context.Background())
+      recovered := make(chan error, 1)
+      parent := handler.WithRequestID(context.Background(), "req-1")
+      id, _ := short.Submit(parent, Job{
+        Name: "late panic",
+        Run: func(ctx context.Context) error {
+          <-ctx.Done()
+          panic("too late")
+        },
+        Recover: func(ctx context.Context, err error) {
+          if ctx.Err() == nil && handler.RequestID(ctx) == "req-1" {
+            recovered <- err
+          } else {
+            recovered <- ctx.Err()
+          }
+        },
+      })
+      s := waitFor(short, id)
+      Convey("Recover should get a live context, with the request ID", func() {
+        So(s.State, ShouldEqual, Panicked)
+        So((<-recovered).Error(), ShouldEqual, "too late")
+      })
+    })
+
+    Reset(func() {
+      p.Shutdown(context.Background())
+    })
+  })
+
+  Convey("Given a pool with a full queue", t, func() {
+    p := New(1, 1, 10, time.Second)
+    p.Start()
+    release := make(chan struct{})
+    started := make(chan struct{})
+    block := Job{Name: "block", Run: func(ctx context.Context) error {
+      <-release
+      return nil
+    }}
+
+    // One job for the worker, and one for the queue.  Wait for the first to
+    // start, so the second stays queued.
+    p.Submit(context.Background(), Job{Name: "first", Run: func(ctx context.Context) error {
+      close(started)
+      <-release
+      return nil
+    }})
+    <-started
+    p.Submit(context.Background(), block)
+
+    Convey("Submit should return ErrBusy", func() {
+      _, err := p.Submit(context.Background(), block)
+      So(err, ShouldEqual, ErrBusy)
+    })
+
+    Reset(func() {
+      close(release)
+      p.Shutdown(context.Background())
+    })
+  })
+
+  Convey("Given a pool that has been shut down", t, func() {
+    p := New(1, 1, 10, time.Second)
+    p.Start()
+    p.Shutdown(context.Background())
+
+    Convey("Submit should return ErrStopped", func() {
+      _, err := p.Submit(context.Background(), Job{Name: "late"})
+      So(err, ShouldEqual, ErrStopped)
+    })
+  })
+}
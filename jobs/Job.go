@@ -0,0 +1,54 @@
+package jobs
+
+import (
+  "time"
+  "context"
+)
+
+// State is where a job is in its life cycle.
+type State string
+
+const (
+  Queued State = "queued"
+  Running State = "running"
+  Succeeded State = "succeeded"
+  Failed State = "failed"
+  // TimedOut means the job was still running when its context expired.
+  TimedOut State = "timed_out"
+  // Panicked means the job panicked.  The panic was recovered, and passed
+  // to Job.Recover.
+  Panicked State = "panicked"
+)
+
+// Job is a unit of work for a Pool.
+type Job struct {
+  // Name shows up in the admin job list.  Such as "/devhub new".
+  Name string
+  // User that started the job, if any.
+  User string
+  // Run does the work.  The context is detached from the request that
+  // submitted the job, and bounded by the pool Timeout.
+  Run func(ctx context.Context) error
+  // Recover, if set, is called with the recovered panic, so the job can
+  // let the user know something went wrong.  Its context is not the one Run
+  // had, which may have expired, and is bounded by RecoverTimeout.
+  Recover func(ctx context.Context, err error)
+}
+
+// Status is the admin view of a job.
+type Status struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  User string `json:"user,omitempty"`
+  RequestID string `json:"request_id,omitempty"`
+  State State `json:"state"`
+  Error string `json:"error,omitempty"`
+  QueuedAt time.Time `json:"queued_at"`
+  StartedAt *time.Time `json:"started_at,omitempty"`
+  FinishedAt *time.Time `json:"finished_at,omitempty"`
+  // Duration is how long the job has been running, or ran for.
+  Duration string `json:"duration"`
+}
+
+// Helper type for a slice of Status.
+type Statuses []Status
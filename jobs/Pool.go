@@ -0,0 +1,269 @@
+// Package jobs runs long running work, such as slash commands that respond
+// through a response_url, on a bounded pool of workers.
+package jobs
+
+import (
+  "log"
+  "fmt"
+  "sync"
+  "time"
+  "errors"
+  "context"
+  "runtime/debug"
+
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/router/handler"
+)
+
+// Config keys for the pool.  They are read through config.App().
+const (
+  WorkersKey = "JOBS_WORKERS"
+  QueueSizeKey = "JOBS_QUEUE_SIZE"
+  HistorySizeKey = "JOBS_HISTORY_SIZE"
+  // TimeoutKey bounds how long a single job may run.
+  TimeoutKey = "LONG_COMMAND_TIMEOUT"
+)
+
+// DefaultTimeout is the default LONG_COMMAND_TIMEOUT.  Slack response_urls
+// are only good for 30 minutes, so there is no point letting a job run
+// longer.
+const DefaultTimeout = 5 * time.Minute
+
+// RecoverTimeout bounds Job.Recover.  Recover gets a context of its own, as
+// the job's may have expired, which is often why it panicked.
+const RecoverTimeout = 30 * time.Second
+
+// ErrBusy is returned by Submit when the queue is full.
+var ErrBusy = errors.New("Job queue is full")
+
+// ErrStopped is returned by Submit once Shutdown has been called.
+var ErrStopped = errors.New("Job pool is shutting down")
+
+// Default is the pool used by the route handlers.  It is set by Init.
+var Default *Pool
+
+// Init creates and starts the Default pool from the config.  main calls this
+// once, before the servers are started.
+func Init(c *config.Config) *Pool {
+  jobs := c.App().Jobs
+  Default = New(jobs.Workers, jobs.QueueSize, jobs.HistorySize, jobs.Timeout)
+  Default.Start()
+  return Default
+}
+
+// Pool is a fixed set of workers reading from a bounded queue.  Finished
+// jobs are kept in a short history for the admin server.
+type Pool struct {
+  Workers int
+  QueueSize int
+  HistorySize int
+  Timeout time.Duration
+
+  queue chan *entry
+  ctx context.Context
+  cancel context.CancelFunc
+  wg sync.WaitGroup
+
+  mu sync.Mutex
+  closed bool
+  active map[string]*entry
+  history Statuses
+}
+
+type entry struct {
+  job Job
+  ctx context.Context
+  status Status
+}
+
+// New returns a Pool.  Call Start before submitting jobs.
+func New(workers int, queueSize int, historySize int, timeout time.Duration) *Pool {
+  if workers < 1 {
+    workers = 1
+  }
+  if queueSize < 0 {
+    queueSize = 0
+  }
+  ctx, cancel := context.WithCancel(context.Background())
+  return &Pool{
+    Workers: workers,
+    QueueSize: queueSize,
+    HistorySize: historySize,
+    Timeout: timeout,
+    queue: make(chan *entry, queueSize),
+    ctx: ctx,
+    cancel: cancel,
+    active: make(map[string]*entry),
+  }
+}
+
+// Start launches the workers.
+func (p *Pool) Start() {
+  log.Printf("Jobs: Starting %d workers, queue size %d", p.Workers, p.QueueSize)
+  for i := 0; i < p.Workers; i++ {
+    p.wg.Add(1)
+    go p.work()
+  }
+}
+
+// Submit queues job and returns its ID.  It never blocks.  If the queue is
+// full, ErrBusy is returned and the job is dropped.  The job keeps the
+// request ID and Store carried by parent, but not its deadline.
+func (p *Pool) Submit(parent context.Context, job Job) (string, error) {
+  e := &entry{
+    job: job,
+    ctx: handler.Detach(p.ctx, parent),
+    status: Status{
+      ID: handler.NewRequestID(),
+      Name: job.Name,
+      User: job.User,
+      RequestID: handler.RequestID(parent),
+      State: Queued,
+      QueuedAt: time.Now(),
+    },
+  }
+
+  p.mu.Lock()
+  defer p.mu.Unlock()
+  if p.closed {
+    return "", ErrStopped
+  }
+  select {
+  case p.queue <- e:
+    p.active[e.status.ID] = e
+    return e.status.ID, nil
+  default:
+    return "", ErrBusy
+  }
+}
+
+// Jobs returns the queued and running jobs, followed by the finished jobs,
+// most recent first.
+func (p *Pool) Jobs() Statuses {
+  p.mu.Lock()
+  defer p.mu.Unlock()
+
+  now := time.Now()
+  statuses := make(Statuses, 0, len(p.active) + len(p.history))
+  for _, e := range p.active {
+    s := e.status
+    s.Duration = duration(s, now).String()
+    statuses = append(statuses, s)
+  }
+  for i := len(p.history) - 1; i >= 0; i-- {
+    statuses = append(statuses, p.history[i])
+  }
+  return statuses
+}
+
+// Shutdown stops taking jobs, and waits for queued and running jobs to
+// finish.  If ctx expires first, the remaining jobs are cancelled.
+func (p *Pool) Shutdown(ctx context.Context) error {
+  p.mu.Lock()
+  if !p.closed {
+    p.closed = true
+    close(p.queue)
+  }
+  p.mu.Unlock()
+
+  done := make(chan struct{})
+  go func() {
+    p.wg.Wait()
+    close(done)
+  }()
+  select {
+  case <-done:
+    return nil
+  case <-ctx.Done():
+    p.cancel()
+    return ctx.Err()
+  }
+}
+
+func (p *Pool) work() {
+  defer p.wg.Done()
+  for e := range p.queue {
+    p.run(e)
+  }
+}
+
+func (p *Pool) run(e *entry) {
+  ctx, cancel := context.WithTimeout(e.ctx, p.Timeout)
+  defer cancel()
+
+  p.update(e, func(s *Status) {
+    now := time.Now()
+    s.StartedAt = &now
+    s.State = Running
+  })
+
+  state, err := p.call(ctx, e)
+
+  p.finish(e, state, err)
+  if err != nil {
+    log.Printf("[%s] Job %s (%s) %s: %s", e.status.RequestID, e.status.ID, e.status.Name, state, err.Error())
+  }
+}
+
+// call runs the job, and turns a panic into an error.
+func (p *Pool) call(ctx context.Context, e *entry) (state State, err error) {
+  defer func() {
+    if r := recover(); r != nil {
+      state = Panicked
+      err = fmt.Errorf("%v", r)
+      log.Printf("[%s] Job %s (%s) panicked: %v\n%s", e.status.RequestID, e.status.ID, e.status.Name, r, debug.Stack())
+      if e.job.Recover != nil {
+        rctx, cancel := context.WithTimeout(handler.Detach(p.ctx, e.ctx), RecoverTimeout)
+        defer cancel()
+        e.job.Recover(rctx, err)
+      }
+    }
+  }()
+
+  if err = e.job.Run(ctx); err != nil {
+    if ctx.Err() != nil {
+      return TimedOut, err
+    }
+    return Failed, err
+  }
+  return Succeeded, nil
+}
+
+func (p *Pool) update(e *entry, f func(s *Status)) {
+  p.mu.Lock()
+  defer p.mu.Unlock()
+  f(&e.status)
+}
+
+func (p *Pool) finish(e *entry, state State, err error) {
+  p.mu.Lock()
+  defer p.mu.Unlock()
+
+  now := time.Now()
+  e.status.FinishedAt = &now
+  e.status.State = state
+  if err != nil {
+    e.status.Error = err.Error()
+  }
+  e.status.Duration = duration(e.status, now).String()
+
+  delete(p.active, e.status.ID)
+  if p.HistorySize > 0 {
+    p.history = append(p.history, e.status)
+    if len(p.history) > p.HistorySize {
+      p.history = p.history[len(p.history) - p.HistorySize:]
+    }
+  }
+}
+
+// duration is the run time of a started job, or the queue time of one that
+// has not started yet.
+func duration(s Status, now time.Time) time.Duration {
+  if s.StartedAt == nil {
+    return now.Sub(s.QueuedAt)
+  }
+  if s.FinishedAt == nil {
+    return now.Sub(*s.StartedAt)
+  }
+  return s.FinishedAt.Sub(*s.StartedAt)
+}
@@ -3,53 +3,139 @@ package main
 
 import (
   "os"
+  "fmt"
   "log"
+  "context"
 
+  "github.com/confyrm/gorest/jobs"
+  "github.com/confyrm/gorest/store"
   "github.com/confyrm/gorest/admin"
+  "github.com/confyrm/gorest/server"
   "github.com/confyrm/gorest/servers/slack"
   "github.com/confyrm/gorest/config"
-  github "github.com/confyrm/gorest/githubclient"
+  "github.com/spf13/cast"
+  "github.com/spf13/pflag"
   //jww "github.com/spf13/jwalterweatherman"
 )
 
 func main() {
   //jww.SetLogThreshold(jww.LevelDebug)
   //jww.SetStdoutThreshold(jww.LevelDebug)
+  // Secrets from the config are redacted from everything that is logged.
+  log.SetOutput(config.RedactWriter{os.Stderr})
   c := SetupConfig()
-  //  Run the admin server as a go function.  Currently, all it supports is
-  // /exit.
-  go admin.Server(c)
 
-  // Run the main app
-  app := slack.New(c)
-  app.Run()
+  // Report every problem with the config at once, rather than stopping at
+  // the first.  A reloaded config file has to pass the same checks.
+  if err := config.ValidateApp(c); err != nil {
+    fmt.Fprintln(os.Stderr, config.Redact(err.Error()))
+    os.Exit(1)
+  }
+  c.AddValidator("app", config.ValidateApp)
+
+  // SIGINT, SIGTERM and the admin /exit route all end up in server.Stop.
+  server.HandleSignals()
+
+  // Long running slash commands are run on the job pool.
+  pool := jobs.Init(c)
+
+  // App state, such as linked GitHub accounts, is kept in the store.
+  st, err := store.Open(c)
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  // Start the admin server.  Its routes, such as /exit, /jobs and /config,
+  // are in admin/routes.
+  adminServer := admin.New(c)
+  if err := adminServer.Start(); err != nil {
+    log.Fatal(err)
+  }
+
+  // Start the main app
+  app := slack.New(c, st)
+  if err := app.Start(); err != nil {
+    log.Fatal(err)
+  }
+
+  <-server.Stopping()
+  Shutdown(c, app, pool, adminServer)
+  if err := st.Close(); err != nil {
+    log.Printf("Error closing the store: %s", err.Error())
+  }
 }
 
+// Shutdown drains the app before the admin server goes away.  The app stops
+// taking requests first, then any queued or running jobs are given the rest
+// of SHUTDOWN_TIMEOUT to post their responses.
+func Shutdown(c *config.Config, app *server.Server, pool *jobs.Pool, adminServer *server.Server) {
+  ctx, cancel := context.WithTimeout(context.Background(), server.ShutdownTimeout(c))
+  defer cancel()
+
+  if err := app.Shutdown(ctx); err != nil {
+    log.Printf("Error shutting down %s: %s", app.Name, err.Error())
+  }
+  if err := pool.Shutdown(ctx); err != nil {
+    log.Printf("Gave up waiting for jobs: %s", err.Error())
+  }
+  if err := adminServer.Shutdown(ctx); err != nil {
+    log.Printf("Error shutting down %s: %s", adminServer.Name, err.Error())
+  }
+  log.Printf("Shutdown complete")
+}
+
+// ConfigKey names the config file.  It can only be set by flag or env.
+const ConfigKey = "GOREST_CONFIG"
+
+// Flags are the config keys that can be set on the command line: the config
+// file and profile, and the AppConfig.  Secrets and maps are left to the env and the
+// config file, since command lines are easy to see in ps.
+var Flags = append(config.ConfigFlags {
+  {ConfigKey, config.StringFlag, "config", "Config file.  A path, a path/file or a file, with or without an extension"},
+  {config.ProfileKey, config.StringFlag, nil, "Profile, such as prod.  Its file, such as config.prod.yml, is read over the config file"},
+}, config.StructFlags(config.AppConfig{})...)
+
 func SetupConfig() *config.Config {
-  // Check the env for the location of a config file. The configFile can be
-  // just a path, or a path/file.  The configFile does not need an extension.
-  defaultConfigName := "config"
-  var configFile string
-  if configFile = os.Getenv("GOREST_CONFIG"); configFile == "" {
-    log.Printf("GOREST_CONFIG not set.  Using %s", defaultConfigName)
-    configFile = defaultConfigName
+  fs, err := Flags.FlagSet(os.Args[0], pflag.ContinueOnError)
+  if err != nil {
+    log.Fatal(err)
+  }
+  if err := fs.Parse(os.Args[1:]); err == pflag.ErrHelp {
+    os.Exit(0)
+  } else if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    fs.Usage()
+    os.Exit(2)
+  }
+
+  // Check the flags, then the env, for the location of a config file. The
+  // configFile can be just a path, or a path/file.  The configFile does not
+  // need an extension.
+  configFile, ok := Flags.Changed(fs, ConfigKey)
+  if ok {
+    log.Printf("--%s is set to %s", config.FlagName(ConfigKey), configFile)
+  } else if configFile = os.Getenv(ConfigKey); configFile != "" {
+    log.Printf("%s is set to %s", ConfigKey, configFile)
   } else {
-    log.Printf("GOREST_CONFIG is set to %s", configFile)
+    configFile = cast.ToString(Flags.Defaults()[ConfigKey])
+    log.Printf("%s not set.  Using %s", ConfigKey, configFile)
+  }
+
+  profile, ok := Flags.Changed(fs, config.ProfileKey)
+  if !ok {
+    profile = os.Getenv(config.ProfileKey)
   }
 
   // Default configuration settings that need to have valid
-  // values, even if not set in a config file or in the env.
-  configDefaults := map[string]interface{} {
-    "APP_PORT": 8080,
-    "APP_NAME": "devhub",
-    "APP_ROOT": ".",
-    "ADMIN_PORT": 8001,
-    github.DefaultOwner: "confyrm",
-    github.DefaultRepo: "devhub",
-  }
-  log.Printf("Using the following defaults: %v.  To override, set in either the ENV or the config file", configDefaults)
+  // values, even if not set on the command line, in a config file or in the
+  // env.
+  configDefaults := Flags.Defaults()
   // Tell Viper to look for a file called 'config'.  The fileName can
   // be pathed, as well.  In which case, Viper will look in the path and
   // in the current directory '.', in that order.
-  return config.New(&configFile, &configDefaults)
+  c := config.NewProfile(&configFile, profile, &configDefaults)
+  if err := Flags.Bind(c, fs); err != nil {
+    log.Fatal(err)
+  }
+  return c
 }
@@ -9,6 +9,7 @@ import (
 
   "github.com/stretchr/testify/assert"
   "github.com/stretchr/testify/require"
+  "github.com/confyrm/gorest/store"
   "github.com/confyrm/gorest/router/handler"
   "github.com/confyrm/gorest/servers/slack/routes"
 )
@@ -31,7 +32,8 @@ func TestMain(t *testing.T ) {
   c := SetupConfig()
   h := handler.Handler {
     c,
-    routes.SlashRouter,
+    store.NewMemory(),
+    handler.Contextual(routes.SlashRouter),
   }
 
   server := httptest.NewServer(h)
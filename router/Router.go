@@ -6,6 +6,7 @@ import (
   "net/http"
 
   "github.com/gorilla/mux"
+  "github.com/confyrm/gorest/store"
   "github.com/confyrm/gorest/router/handler"
   "github.com/confyrm/gorest/config"
 )
@@ -30,8 +31,9 @@ type Routes []Route
 // New returns the fully configured router for the set of routes.
 // The config is not use by the Router directly, but it is passed down to
 // all handler functions.  This is the simplest way to handle global
-// config data.
-func (routes Routes) New(config *config.Config) *mux.Router {
+// config data.  The store, which may be nil, is passed down the same way,
+// in the request context.
+func (routes Routes) New(config *config.Config, store store.Store) *mux.Router {
   // Setting StrictSlash allow a path to be
   // accepted with, or without a trailing slash.
   // So, a path of '/thispath' == a path of '/thispath/'
@@ -39,7 +41,7 @@ func (routes Routes) New(config *config.Config) *mux.Router {
   for _, route := range routes {
     var h http.Handler
     //handler = route.HandlerFunc
-    h = handler.Handler{config, route.HandlerFunc}
+    h = handler.Handler{config, store, route.HandlerFunc}
 
     router.
       Methods(route.Method).
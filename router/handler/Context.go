@@ -0,0 +1,81 @@
+package handler
+
+import (
+  "net/http"
+  "context"
+  "crypto/rand"
+  "encoding/hex"
+
+  "github.com/confyrm/gorest/store"
+  "github.com/confyrm/gorest/config"
+)
+
+// ContextHandlerFunc is an EnvHandlerFunc that also receives the request
+// context.  The context carries the request ID and the request deadline.
+type ContextHandlerFunc func(ctx context.Context, e *config.Config, rw http.ResponseWriter, req *http.Request) error
+
+// Contextual adapts a ContextHandlerFunc so that it can be used anywhere an
+// EnvHandlerFunc is expected, such as a router.Route.
+func Contextual(h ContextHandlerFunc) EnvHandlerFunc {
+  return func(c *config.Config, rw http.ResponseWriter, req *http.Request) error {
+    return h(req.Context(), c, rw, req)
+  }
+}
+
+// RequestIDHeader is echoed back on every response.  If the caller sends
+// one, it is reused.
+const RequestIDHeader = "X-Request-Id"
+
+// RequestTimeout is the config key for the per request deadline.
+const RequestTimeout = "REQUEST_TIMEOUT"
+
+type contextKey int
+
+const requestIDKey contextKey = 0
+
+// NewRequestID returns a random 16 character hex ID.
+func NewRequestID() string {
+  b := make([]byte, 8)
+  if _, err := rand.Read(b); err != nil {
+    return "unknown"
+  }
+  return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of ctx that carries id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+  return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+  if ctx == nil {
+    return ""
+  }
+  id, _ := ctx.Value(requestIDKey).(string)
+  return id
+}
+
+// Detach returns a copy of base that carries the request ID and Store of
+// parent, but not its deadline.  It is for work that outlives the request,
+// such as jobs.
+func Detach(base context.Context, parent context.Context) context.Context {
+  ctx := WithRequestID(base, RequestID(parent))
+  if s := store.FromContext(parent); s != nil {
+    ctx = store.WithStore(ctx, s)
+  }
+  return ctx
+}
+
+// newRequestContext derives the context every handler runs with.
+func newRequestContext(c *config.Config, s store.Store, req *http.Request) (context.Context, context.CancelFunc) {
+  id := req.Header.Get(RequestIDHeader)
+  if id == "" {
+    id = NewRequestID()
+  }
+  ctx := WithRequestID(req.Context(), id)
+  if s != nil {
+    ctx = store.WithStore(ctx, s)
+  }
+  return context.WithTimeout(ctx, c.App().RequestTimeout)
+}
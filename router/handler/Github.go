@@ -0,0 +1,75 @@
+package handler
+
+import (
+  "log"
+  "fmt"
+  "bytes"
+  "errors"
+  "strings"
+  "net/http"
+  "io/ioutil"
+  "crypto/hmac"
+  "crypto/sha256"
+  "encoding/hex"
+
+  "github.com/confyrm/gorest/config"
+  . "github.com/confyrm/gorest/errors"
+)
+
+// GithubWebhookSecret is the config key for the secret entered when the
+// webhook was added to the GitHub repo or org.
+const GithubWebhookSecret = "GITHUB_WEBHOOK_SECRET"
+
+// GitHub webhook request headers.
+const (
+  GithubSignatureHeader = "X-Hub-Signature-256"
+  GithubEventHeader = "X-GitHub-Event"
+  GithubDeliveryHeader = "X-GitHub-Delivery"
+)
+
+// MaxGithubBodySize caps how much of a webhook body is read.  GitHub caps
+// payloads at 25MB, but nothing we handle comes close.
+const MaxGithubBodySize = 5 << 20
+
+// VerifyGithub is middleware that authenticates a GitHub webhook before the
+// wrapped handler sees it.  Like VerifySlack, the body is restored after
+// verification.
+func VerifyGithub(h EnvHandlerFunc) EnvHandlerFunc {
+  return func(c *config.Config, rw http.ResponseWriter, req *http.Request) error {
+    body, err := ioutil.ReadAll(http.MaxBytesReader(rw, req.Body, MaxGithubBodySize))
+    if err != nil {
+      return StatusError{http.StatusBadRequest,
+        fmt.Errorf("Could not read request body: %s", err.Error())}
+    }
+    req.Body.Close()
+
+    if err := VerifyGithubSignature(c.App().Github.WebhookSecret, req.Header.Get(GithubSignatureHeader), body); err != nil {
+      log.Printf("GitHub verification failed for delivery %s: %s", req.Header.Get(GithubDeliveryHeader), err.Error())
+      return StatusError{http.StatusUnauthorized, err}
+    }
+
+    req.Body = ioutil.NopCloser(bytes.NewReader(body))
+    return h(c, rw, req)
+  }
+}
+
+// VerifyGithubSignature checks a "sha256=<hex>" signature against body.
+func VerifyGithubSignature(secret string, signature string, body []byte) error {
+  if secret == "" {
+    return errors.New("Not authorized. No GitHub webhook secret configured.")
+  }
+  if !strings.HasPrefix(signature, "sha256=") {
+    return errors.New("Not authorized. Missing GitHub signature.")
+  }
+  if !hmac.Equal([]byte(GithubSignature(secret, body)), []byte(signature)) {
+    return errors.New("Not authorized. Wrong GitHub signature.")
+  }
+  return nil
+}
+
+// GithubSignature computes the value GitHub sends in X-Hub-Signature-256.
+func GithubSignature(secret string, body []byte) string {
+  mac := hmac.New(sha256.New, []byte(secret))
+  mac.Write(body)
+  return config.Join("=", "sha256", hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+  "fmt"
+  "testing"
+
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+var githubBody = []byte(`{"action":"opened","issue":{"number":4}}`)
+
+var githubSignatureData = []struct {
+  Name string
+  Secret string
+  Signature string
+  Valid bool
+} {
+  {
+    "a correctly signed delivery",
+    testSecret,
+    GithubSignature(testSecret, githubBody),
+    true,
+  },
+  {
+    "a delivery signed with the wrong secret",
+    testSecret,
+    GithubSignature("not the secret", githubBody),
+    false,
+  },
+  {
+    "a delivery with a sha1 signature",
+    testSecret,
+    "sha1=0123456789abcdef",
+    false,
+  },
+  {
+    "an unsigned delivery",
+    testSecret,
+    "",
+    false,
+  },
+  {
+    "a delivery when no secret is configured",
+    "",
+    GithubSignature("", githubBody),
+    false,
+  },
+}
+
+func TestVerifyGithubSignature(t *testing.T) {
+  for _, d := range githubSignatureData {
+    err := VerifyGithubSignature(d.Secret, d.Signature, githubBody)
+    Convey(fmt.Sprintf("Given %s", d.Name), t, func() {
+      Convey(fmt.Sprintf("Valid should be %v", d.Valid), func() {
+        So(err == nil, ShouldEqual, d.Valid)
+      })
+    })
+  }
+}
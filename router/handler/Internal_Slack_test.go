@@ -0,0 +1,153 @@
+package handler
+
+import (
+  "fmt"
+  "time"
+  "testing"
+  "net/http"
+
+  "github.com/confyrm/gorest/config"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+var testSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+var testBody = []byte("token=abcd1234&team_domain=example&command=%2Fdevhub&text=help")
+var testNow = time.Unix(1531420618, 0)
+
+var signatureData = []struct {
+  Name string
+  Timestamp string
+  Signature string
+  Valid bool
+} {
+  {
+    "a correctly signed request",
+    "1531420618",
+    SlackSignature(testSecret, "1531420618", testBody),
+    true,
+  },
+  {
+    "a request signed with the wrong secret",
+    "1531420618",
+    SlackSignature("not the secret", "1531420618", testBody),
+    false,
+  },
+  {
+    "a request whose signature is for another timestamp",
+    "1531420618",
+    SlackSignature(testSecret, "1531420617", testBody),
+    false,
+  },
+  {
+    "a replayed request",
+    "1531420000",
+    SlackSignature(testSecret, "1531420000", testBody),
+    false,
+  },
+  {
+    "a request with no timestamp",
+    "",
+    SlackSignature(testSecret, "", testBody),
+    false,
+  },
+  {
+    "a request with a garbage timestamp",
+    "yesterday",
+    SlackSignature(testSecret, "yesterday", testBody),
+    false,
+  },
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+  for _, d := range signatureData {
+    err := VerifySlackSignature(testSecret, d.Timestamp, d.Signature, testBody, DefaultSignatureWindow, testNow)
+    Convey(fmt.Sprintf("Given %s", d.Name), t, func() {
+      Convey(fmt.Sprintf("Valid should be %v", d.Valid), func() {
+        So(err == nil, ShouldEqual, d.Valid)
+      })
+    })
+  }
+}
+
+func TestVerifySlackRequest(t *testing.T) {
+  Convey("Given an unsigned request", t, func() {
+    header := http.Header{}
+
+    Convey("When the legacy token is not allowed", func() {
+      c := config.New(nil, &map[string]interface{} {
+        SlackSigningSecret: testSecret,
+        SlackToken: "abcd1234",
+      })
+      Convey("The request should be rejected", func() {
+        So(VerifySlackRequest(c, header, testBody, testNow), ShouldNotBeNil)
+      })
+    })
+
+    Convey("When the legacy token is allowed and matches", func() {
+      c := config.New(nil, &map[string]interface{} {
+        SlackAllowLegacyToken: true,
+        SlackToken: "abcd1234",
+      })
+      Convey("The request should be accepted", func() {
+        So(VerifySlackRequest(c, header, testBody, testNow), ShouldBeNil)
+      })
+    })
+
+    Convey("When the legacy token is allowed and the body is an Events API payload", func() {
+      c := config.New(nil, &map[string]interface{} {
+        SlackAllowLegacyToken: true,
+        SlackToken: "abcd1234",
+      })
+      body := []byte(`{"token":"abcd1234","type":"url_verification","challenge":"xyz"}`)
+      Convey("The request should be accepted", func() {
+        So(VerifySlackRequest(c, header, body, testNow), ShouldBeNil)
+      })
+    })
+
+    Convey("When the legacy token is allowed and the body is an interactive payload", func() {
+      c := config.New(nil, &map[string]interface{} {
+        SlackAllowLegacyToken: true,
+        SlackToken: "abcd1234",
+      })
+      body := []byte(`payload=%7B%22token%22%3A%22abcd1234%22%2C%22type%22%3A%22block_actions%22%7D`)
+      Convey("The request should be accepted", func() {
+        So(VerifySlackRequest(c, header, body, testNow), ShouldBeNil)
+      })
+    })
+
+    Convey("When the legacy token is allowed but does not match", func() {
+      c := config.New(nil, &map[string]interface{} {
+        SlackAllowLegacyToken: true,
+        SlackToken: "wxyz9876",
+      })
+      Convey("The request should be rejected", func() {
+        So(VerifySlackRequest(c, header, testBody, testNow), ShouldNotBeNil)
+      })
+    })
+  })
+
+  Convey("Given a signed request", t, func() {
+    header := http.Header{}
+    header.Set(SlackTimestampHeader, "1531420618")
+    header.Set(SlackSignatureHeader, SlackSignature(testSecret, "1531420618", testBody))
+
+    Convey("When the signing secret is configured", func() {
+      c := config.New(nil, &map[string]interface{} {
+        SlackSigningSecret: testSecret,
+      })
+      Convey("The request should be accepted", func() {
+        So(VerifySlackRequest(c, header, testBody, testNow), ShouldBeNil)
+      })
+    })
+
+    Convey("When no signing secret is configured", func() {
+      c := config.New(nil, &map[string]interface{} {
+        SlackAllowLegacyToken: true,
+        SlackToken: "abcd1234",
+      })
+      Convey("The request should be rejected", func() {
+        So(VerifySlackRequest(c, header, testBody, testNow), ShouldNotBeNil)
+      })
+    })
+  })
+}
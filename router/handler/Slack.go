@@ -0,0 +1,163 @@
+package handler
+
+import (
+  "log"
+  "fmt"
+  "time"
+  "bytes"
+  "errors"
+  "strconv"
+  "net/url"
+  "net/http"
+  "io/ioutil"
+  "crypto/hmac"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+
+  "github.com/confyrm/gorest/config"
+  . "github.com/confyrm/gorest/errors"
+)
+
+// Config keys used by VerifySlack.
+const (
+  // SlackSigningSecret is the app signing secret from the Slack app
+  // "Basic Information" page.
+  SlackSigningSecret = "SLACK_SIGNING_SECRET"
+  // SlackToken is the legacy verification token.
+  SlackToken = "SLACK_TOKEN"
+  // SlackAllowLegacyToken opts in to accepting unsigned requests that carry
+  // a matching SLACK_TOKEN.  Only use this for old workspaces.
+  SlackAllowLegacyToken = "SLACK_ALLOW_LEGACY_TOKEN"
+  // SlackSignatureWindow is how old a request timestamp may be before the
+  // request is treated as a replay.
+  SlackSignatureWindow = "SLACK_SIGNATURE_WINDOW"
+)
+
+// Slack request headers.
+const (
+  SlackSignatureHeader = "X-Slack-Signature"
+  SlackTimestampHeader = "X-Slack-Request-Timestamp"
+)
+
+// SlackSignatureVersion is the only signature version Slack currently sends.
+const SlackSignatureVersion = "v0"
+
+// DefaultSignatureWindow is the replay window Slack recommends.
+const DefaultSignatureWindow = 5 * time.Minute
+
+// MaxSlackBodySize caps how much of a request body is read for verification.
+const MaxSlackBodySize = 1 << 20
+
+// VerifySlack is middleware that authenticates a request as coming from
+// Slack before the wrapped handler sees it.  The body is read once, verified,
+// and then restored so the wrapped handler can parse it as usual.
+//     router.Route{"SlashCommand", "POST", "/slack", handler.VerifySlack(SlashRouter)}
+func VerifySlack(h EnvHandlerFunc) EnvHandlerFunc {
+  return func(c *config.Config, rw http.ResponseWriter, req *http.Request) error {
+    body, err := ioutil.ReadAll(http.MaxBytesReader(rw, req.Body, MaxSlackBodySize))
+    if err != nil {
+      return StatusError{http.StatusBadRequest,
+        fmt.Errorf("Could not read request body: %s", err.Error())}
+    }
+    req.Body.Close()
+
+    if err := VerifySlackRequest(c, req.Header, body, time.Now()); err != nil {
+      log.Printf("Slack verification failed for %s: %s", req.URL.Path, err.Error())
+      return StatusError{http.StatusUnauthorized, err}
+    }
+
+    req.Body = ioutil.NopCloser(bytes.NewReader(body))
+    return h(c, rw, req)
+  }
+}
+
+// VerifySlackRequest checks the signature headers against the raw body.  If
+// the request is unsigned and SLACK_ALLOW_LEGACY_TOKEN is set, the form
+// token is compared against SLACK_TOKEN instead.
+func VerifySlackRequest(c *config.Config, header http.Header, body []byte, now time.Time) error {
+  slackConfig := c.App().Slack
+  signature := header.Get(SlackSignatureHeader)
+  if signature == "" {
+    if slackConfig.AllowLegacyToken {
+      return VerifySlackToken(slackConfig.Token, body)
+    }
+    return errors.New("Not authorized. Missing Slack signature.")
+  }
+
+  if slackConfig.SigningSecret == "" {
+    return errors.New("Not authorized. No Slack signing secret configured.")
+  }
+  return VerifySlackSignature(slackConfig.SigningSecret, header.Get(SlackTimestampHeader), signature, body,
+    slackConfig.SignatureWindow, now)
+}
+
+// VerifySlackSignature validates a v0 signature, and rejects timestamps that
+// fall outside of the replay window.
+func VerifySlackSignature(secret string, timestamp string, signature string, body []byte, window time.Duration, now time.Time) error {
+  if timestamp == "" {
+    return errors.New("Not authorized. Missing Slack request timestamp.")
+  }
+  ts, err := strconv.ParseInt(timestamp, 10, 64)
+  if err != nil {
+    return fmt.Errorf("Not authorized. Bad Slack request timestamp: %s", timestamp)
+  }
+  age := now.Sub(time.Unix(ts, 0))
+  if age < 0 {
+    age = -age
+  }
+  if age > window {
+    return errors.New("Not authorized. Slack request timestamp is outside the replay window.")
+  }
+
+  expected := SlackSignature(secret, timestamp, body)
+  if !hmac.Equal([]byte(expected), []byte(signature)) {
+    return errors.New("Not authorized. Wrong Slack signature.")
+  }
+  return nil
+}
+
+// VerifySlackToken is the legacy check.  The token is pulled from the form
+// encoded body without touching the request's own form parsing.  Interactive
+// requests carry the token inside the JSON "payload" field instead, and
+// Events API requests are JSON all the way down.
+func VerifySlackToken(token string, body []byte) error {
+  if token == "" {
+    return errors.New("Not authorized. No Slack token configured.")
+  }
+  var sent string
+  if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+    sent = jsonToken(trimmed)
+  } else {
+    values, err := url.ParseQuery(string(body))
+    if err != nil {
+      return errors.New("Not authorized. Could not read Slack token.")
+    }
+    sent = values.Get("token")
+    if payload := values.Get("payload"); sent == "" && payload != "" {
+      sent = jsonToken([]byte(payload))
+    }
+  }
+  if !hmac.Equal([]byte(sent), []byte(token)) {
+    return errors.New("Not authorized. Wrong Slack Token.")
+  }
+  return nil
+}
+
+// jsonToken returns the top level "token" field of a JSON object, or "".
+func jsonToken(body []byte) string {
+  var p struct {
+    Token string `json:"token"`
+  }
+  if err := json.Unmarshal(body, &p); err != nil {
+    return ""
+  }
+  return p.Token
+}
+
+// SlackSignature computes the value Slack sends in X-Slack-Signature.
+func SlackSignature(secret string, timestamp string, body []byte) string {
+  mac := hmac.New(sha256.New, []byte(secret))
+  mac.Write([]byte(config.Join(":", SlackSignatureVersion, timestamp, string(body))))
+  return config.Join("=", SlackSignatureVersion, hex.EncodeToString(mac.Sum(nil)))
+}
@@ -3,6 +3,7 @@ package handler
 import (
   "log"
   "net/http"
+  "github.com/confyrm/gorest/store"
   "github.com/confyrm/gorest/config"
   . "github.com/confyrm/gorest/errors"
 )
@@ -13,21 +14,30 @@ import (
 type EnvHandlerFunc func(e *config.Config, rw http.ResponseWriter, req *http.Request) error
 
 // The Handler struct that takes a configured Env and a function matching
-// our useful signature.
+// our useful signature.  Store, if set, is passed down in the request
+// context.  Handlers get it with store.FromContext.
 type Handler struct {
   Env *config.Config
+  Store store.Store
   H EnvHandlerFunc
 }
 
-// ServeHTTP allows our Handler type to satisfy http.Handler.
+// ServeHTTP allows our Handler type to satisfy http.Handler.  Every request
+// is given a request ID and a deadline before H is called.  Handlers get at
+// them through req.Context(), or by being wrapped with Contextual.
 func (h Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+  ctx, cancel := newRequestContext(h.Env, h.Store, req)
+  defer cancel()
+  req = req.WithContext(ctx)
+  rw.Header().Set(RequestIDHeader, RequestID(ctx))
+
   err := h.H(h.Env, rw, req)
   if err != nil {
     switch e := err.(type) {
     case Error:
       // We can retrieve the status here and write out a specific
       // HTTP status code.
-      log.Printf("HTTP %d - %s", e.Status(), e)
+      log.Printf("[%s] HTTP %d - %s", RequestID(ctx), e.Status(), e)
       http.Error(rw, e.Error(), e.Status())
     default:
       // Any error types we don't specifically look out for default
@@ -0,0 +1,38 @@
+// Command seal encrypts a secret for a secret://aes/ reference in the
+// config.  The secret is read from stdin, and the sealed file is written to
+// stdout:
+//     openssl rand -base64 32 > prod.key
+//     go run ./seal --key-file prod.key < github_token > dist/secrets/github_token
+// Then set GITHUB_TOKEN to secret://aes/dist/secrets/github_token, and
+// SECRETS_KEY_FILE to prod.key.
+package main
+
+import (
+  "os"
+  "fmt"
+  "strings"
+  "io/ioutil"
+  "github.com/confyrm/gorest/config"
+  "github.com/spf13/pflag"
+)
+
+func main() {
+  keyFile := pflag.String("key-file", os.Getenv(config.SecretsKeyFile),
+    fmt.Sprintf("File with the base64 encoded AES-256 key (%s)", config.SecretsKeyFile))
+  pflag.Parse()
+
+  secret, err := ioutil.ReadAll(os.Stdin)
+  if err != nil {
+    fail(err)
+  }
+  sealed, err := config.SealSecret(*keyFile, strings.TrimRight(string(secret), "\r\n"))
+  if err != nil {
+    fail(err)
+  }
+  fmt.Println(sealed)
+}
+
+func fail(err error) {
+  fmt.Fprintln(os.Stderr, err.Error())
+  os.Exit(1)
+}
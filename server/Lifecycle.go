@@ -0,0 +1,50 @@
+package server
+
+import (
+  "os"
+  "log"
+  "sync"
+  "time"
+  "syscall"
+  "os/signal"
+
+  "github.com/confyrm/gorest/config"
+)
+
+// Config key for how long main waits for requests and long running jobs
+// to finish during shutdown.
+const ShutdownTimeoutKey = "SHUTDOWN_TIMEOUT"
+
+var (
+  stopOnce sync.Once
+  stopping = make(chan struct{})
+)
+
+// Stop starts a graceful shutdown of the process.  It is safe to call more
+// than once, and from any goroutine.  Only the first reason is logged.
+func Stop(reason string) {
+  stopOnce.Do(func() {
+    log.Printf("Stopping: %s", reason)
+    close(stopping)
+  })
+}
+
+// Stopping returns a channel that is closed once Stop has been called.
+func Stopping() <-chan struct{} {
+  return stopping
+}
+
+// HandleSignals calls Stop when the process receives SIGINT or SIGTERM.
+func HandleSignals() {
+  signals := make(chan os.Signal, 1)
+  signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+  go func() {
+    sig := <-signals
+    Stop(sig.String())
+  }()
+}
+
+// ShutdownTimeout returns the configured drain period.
+func ShutdownTimeout(c *config.Config) time.Duration {
+  return c.App().ShutdownTimeout
+}
@@ -5,25 +5,117 @@ import (
   "os"
   "log"
   "fmt"
+  "net"
+  "sync"
+  "errors"
   "net/http"
+  "context"
 
   "github.com/gorilla/handlers"
+  "github.com/confyrm/gorest/store"
   "github.com/confyrm/gorest/router"
   "github.com/confyrm/gorest/config"
 )
 
 type Server struct {
   Config *config.Config
+  // Optional.  Passed down to every handler.  See handler.Handler.
+  Store store.Store
   Name string
   Port int
+  // Optional.  Picks Port out of the app config.  If set, the server moves
+  // to the new port when the config is reloaded.
+  PortOf func(app *config.AppConfig) int
   RouteSet router.Routes
+
+  // Set by Start.
+  mu sync.Mutex
+  handler http.Handler
+  httpServer *http.Server
+}
+
+// Server.Start launches the http router for this Server in the background.
+// Listen errors are returned immediately.  If the server later fails, Stop
+// is called so that main can shut everything else down.
+func (s *Server) Start() error {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  if s.httpServer != nil {
+    return fmt.Errorf("%s: Already started", s.Name)
+  }
+  router := s.RouteSet.New(s.Config, s.Store)
+  s.handler = handlers.CombinedLoggingHandler(os.Stdout, router)
+
+  listener, err := s.listen(s.Port)
+  if err != nil {
+    return err
+  }
+  s.serve(listener)
+  if s.PortOf != nil {
+    s.Config.Subscribe(fmt.Sprintf("%s port", s.Name), s.reload)
+  }
+  return nil
+}
+
+func (s *Server) listen(port int) (net.Listener, error) {
+  listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+  if err != nil {
+    return nil, fmt.Errorf("%s: Could not listen on %d: %s", s.Name, port, err.Error())
+  }
+  log.Printf("%s: Listening on %d...", s.Name, port)
+  return listener, nil
+}
+
+// serve starts a new http.Server on listener.  Call with the lock held.
+func (s *Server) serve(listener net.Listener) {
+  httpServer := &http.Server{Handler: s.handler}
+  s.httpServer = httpServer
+  go func() {
+    if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+      log.Printf("%s: Server failed: %s", s.Name, err.Error())
+      Stop(fmt.Sprintf("%s failed", s.Name))
+    }
+  }()
+}
+
+// reload moves the server to the port in the reloaded config.  The new port
+// is listened on before the old one is closed, so a port that is taken
+// rejects the new config.  Requests on the old port are left to finish.
+func (s *Server) reload(c *config.Config) error {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  port := s.PortOf(c.App())
+  if port == s.Port || s.httpServer == nil {
+    return nil
+  }
+  listener, err := s.listen(port)
+  if err != nil {
+    return err
+  }
+  old := s.httpServer
+  s.serve(listener)
+  log.Printf("%s: Moved from %d to %d", s.Name, s.Port, port)
+  s.Port = port
+
+  go func() {
+    ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout(c))
+    defer cancel()
+    if err := old.Shutdown(ctx); err != nil {
+      log.Printf("%s: Error closing the old port: %s", s.Name, err.Error())
+    }
+  }()
+  return nil
 }
 
-// Server.Run is called in gorest.main, and launches the http router
-// for this Server.
-func (s *Server) Run() {
-  router := s.RouteSet.New(s.Config)
-  loggedRouter := handlers.CombinedLoggingHandler(os.Stdout, router)
-  log.Printf("%s: Listening on %d...", s.Name, s.Port)
-  log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", s.Port), loggedRouter))
+// Server.Shutdown stops accepting new connections and waits for in-flight
+// requests to finish, or for ctx to expire.
+func (s *Server) Shutdown(ctx context.Context) error {
+  s.mu.Lock()
+  httpServer := s.httpServer
+  s.mu.Unlock()
+  if httpServer == nil {
+    return errors.New("Server was not started")
+  }
+  log.Printf("%s: Shutting down...", s.Name)
+  return httpServer.Shutdown(ctx)
 }
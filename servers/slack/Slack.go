@@ -5,30 +5,40 @@ import (
   "log"
   "github.com/confyrm/gorest/config"
   "github.com/confyrm/gorest/server"
+  "github.com/confyrm/gorest/store"
+  "github.com/confyrm/gorest/servers/slack/commands"
   . "github.com/confyrm/gorest/servers/slack/routes"
 )
 
-// Prefix gets added to the config lookups.
-var Prefix = "APP_"
-
-// New returns a configured server.Server that can be Run by main.  It would
+// New returns a configured server.Server that can be started by main.  It would
 // be great if I knew how to reflect a package, so that main can just find
 // this New.  But for now, the package has to be loaded in main manually, and
 // this New specifically called.
-func New(c *config.Config) *server.Server {
-  // Do some checks to make sure all required configs are present, etc.
-  if !c.IsSet("SLACK_TOKEN") {
-    log.Fatal("No Slack Token found. Check your config.")
+func New(c *config.Config, st store.Store) *server.Server {
+  // main has already checked the config with config.ValidateApp.
+  app := c.App()
+  if app.Slack.SigningSecret == "" {
+    log.Printf("No Slack signing secret found.  Falling back to the legacy Slack token.")
   }
-  if !c.IsSet("GITHUB_TOKEN") {
-    log.Fatal("No GitHub Token found. Check your config.")
+  tokens := store.Bucket{st, commands.TokensBucket}
+  if err := commands.SetupAccountLinking(c, tokens); err != nil {
+    log.Fatal(err.Error())
   }
 
+  // Anything that was set up from the old config file is set up again from
+  // a reloaded one.
+  c.Subscribe("account linking", func(c *config.Config) error {
+    return commands.SetupAccountLinking(c, tokens)
+  })
+  c.Subscribe("help", ReloadHelp)
+
   s := server.Server {
-    c,
-    c.GetString(config.Key(Prefix, "NAME")),
-    c.GetInt(config.Key(Prefix, "PORT")),
-    RouteSet,
+    Config: c,
+    Store: st,
+    Name: app.Name,
+    Port: app.Port,
+    PortOf: func(app *config.AppConfig) int { return app.Port },
+    RouteSet: RouteSet,
   }
   return &s
 }
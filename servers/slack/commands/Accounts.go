@@ -0,0 +1,337 @@
+package commands
+
+import (
+  "fmt"
+  "time"
+  "errors"
+  "sync"
+  "context"
+  "net/http"
+  "encoding/json"
+  "golang.org/x/oauth2"
+  "github.com/confyrm/gorest/githubclient"
+  . "github.com/confyrm/gorest/errors"
+  . "github.com/confyrm/gorest/slack/command"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/store"
+)
+
+// tokens holds the GitHub accounts linked with /devhub login.  It is set by
+// SetupAccountLinking, and is nil if linking is off.
+var (
+  tokensMu sync.RWMutex
+  tokens githubclient.TokenStore
+)
+
+// Tokens returns the linked GitHub accounts, or nil if linking is off.
+func Tokens() githubclient.TokenStore {
+  tokensMu.RLock()
+  defer tokensMu.RUnlock()
+  return tokens
+}
+
+// TokensBucket is the store bucket that linked accounts are kept in.
+const TokensBucket = "github_tokens"
+
+// SetupAccountLinking turns on /devhub login if GITHUB_CLIENT_ID is set.
+// Linked tokens are encrypted with GITHUB_TOKEN_KEY.  It is called again
+// when the config is reloaded, so linking can be turned on or off, or the
+// key changed, without a restart.
+func SetupAccountLinking(config *config.Config, blobs githubclient.BlobStore) error {
+  if !LinkingEnabled(config) {
+    setTokens(nil)
+    return nil
+  }
+  app := config.App()
+  if app.Github.ClientSecret == "" || app.Github.RedirectUrl == "" {
+    return fmt.Errorf("%s needs %s and %s", githubclient.ClientId, githubclient.ClientSecret, githubclient.RedirectUrl)
+  }
+  if app.Slack.ClientId == "" || app.Slack.ClientSecret == "" || app.Slack.RedirectUrl == "" {
+    return fmt.Errorf("%s needs SLACK_CLIENT_ID, SLACK_CLIENT_SECRET and SLACK_OAUTH_REDIRECT_URL, for Sign in with Slack", githubclient.ClientId)
+  }
+  store, err := githubclient.NewEncryptedStore(app.Github.TokenKey, blobs)
+  if err != nil {
+    return fmt.Errorf("Bad %s: %s", githubclient.TokenKey, err.Error())
+  }
+  setTokens(store)
+  return nil
+}
+
+func setTokens(store githubclient.TokenStore) {
+  tokensMu.Lock()
+  defer tokensMu.Unlock()
+  tokens = store
+}
+
+// LinkingEnabled is true if a GitHub OAuth app is configured.
+func LinkingEnabled(config *config.Config) bool {
+  return config.App().Github.ClientId != ""
+}
+
+// UserToken returns the GitHub token to act as the Slack user with.  It is
+// empty if the bot token, GITHUB_TOKEN, should be used: when linking is
+// off, or when the user has not linked an account and
+// GITHUB_TOKEN_FALLBACK is set.
+func UserToken(config *config.Config, userId string) (string, error) {
+  tokens := Tokens()
+  if !LinkingEnabled(config) || tokens == nil {
+    return "", nil
+  }
+  account, err := tokens.Get(userId)
+  switch err {
+  case nil:
+    return account.Token, nil
+  case githubclient.ErrNotLinked:
+    if config.App().Github.TokenFallback {
+      return "", nil
+    }
+    return "", errors.New("Link your GitHub account first, with /devhub login")
+  default:
+    return "", err
+  }
+}
+
+// withUserToken returns a context that makes NewGithubClient act as the
+// Slack user.
+func withUserToken(ctx context.Context, config *config.Config, userId string) (context.Context, *StatusError) {
+  token, err := UserToken(config, userId)
+  if err != nil {
+    return nil, &StatusError{http.StatusForbidden, err}
+  }
+  if token == "" {
+    return ctx, nil
+  }
+  return githubclient.WithToken(ctx, token), nil
+}
+
+// ActAsUser is Middleware that makes GitHub calls as the Slack user's
+// linked account.
+func ActAsUser(next ContextHandlerFunc) ContextHandlerFunc {
+  return func(ctx context.Context, config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError) {
+    userCtx, statusErr := withUserToken(ctx, config, sReq.UserId)
+    if statusErr != nil {
+      return nil, statusErr
+    }
+    return next(userCtx, config, sReq, command)
+  }
+}
+
+// AsUser is ActAsUser for interactive actions.
+func AsUser(h ActionHandlerFunc) ActionHandlerFunc {
+  return func(ctx context.Context, config *config.Config, interaction *slack.Interaction, action *slack.Action) (*slack.Response, *StatusError) {
+    userCtx, statusErr := withUserToken(ctx, config, interaction.User.Id)
+    if statusErr != nil {
+      return nil, statusErr
+    }
+    return h(userCtx, config, interaction, action)
+  }
+}
+
+// HandleLogin replies with a link that starts the login.  The user first
+// signs in with Slack, so the link only works for the Slack user it was
+// made for, and then authorizes the GitHub OAuth app.  The link's state
+// carries a nonce, kept in LoginsBucket until LinkAccount uses it up.
+func HandleLogin(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+  tokens := Tokens()
+  if !LinkingEnabled(config) || tokens == nil {
+    return nil, errors.New("GitHub account linking is not set up.  Ask an admin to set " + githubclient.ClientId)
+  }
+  s := store.FromContext(ctx)
+  if s == nil {
+    return nil, errors.New("GitHub account linking needs a store, and none is set up")
+  }
+
+  now := time.Now()
+  sweepLogins(s, now)
+  nonce, err := githubclient.NewNonce()
+  if err != nil {
+    return nil, err
+  }
+  login := githubclient.LoginState{UserId: sReq.UserId, Nonce: nonce, Expires: now.Add(githubclient.StateTTL)}
+  if err := store.PutJSON(s, LoginsBucket, nonce, &pendingLogin{login.UserId, login.Expires}); err != nil {
+    return nil, fmt.Errorf("Could not start the login: %s", err.Error())
+  }
+  state := githubclient.SignState(config.App().Github.ClientSecret, login)
+  url := SignInConfig(config).AuthCodeURL(state)
+
+  title := "Link your GitHub account"
+  if account, err := tokens.Get(sReq.UserId); err == nil {
+    title = fmt.Sprintf("You are linked to GitHub as %s.  To link another account:", account.Login)
+  }
+  att := slack.Attachment {
+    Title: "Sign in with Slack, then authorize on GitHub",
+    TitleLink: url,
+    Fallback: url,
+    Text: fmt.Sprintf("The link works once, and only for you.  It expires in %d minutes.", int(githubclient.StateTTL.Minutes())),
+    Color: slack.GOOD,
+  }
+  return &slack.Response{Type: slack.Ephemeral.String(), Text: &title, Attachments: slack.Attachments{att}}, nil
+}
+
+// HandleLogout forgets the Slack user's GitHub account.
+func HandleLogout(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+  tokens := Tokens()
+  if !LinkingEnabled(config) || tokens == nil {
+    return nil, errors.New("GitHub account linking is not set up")
+  }
+  if err := tokens.Delete(sReq.UserId); err != nil {
+    return nil, fmt.Errorf("Could not unlink your account: %s", err.Error())
+  }
+  text := "Your GitHub account is no longer linked.  You can also revoke DevHub under Settings > Applications on GitHub."
+  return &slack.Response{Type: slack.Ephemeral.String(), Text: &text}, nil
+}
+
+// OAuthConfig is the GitHub OAuth app from the config.
+func OAuthConfig(config *config.Config) *oauth2.Config {
+  githubConfig := config.App().Github
+  return githubclient.OAuthConfig(githubConfig.ClientId, githubConfig.ClientSecret, githubConfig.RedirectUrl)
+}
+
+// SignInConfig is Sign in with Slack, with the Slack app from the config.
+func SignInConfig(config *config.Config) *oauth2.Config {
+  app := config.App()
+  return slack.SignInConfig(app.Slack.ClientId, app.Slack.ClientSecret, app.Slack.RedirectUrl, app.Slack.ApiUrl)
+}
+
+// LoginsBucket is the store bucket that logins wait in, from /devhub login
+// until the account is linked.  The key is the nonce.
+const LoginsBucket = "github_logins"
+
+// pendingLogin is the value kept in LoginsBucket.
+type pendingLogin struct {
+  UserId string
+  Expires time.Time
+}
+
+// loginsMu makes using up a nonce atomic.  The store has no delete that
+// says whether the key was there.
+var loginsMu sync.Mutex
+
+// checkLogin returns an error if login's nonce is not waiting in s, for
+// login's user.
+func checkLogin(s store.Store, login githubclient.LoginState, now time.Time) error {
+  var pending pendingLogin
+  switch err := store.GetJSON(s, LoginsBucket, login.Nonce, &pending); err {
+  case nil:
+  case store.ErrNotFound:
+    return errors.New("This login link has already been used.  Run /devhub login again.")
+  default:
+    return fmt.Errorf("Could not read the login: %s", err.Error())
+  }
+  if pending.UserId != login.UserId {
+    return errors.New("This login link is for another Slack user")
+  }
+  if now.After(pending.Expires) {
+    return errors.New("The login link has expired.  Run /devhub login again.")
+  }
+  return nil
+}
+
+// useLogin is checkLogin, and then removes the nonce, so that it can not be
+// used again.
+func useLogin(s store.Store, login githubclient.LoginState, now time.Time) error {
+  loginsMu.Lock()
+  defer loginsMu.Unlock()
+  if err := checkLogin(s, login, now); err != nil {
+    return err
+  }
+  if err := s.Delete(LoginsBucket, login.Nonce); err != nil {
+    return fmt.Errorf("Could not use up the login: %s", err.Error())
+  }
+  return nil
+}
+
+// sweepLogins removes the logins that expired without being used.  It is
+// best effort; a login that is missed is removed the next time.
+func sweepLogins(s store.Store, now time.Time) {
+  var expired []string
+  s.ForEach(LoginsBucket, func(nonce string, value []byte) error {
+    var pending pendingLogin
+    if json.Unmarshal(value, &pending) != nil || now.After(pending.Expires) {
+      expired = append(expired, nonce)
+    }
+    return nil
+  })
+  for _, nonce := range expired {
+    s.Delete(LoginsBucket, nonce)
+  }
+}
+
+// SignInWithSlack is the middle of the login.  It checks that the Slack
+// user who signed in is the one the state was made for, and returns the
+// value of the githubclient.SessionCookie to set, and the GitHub url to
+// send them to next.
+func SignInWithSlack(ctx context.Context, config *config.Config, code string, state string) (string, string, error) {
+  s := store.FromContext(ctx)
+  if !LinkingEnabled(config) || Tokens() == nil || s == nil {
+    return "", "", errors.New("GitHub account linking is not set up")
+  }
+  secret := config.App().Github.ClientSecret
+  now := time.Now()
+  login, err := githubclient.VerifyState(secret, state, now)
+  if err != nil {
+    return "", "", err
+  }
+  if err := checkLogin(s, login, now); err != nil {
+    return "", "", err
+  }
+
+  token, err := SignInConfig(config).Exchange(ctx, code)
+  if err != nil {
+    return "", "", fmt.Errorf("Slack did not accept the sign in: %s", err.Error())
+  }
+  user, err := slack.NewWebClient(token.AccessToken, config.App().Slack.ApiUrl).OpenIDUserInfo(ctx)
+  if err != nil {
+    return "", "", fmt.Errorf("Could not find who signed in to Slack: %s", err.Error())
+  }
+  if user.UserId != login.UserId {
+    return "", "", errors.New("This login link is for another Slack user")
+  }
+  return githubclient.SignSession(secret, login), OAuthConfig(config).AuthCodeURL(state), nil
+}
+
+// LinkAccount finishes the login: it checks that the state and the session
+// cookie are from the same login, uses up its nonce, trades the code for a
+// token, and stores the token for the Slack user.  It returns the GitHub
+// login.
+func LinkAccount(ctx context.Context, config *config.Config, code string, state string, session string) (string, error) {
+  tokens := Tokens()
+  s := store.FromContext(ctx)
+  if !LinkingEnabled(config) || tokens == nil || s == nil {
+    return "", errors.New("GitHub account linking is not set up")
+  }
+  secret := config.App().Github.ClientSecret
+  now := time.Now()
+  login, err := githubclient.VerifyState(secret, state, now)
+  if err != nil {
+    return "", err
+  }
+  signedIn, err := githubclient.VerifySession(secret, session, now)
+  if err != nil {
+    return "", err
+  }
+  if signedIn.UserId != login.UserId || signedIn.Nonce != login.Nonce {
+    return "", errors.New("This login was started by another Slack user, or in another browser.  Run /devhub login again.")
+  }
+  if err := useLogin(s, login, now); err != nil {
+    return "", err
+  }
+
+  token, err := OAuthConfig(config).Exchange(ctx, code)
+  if err != nil {
+    return "", fmt.Errorf("GitHub did not accept the login: %s", err.Error())
+  }
+  client, err := githubclient.NewClient(ctx, token.AccessToken, config.App().Github.ApiUrl)
+  if err != nil {
+    return "", err
+  }
+  user, err := GetUser(client)
+  if err != nil || user == nil {
+    return "", fmt.Errorf("Could not find your GitHub login: %v", err)
+  }
+  if err := tokens.Put(login.UserId, &githubclient.LinkedAccount{Login: *user, Token: token.AccessToken}); err != nil {
+    return "", fmt.Errorf("Could not store the token: %s", err.Error())
+  }
+  return *user, nil
+}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+  "fmt"
+  "strings"
+  "context"
+  "net/http"
+  "github.com/google/go-github/github"
+  . "github.com/confyrm/gorest/errors"
+  . "github.com/confyrm/gorest/slack/command"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack"
+)
+
+// Action keys for the buttons on issue attachments.
+const (
+  IssueCallback = "issue"
+  CloseIssue = "issue_close"
+  AssignIssue = "issue_assign"
+)
+
+// GithubUsers is the config key for a map of Slack user id (or name) to
+// GitHub login.  It is used by "Assign to me".
+const GithubUsers = "SLACK_GITHUB_USERS"
+
+// This is the list of actions that is given to InteractiveRouter.  Each
+// action is keyed by action_id for Block Kit, the action name for legacy
+// attachments, or the callback_id for views and shortcuts.
+var InteractiveActions = Actions {
+  Action{
+    CloseIssue,
+    true,
+    AsUser(HandleCloseAction),
+  },
+  Action{
+    AssignIssue,
+    true,
+    AsUser(HandleAssignAction),
+  },
+  Action{
+    MergePullRequest,
+    true,
+    AsUser(HandleMergeAction),
+  },
+  Action{
+    CancelMerge,
+    false,
+    HandleCancelMergeAction,
+  },
+  Action{
+    IssuePage,
+    true,
+    AsUser(HandlePageAction),
+  },
+  Action{
+    NewIssueModal,
+    true,
+    AsUser(HandleNewIssueSubmission),
+  },
+}
+
+// IssueRef returns "owner/repo#number" for the issue.  It is used as the
+// value of issue buttons, so the action handler knows which issue to act on.
+func IssueRef(issue *github.Issue) string {
+  // HTMLURL looks like https://github.com/owner/repo/issues/4
+  parts := strings.Split(GetSafeString(issue.HTMLURL), "/")
+  if len(parts) < 4 {
+    return ""
+  }
+  return fmt.Sprintf("%s/%s#%d", parts[len(parts)-4], parts[len(parts)-3], GetSafeInt(issue.Number))
+}
+
+// ParseIssueRef is the reverse of IssueRef.
+func ParseIssueRef(ref string) (string, string, int, error) {
+  var owner, repo string
+  var number int
+  hash := strings.LastIndex(ref, "#")
+  slash := strings.Index(ref, "/")
+  if hash < 0 || slash < 0 || slash > hash {
+    return "", "", -1, fmt.Errorf("Bad issue reference: %s", ref)
+  }
+  owner = ref[:slash]
+  repo = ref[slash+1:hash]
+  if _, err := fmt.Sscanf(ref[hash+1:], "%d", &number); err != nil || owner == "" || repo == "" {
+    return "", "", -1, fmt.Errorf("Bad issue reference: %s", ref)
+  }
+  return owner, repo, number, nil
+}
+
+// IssueActions returns the buttons for an issue attachment.
+func IssueActions(issue *github.Issue) slack.AttachmentActions {
+  ref := IssueRef(issue)
+  if ref == "" {
+    return nil
+  }
+  actions := slack.AttachmentActions{}
+  if GetSafeString(issue.State) != "closed" {
+    actions = append(actions, slack.AttachmentAction {
+      Name: CloseIssue,
+      Text: "Close",
+      Type: "button",
+      Value: ref,
+      Style: slack.DANGER,
+      Confirm: &slack.Confirmation {
+        Title: "Close this issue?",
+        Text: fmt.Sprintf("This will close %s for everyone.", ref),
+        OkText: "Close it",
+        DismissText: "Never mind",
+      },
+    })
+  }
+  actions = append(actions, slack.AttachmentAction {
+    Name: AssignIssue,
+    Text: "Assign to me",
+    Type: "button",
+    Value: ref,
+  })
+  return actions
+}
+
+// GithubLogin maps a Slack user to a GitHub login, using SLACK_GITHUB_USERS.
+func GithubLogin(config *config.Config, userId string, userName string) (string, error) {
+  // Viper lower cases map keys.
+  users := config.App().Slack.GithubUsers
+  if login, ok := users[strings.ToLower(userId)]; ok {
+    return login, nil
+  }
+  if login, ok := users[strings.ToLower(userName)]; ok {
+    return login, nil
+  }
+  return "", fmt.Errorf("No GitHub user is known for %s.  Ask an admin to add you to %s.", userName, GithubUsers)
+}
+
+// HandleCloseAction closes the issue behind a "Close" button.
+func HandleCloseAction(ctx context.Context, config *config.Config, interaction *slack.Interaction, action *slack.Action) (*slack.Response, *StatusError) {
+  owner, repo, number, err := ParseIssueRef(action.SelectedValue())
+  if err != nil {
+    return nil, &StatusError{http.StatusBadRequest, err}
+  }
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, &StatusError{http.StatusInternalServerError, err}
+  }
+  state := "closed"
+  issue, _, err := client.Issues.Edit(owner, repo, number, &github.IssueRequest{State: &state})
+  if err != nil {
+    return nil, &StatusError{http.StatusBadGateway, fmt.Errorf("Issue close failed with %s", err.Error())}
+  }
+
+  title := fmt.Sprintf("<@%s|%s> closed an issue", interaction.User.Id, interaction.UserName())
+  return IssueResponse(config, slack.InChannel, title, issue, true), nil
+}
+
+// HandleAssignAction assigns the issue behind an "Assign to me" button to
+// the GitHub user of whoever clicked it.
+func HandleAssignAction(ctx context.Context, config *config.Config, interaction *slack.Interaction, action *slack.Action) (*slack.Response, *StatusError) {
+  owner, repo, number, err := ParseIssueRef(action.SelectedValue())
+  if err != nil {
+    return nil, &StatusError{http.StatusBadRequest, err}
+  }
+  login, err := GithubLogin(config, interaction.User.Id, interaction.UserName())
+  if err != nil {
+    return nil, &StatusError{http.StatusForbidden, err}
+  }
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, &StatusError{http.StatusInternalServerError, err}
+  }
+  issue, _, err := client.Issues.Edit(owner, repo, number, &github.IssueRequest{Assignee: &login})
+  if err != nil {
+    return nil, &StatusError{http.StatusBadGateway, fmt.Errorf("Issue assign failed with %s", err.Error())}
+  }
+
+  title := fmt.Sprintf("<@%s|%s> took an issue", interaction.User.Id, interaction.UserName())
+  return IssueResponse(config, slack.InChannel, title, issue, true), nil
+}
@@ -0,0 +1,165 @@
+package commands
+
+import (
+  "fmt"
+  "time"
+  "errors"
+  "strings"
+  "context"
+  "net/http"
+  "github.com/confyrm/gorest/store"
+  . "github.com/confyrm/gorest/errors"
+  . "github.com/confyrm/gorest/slack/command"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack"
+)
+
+// ChannelRepos is the config key for a map of Slack channel id to
+// "owner/repo".  It is used for channels that have not been set up with
+// /devhub config set.
+const ChannelRepos = "SLACK_CHANNEL_REPOS"
+
+// ChannelsBucket is the store bucket that channel defaults are kept in.
+const ChannelsBucket = "channels"
+
+// ChannelConfig is the default owner and repo of a Slack channel.  Either
+// may be empty, and then the global default is used.
+type ChannelConfig struct {
+  Owner string `json:"owner,omitempty"`
+  Repo string `json:"repo,omitempty"`
+  SetBy string `json:"set_by,omitempty"`
+  SetAt time.Time `json:"set_at,omitempty"`
+}
+
+// LookupChannel returns the defaults for channel, from the store if they
+// were set there, and from SLACK_CHANNEL_REPOS if not.  ok is false if
+// the channel has no defaults.  s may be nil.
+func LookupChannel(s store.Store, config *config.Config, channel string) (*ChannelConfig, bool, error) {
+  if s != nil {
+    cc := &ChannelConfig{}
+    switch err := store.GetJSON(s, ChannelsBucket, channel, cc); err {
+    case nil:
+      return cc, true, nil
+    case store.ErrNotFound:
+    default:
+      return nil, false, err
+    }
+  }
+  // Viper lower cases map keys.
+  if ref, ok := config.App().Slack.ChannelRepos[strings.ToLower(channel)]; ok && ref != "" {
+    owner, repo := SplitRepo(ref)
+    return &ChannelConfig{Owner: owner, Repo: repo}, true, nil
+  }
+  return nil, false, nil
+}
+
+// SplitRepo splits "owner/repo".  owner is empty if there is no slash.
+func SplitRepo(ref string) (string, string) {
+  if i := strings.Index(ref, "/"); i >= 0 {
+    return strings.TrimSpace(ref[:i]), strings.TrimSpace(ref[i+1:])
+  }
+  return "", strings.TrimSpace(ref)
+}
+
+// ChannelDefaults is Middleware that fills in owner and repo from the
+// channel's defaults when the command does not give them.  It runs after
+// validation, and ValidateOwnerAndRepo falls back to the global defaults,
+// so the order is command, then channel, then global.
+func ChannelDefaults(next ContextHandlerFunc) ContextHandlerFunc {
+  return func(ctx context.Context, config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError) {
+    cc, ok, err := LookupChannel(store.FromContext(ctx), config, sReq.ChannelId)
+    if err != nil {
+      return nil, &StatusError{http.StatusInternalServerError, fmt.Errorf("Could not read the channel config: %s", err.Error())}
+    }
+    if ok {
+      // repo=owner/name sets both, so only fill in an owner if the command
+      // gave neither.
+      if cc.Owner != "" && !command.HasValue("owner") && !strings.Contains(command.ValueOrDefault("repo", ""), "/") {
+        command.Params["owner"] = cc.Owner
+      }
+      if cc.Repo != "" && !command.HasValue("repo") {
+        command.Params["repo"] = cc.Repo
+      }
+    }
+    return next(ctx, config, sReq, command)
+  }
+}
+
+// HandleConfigSet sets the channel's default owner and repo.
+func HandleConfigSet(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+  s := store.FromContext(ctx)
+  if s == nil {
+    return nil, errors.New("Channel config needs a store, and none is set up")
+  }
+  owner := command.ValueOrDefault("owner", "")
+  repo := command.ValueOrDefault("repo", "")
+  if refOwner, refRepo := SplitRepo(repo); refOwner != "" {
+    if owner != "" && owner != refOwner {
+      return nil, fmt.Errorf("owner=%s does not match repo=%s", owner, repo)
+    }
+    owner, repo = refOwner, refRepo
+  }
+  if owner == "" && repo == "" {
+    return nil, errors.New("Give a repo, an owner, or both")
+  }
+
+  cc := &ChannelConfig{Owner: owner, Repo: repo, SetBy: sReq.UserId, SetAt: time.Now().UTC()}
+  if err := store.PutJSON(s, ChannelsBucket, sReq.ChannelId, cc); err != nil {
+    return nil, fmt.Errorf("Could not save the channel config: %s", err.Error())
+  }
+  text := fmt.Sprintf("<@%s|%s> set this channel's repo to %s", sReq.UserId, sReq.UserName, FormatChannelRepo(config, cc))
+  return &slack.Response{Type: slack.InChannel.String(), Text: &text}, nil
+}
+
+// HandleConfigGet shows the channel's defaults, and where they came from.
+func HandleConfigGet(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+  cc, ok, err := LookupChannel(store.FromContext(ctx), config, sReq.ChannelId)
+  if err != nil {
+    return nil, fmt.Errorf("Could not read the channel config: %s", err.Error())
+  }
+  var text string
+  switch {
+  case !ok:
+    text = fmt.Sprintf("This channel uses the global default, %s", FormatChannelRepo(config, &ChannelConfig{}))
+  case cc.SetBy != "":
+    text = fmt.Sprintf("This channel uses %s, set by <@%s> on %s", FormatChannelRepo(config, cc), cc.SetBy,
+      cc.SetAt.Format("Jan 2, 2006"))
+  default:
+    text = fmt.Sprintf("This channel uses %s, from %s", FormatChannelRepo(config, cc), ChannelRepos)
+  }
+  return &slack.Response{Type: slack.Ephemeral.String(), Text: &text}, nil
+}
+
+// HandleConfigUnset removes the channel's defaults from the store.
+func HandleConfigUnset(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+  s := store.FromContext(ctx)
+  if s == nil {
+    return nil, errors.New("Channel config needs a store, and none is set up")
+  }
+  if err := s.Delete(ChannelsBucket, sReq.ChannelId); err != nil {
+    return nil, fmt.Errorf("Could not remove the channel config: %s", err.Error())
+  }
+  cc, _, _ := LookupChannel(nil, config, sReq.ChannelId)
+  if cc == nil {
+    cc = &ChannelConfig{}
+  }
+  text := fmt.Sprintf("<@%s|%s> removed this channel's repo.  It now uses %s", sReq.UserId, sReq.UserName,
+    FormatChannelRepo(config, cc))
+  return &slack.Response{Type: slack.InChannel.String(), Text: &text}, nil
+}
+
+// FormatChannelRepo is "owner/repo", with the global defaults filled in.
+func FormatChannelRepo(config *config.Config, cc *ChannelConfig) string {
+  command := &slack.DevHubCommand{slack.Commands{}, slack.KVPairs{}}
+  if cc.Owner != "" {
+    command.Params["owner"] = cc.Owner
+  }
+  if cc.Repo != "" {
+    command.Params["repo"] = cc.Repo
+  }
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return "nothing.  " + err.Error()
+  }
+  return fmt.Sprintf("*%s/%s*", owner, repo)
+}
@@ -9,8 +9,9 @@ import (
 // presented by Slack.  The command handler must be in this "commands" package.
 var SlashCommands = Commands {
   Command{
-    "/devhub",
-    true,
-    DevHub,
+    Name: "/devhub",
+    IsLong: true,
+    Handler: DevHub,
+    Schema: DevHubSchema,
   },
 }
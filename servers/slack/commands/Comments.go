@@ -0,0 +1,238 @@
+package commands
+
+import (
+  "fmt"
+  "log"
+  "regexp"
+  "strings"
+  "context"
+  "net/http"
+  "github.com/google/go-github/github"
+  "github.com/confyrm/gorest/githubclient"
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/router/handler"
+)
+
+// SlackThreadSync is the config key that turns on mirroring of Slack thread
+// replies to GitHub comments.  It is off by default.
+const SlackThreadSync = "SLACK_THREAD_SYNC"
+
+// NewIssueText ends the message HandleNew posts.  Thread sync only mirrors
+// replies to messages that end with it.
+const NewIssueText = "created a new issue!"
+
+// Default and max number of comments shown by comments.
+const (
+  DefaultCommentCount = 5
+  MaxCommentCount = 20
+)
+
+// HandleComment adds a comment to an issue.
+func HandleComment(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return nil, err
+  }
+  number, err := ValidateNumber(command)
+  if err != nil {
+    return nil, err
+  }
+  text, ok := command.Value("body")
+  if !ok || strings.TrimSpace(text) == "" {
+    return nil, fmt.Errorf("Comment body was not provided")
+  }
+
+  body := CommentBody(config, sReq.UserId, sReq.UserName, text)
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
+  comment, _, err := client.Issues.CreateComment(owner, repo, number, &github.IssueComment{Body: &body})
+  if err != nil {
+    return nil, fmt.Errorf("Comment failed with %s", err.Error())
+  }
+
+  title := fmt.Sprintf("<@%s|%s> commented on %s/%s#%d", sReq.UserId, sReq.UserName, owner, repo, number)
+  return &slack.Response{Type: slack.InChannel.String(), Text: &title,
+    Attachments: slack.Attachments{FormatComment(comment)}}, nil
+}
+
+// HandleComments shows the last few comments on an issue.
+func HandleComments(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return nil, err
+  }
+  number, err := ValidateNumber(command)
+  if err != nil {
+    return nil, err
+  }
+  limit := command.ValueToIntOrDefault("limit", DefaultCommentCount)
+  if limit < 1 || limit > MaxCommentCount {
+    return nil, fmt.Errorf("limit must be between 1 and %d", MaxCommentCount)
+  }
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
+  comments, err := LastComments(client, owner, repo, number, limit)
+  if err != nil {
+    return nil, fmt.Errorf("Comment fetch failed with %s", err.Error())
+  }
+
+  title := fmt.Sprintf("Last %d comments on %s/%s#%d", len(comments), owner, repo, number)
+  if len(comments) == 0 {
+    title = fmt.Sprintf("%s/%s#%d has no comments", owner, repo, number)
+  }
+  response := &slack.Response{Type: slack.Ephemeral.String(), Text: &title}
+  for _, comment := range comments {
+    response.Attachments = append(response.Attachments, FormatComment(comment))
+  }
+  return response, nil
+}
+
+// LastComments returns the last limit comments, oldest first.  GitHub
+// lists comments oldest first, so this reads the last page, and the one
+// before it if the last page is short.
+func LastComments(client *githubclient.Client, owner string, repo string, number int, limit int) ([]*github.IssueComment, error) {
+  opt := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: limit}}
+  comments, resp, err := client.Issues.ListComments(owner, repo, number, opt)
+  if err != nil || resp == nil || resp.LastPage <= 1 {
+    return comments, err
+  }
+
+  opt.Page = resp.LastPage
+  last, _, err := client.Issues.ListComments(owner, repo, number, opt)
+  if err != nil {
+    return nil, err
+  }
+  if len(last) < limit {
+    opt.Page = resp.LastPage - 1
+    previous, _, err := client.Issues.ListComments(owner, repo, number, opt)
+    if err != nil {
+      return nil, err
+    }
+    last = append(previous, last...)
+  }
+  if len(last) > limit {
+    last = last[len(last) - limit:]
+  }
+  return last, nil
+}
+
+// FormatComment shows a comment the way FormatIssueDetails shows an issue.
+func FormatComment(comment *github.IssueComment) slack.Attachment {
+  title := fmt.Sprintf("Comment by %s", MakeUserLink(comment.User))
+  if url := GetSafeString(comment.HTMLURL); url != "" {
+    title = fmt.Sprintf("<%s|Comment> by %s", url, MakeUserLink(comment.User))
+  }
+  body := slack.Truncate(GetSafeString(comment.Body), slack.MaxSectionTextLength)
+  att := slack.Attachment {
+    Title: title,
+    Fallback: body,
+    Text: body,
+    MarkdownIn: []string{"title", "text"},
+  }
+  if comment.CreatedAt != nil {
+    att.Footer = comment.CreatedAt.Format("Jan 2, 2006 15:04 MST")
+  }
+  return att
+}
+
+// CommentBody attributes text to the Slack user, since the comment may be
+// made with the bot's GitHub token.  The user's GitHub login is added if
+// SLACK_GITHUB_USERS knows it.
+func CommentBody(config *config.Config, userId string, userName string, text string) string {
+  author := userName
+  if login, err := GithubLogin(config, userId, userName); err == nil {
+    author = fmt.Sprintf("%s (@%s)", userName, login)
+  }
+  return fmt.Sprintf("%s\n\n_Posted from Slack by %s_", text, author)
+}
+
+// issueUrl finds GitHub issue urls in message JSON.  Slack escapes the
+// slashes, so they are unescaped before matching.
+var issueUrl = regexp.MustCompile(`https://github\.com/[^/\s"|>]+/[^/\s"|>]+/issues/[0-9]+`)
+
+// ThreadIssue finds the issue a HandleNew message is about.  ok is false if
+// parent is not such a message.
+func ThreadIssue(parent *slack.Event) (owner string, repo string, number int, ok bool) {
+  if parent.BotId == "" || !strings.HasSuffix(strings.TrimSpace(parent.Text), NewIssueText) {
+    return "", "", -1, false
+  }
+  raw := strings.Replace(string(parent.Raw), `\/`, "/", -1)
+  url := issueUrl.FindString(raw)
+  if url == "" {
+    return "", "", -1, false
+  }
+  owner, repo, number, err := ParseIssueUrl(url)
+  if err != nil {
+    return "", "", -1, false
+  }
+  return owner, repo, number, true
+}
+
+// WantsThreadReply is true for a message HandleThreadReply might mirror: a
+// plain reply in a thread from a person, with SLACK_THREAD_SYNC on.  Bots,
+// edits and deletes have a bot_id or a subtype.  Our own messages are bot
+// messages, so this can't loop.
+func WantsThreadReply(config *config.Config, envelope *slack.EventEnvelope) bool {
+  event := envelope.Event
+  return config.App().Slack.ThreadSync && event.ThreadTs != "" && event.ThreadTs != event.Ts &&
+    event.SubType == "" && event.BotId == "" && event.User != ""
+}
+
+// HandleThreadReply mirrors replies in the thread under a HandleNew message
+// as comments on the new issue.  It needs SLACK_THREAD_SYNC, the
+// message.channels event, and the channels:history and users:read scopes.
+func HandleThreadReply(ctx context.Context, config *config.Config, envelope *slack.EventEnvelope) *StatusError {
+  event := envelope.Event
+  if !WantsThreadReply(config, envelope) {
+    return nil
+  }
+
+  sc := NewSlackClient(config)
+  messages, err := sc.ConversationsReplies(ctx, event.Channel, event.ThreadTs, 1)
+  if err != nil {
+    return &StatusError{http.StatusBadGateway, err}
+  }
+  if len(messages) == 0 {
+    return nil
+  }
+  owner, repo, number, ok := ThreadIssue(&messages[0])
+  if !ok {
+    return nil
+  }
+
+  userName := event.User
+  if user, err := sc.UsersInfo(ctx, event.User); err == nil {
+    userName = user.Name
+    if user.RealName != "" {
+      userName = user.RealName
+    }
+  } else {
+    log.Printf("[%s] Could not look up Slack user %s: %s", handler.RequestID(ctx), event.User, err.Error())
+  }
+
+  // Comment as the user's linked account, if they have one.
+  userCtx, statusErr := withUserToken(ctx, config, event.User)
+  if statusErr != nil {
+    log.Printf("[%s] Not mirroring a reply from %s: %s", handler.RequestID(ctx), event.User, statusErr.Err.Error())
+    return nil
+  }
+  body := CommentBody(config, event.User, userName, event.Text)
+  client, err := NewGithubClient(userCtx, config)
+  if err != nil {
+    return &StatusError{http.StatusInternalServerError, err}
+  }
+  if _, _, err := client.Issues.CreateComment(owner, repo, number, &github.IssueComment{Body: &body}); err != nil {
+    return &StatusError{http.StatusBadGateway, fmt.Errorf("Comment failed with %s", err.Error())}
+  }
+  log.Printf("[%s] Mirrored a thread reply to %s/%s#%d", handler.RequestID(ctx), owner, repo, number)
+  return nil
+}
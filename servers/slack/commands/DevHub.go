@@ -4,70 +4,90 @@ import (
   "fmt"
   "log"
   "errors"
-  "golang.org/x/oauth2"
+  "context"
+  "unicode"
+  "net/http"
+  "unicode/utf8"
   "github.com/google/go-github/github"
   //"gopkg.in/libgit2/git2go.v22"
   . "github.com/confyrm/gorest/errors"
   "github.com/confyrm/gorest/config"
   "github.com/confyrm/gorest/slack"
+  . "github.com/confyrm/gorest/slack/command"
   "github.com/confyrm/gorest/githubclient"
+  "github.com/confyrm/gorest/router/handler"
 )
 
-func DevHub(config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError) {
-  log.Printf("DevHub was called")
+// DevHub is the /devhub command handler.  Subcommands are declared, and
+// routed, by DevHubSchema.  To add a subcommand, add it to the schema.
+func DevHub(ctx context.Context, config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError) {
+  log.Printf("[%s] DevHub was called", handler.RequestID(ctx))
+  return DevHubSchema.Dispatch(ctx, config, sReq, command)
+}
 
-  if len(command.Commands) < 1 {
-    RespondWithError(sReq, errors.New("No devhub command specified"))
+// RespondToUser is middleware for long running commands.  The response, or
+// the error, is posted to the response_url, since the slash command request
+// has already been answered.
+func RespondToUser(next ContextHandlerFunc) ContextHandlerFunc {
+  return func(ctx context.Context, config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError) {
+    resp, statusErr := next(ctx, config, sReq, command)
+    if statusErr != nil {
+      RespondWithError(ctx, sReq, statusErr.Err)
+      // The user has already been told.  Returning the error just lets the
+      // job pool record the outcome.
+      return nil, statusErr
+    }
+    if resp != nil {
+      RespondWithSuccess(ctx, sReq, resp)
+    }
+    return nil, nil
   }
+}
 
-  var (
-    resp *slack.Response
-    err error
-  )
+// IssueHandlerFunc is the signature of the Handle* subcommand handlers.
+type IssueHandlerFunc func(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error)
 
-  cmd := command.Commands[0]
-  switch (cmd) {
-    case "new":
-      resp, err = HandleNew(sReq, config, command)
-    case "get":
-      resp, err = HandleGet(sReq, config, command)
-    case "close":
-      resp, err = HandleClose(sReq, config, command)
-    case "update":
-      resp, err = HandleUpdate(sReq, config, command)
-    default:
-      err = fmt.Errorf("Command not recognized: %s", cmd)
+// IssueHandler lets an IssueHandlerFunc be used as a Subcommand Handler.
+// Errors are returned as a 400.
+func IssueHandler(h IssueHandlerFunc) ContextHandlerFunc {
+  return func(ctx context.Context, config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError) {
+    resp, err := h(ctx, sReq, config, command)
+    if err != nil {
+      return nil, &StatusError{http.StatusBadRequest, err}
+    }
+    return resp, nil
   }
+}
 
-  if err != nil {
-    RespondWithError(sReq, err)
-  } else {
-    RespondWithSuccess(sReq, resp)
-  }
+func RespondWithSuccess(ctx context.Context, sReq *slack.Request, response *slack.Response ) {
 
-  // Since this is a long running command, just return (nil, nil)
-  return nil, nil
+  if err := sReq.RespondWithContext(ctx, response); err != nil {
+    log.Printf("[%s] Error sending error response: %s\n\n", handler.RequestID(ctx), err.Error())
+  }
 }
 
-func RespondWithSuccess(sReq *slack.Request, response *slack.Response ) {
-
-  if err := sReq.Respond(response); err != nil {
-    log.Printf("Error sending error response: %s\n\n", err.Error())
+func RespondWithError(ctx context.Context, sReq *slack.Request, err error) {
+  if errr := sReq.RespondWithContext(ctx, ErrorResponse(err)); errr != nil {
+    log.Printf("[%s] Error sending error response: %s %s", handler.RequestID(ctx), err.Error(), errr.Error())
   }
 }
 
-func RespondWithError(sReq *slack.Request, err error) {
+// ErrorResponse is the ephemeral response used to tell the user that their
+// command failed.  Parse errors point at where the problem is.
+func ErrorResponse(err error) *slack.Response {
+  text := err.Error()
+  if perr, ok := err.(*slack.ParseError); ok {
+    text = fmt.Sprintf("%s\n```%s```", text, perr.Pointer())
+  }
   var atts = slack.Attachments {
     slack.Attachment {
       Title: "Oh snap! Something went wrong!",
-      Text: err.Error(),
+      Text: text,
       Color: slack.DANGER,
+      MarkdownIn: []string{"text"},
     },
   }
-  response := slack.Response{slack.Ephemeral.String(), nil, atts}
-  if errr := sReq.Respond(&response); err != nil {
-    log.Printf("Error sending error response: %s %s", err.Error(), errr.Error())
-  }
+  return &slack.Response{Type: slack.Ephemeral.String(), Attachments: atts}
 }
 
 func ValidateNumber(command *slack.DevHubCommand) (int, error) {
@@ -82,14 +102,26 @@ func ValidateNumber(command *slack.DevHubCommand) (int, error) {
   return -1, fmt.Errorf("Issue number was not provided")
 }
 
-// ValidateOwnerAndRepo looks in the command for the owner and repo.
-// If none are provided, and no default was provided in the config, then it's an error
+// ValidateOwnerAndRepo looks in the command for the owner and repo.  The
+// repo can also be given as repo=owner/name.  ChannelDefaults has already
+// filled in the channel's defaults, so anything still missing comes from
+// the config.  If none are provided, and no default was provided in the
+// config, then it's an error
 func ValidateOwnerAndRepo(config *config.Config, command *slack.DevHubCommand) (string, string, error) {
-  owner := config.GetString(githubclient.DefaultOwner)
+  owner, repo := SplitRepo(command.ValueOrDefault("repo", ""))
+  if len(owner) > 0 {
+    if given, ok := command.Value("owner"); ok && given != owner {
+      return "", "", fmt.Errorf("owner=%s does not match repo=%s/%s", given, owner, repo)
+    }
+  } else {
+    owner = command.ValueOrDefault("owner", config.App().Github.DefaultOwner)
+  }
   if len(owner) == 0 {
     return "", "", errors.New("Could not find a GitHub owner in the command or the config")
   }
-  repo := command.ValueOrDefault("repo", config.GetString(githubclient.DefaultRepo))
+  if len(repo) == 0 {
+    repo = config.App().Github.DefaultRepo
+  }
   if len(repo) == 0 {
     return "", "", errors.New("Could not find a GitHub repo in the command or the config")
   }
@@ -101,6 +133,16 @@ func ValidateOwnerAndRepo(config *config.Config, command *slack.DevHubCommand) (
 // https://godoc.org/github.com/google/go-github/github#IssuesService.Get
 // https://github.com/google/go-github/blob/master/github/issues.go
 
+// Capitalize upper cases the first letter of s, such as "Squash" for
+// "squash".
+func Capitalize(s string) string {
+  if s == "" {
+    return s
+  }
+  r, size := utf8.DecodeRuneInString(s)
+  return string(unicode.ToUpper(r)) + s[size:]
+}
+
 func GetSafeString(ptr *string) string {
   if ptr != nil {
     return *ptr
@@ -165,6 +207,8 @@ func FormatBasicIssue(issue *github.Issue) slack.Attachment {
     Text: issueBody,
     Color: slack.GOOD,
     MarkdownIn: []string{"title", "text"},
+    CallbackId: IssueCallback,
+    Actions: IssueActions(issue),
   }
   return att
 }
@@ -184,7 +228,7 @@ func FormatIssueDetails(issue *github.Issue) slack.Attachment {
   }
   return att
 }
-func HandleGet(sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+func HandleGet(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
 
   owner, repo, err := ValidateOwnerAndRepo(config, command)
   if err != nil {
@@ -197,7 +241,10 @@ func HandleGet(sReq *slack.Request, config *config.Config, command *slack.DevHub
     return nil, err
   }
 
-  client := NewGithubClient(config)
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
 
   issue, _, err := client.Issues.Get(owner, repo, number)
 	if err != nil {
@@ -206,21 +253,21 @@ func HandleGet(sReq *slack.Request, config *config.Config, command *slack.DevHub
 	}
 
 
-  atts := slack.Attachments {
-    FormatBasicIssue(issue),
-    FormatIssueDetails(issue),
-  }
   title := "Get Issue"
-  response := slack.Response{slack.Ephemeral.String(), &title, atts}
-  return &response, nil
+  return IssueResponse(config, slack.Ephemeral, title, issue, true), nil
 }
 
 // HandleNew creates a new GitHub issue, using the Key/Value data in the DevHubCommand
-func HandleNew(sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+func HandleNew(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
 
   owner, repo, err := ValidateOwnerAndRepo(config, command)
   if err != nil {
-    return nil, errors.New("Could not find a GitHub owner in the command or the config")
+    return nil, err
+  }
+
+  // A bare "/devhub new" opens a form instead.  See OpenNewIssueModal.
+  if WantsModal(command) {
+    return nil, OpenNewIssueModal(ctx, sReq, config, owner, repo)
   }
 
   // Validate that we have all required data
@@ -228,7 +275,10 @@ func HandleNew(sReq *slack.Request, config *config.Config, command *slack.DevHub
     return nil, fmt.Errorf("Issue title was not provided")
   }
 
-  client := NewGithubClient(config)
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
   input := TextToIssueRequest(command)
 
   issue, _, err := client.Issues.Create(owner, repo, input)
@@ -237,16 +287,12 @@ func HandleNew(sReq *slack.Request, config *config.Config, command *slack.DevHub
     return nil, errr
 	}
 
-  atts := slack.Attachments {
-    FormatBasicIssue(issue),
-  }
-  title := fmt.Sprintf("<@%s|%s> created a new issue!", sReq.UserId, sReq.UserName)
-  response := slack.Response{slack.InChannel.String(), &title, atts}
-  return &response, nil
+  title := fmt.Sprintf("<@%s|%s> %s", sReq.UserId, sReq.UserName, NewIssueText)
+  return IssueResponse(config, slack.InChannel, title, issue, false), nil
 }
 
 // HandleClose creates a new GitHub issue, using the Key/Value data in the DevHubCommand
-func HandleClose(sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+func HandleClose(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
 
   owner, repo, err := ValidateOwnerAndRepo(config, command)
   if err != nil {
@@ -259,7 +305,10 @@ func HandleClose(sReq *slack.Request, config *config.Config, command *slack.DevH
     return nil, err
   }
 
-  client := NewGithubClient(config)
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
   closeCommand := slack.DevHubCommand {slack.Commands{}, slack.KVPairs{"state":"closed"}}
   input := TextToIssueRequest( &closeCommand)
 
@@ -269,17 +318,12 @@ func HandleClose(sReq *slack.Request, config *config.Config, command *slack.DevH
     return nil, errr
 	}
 
-  atts := slack.Attachments {
-    FormatBasicIssue(issue),
-    FormatIssueDetails(issue),
-  }
   title := "Update Issue"
-  response := slack.Response{slack.Ephemeral.String(), &title, atts}
-  return &response, nil
+  return IssueResponse(config, slack.Ephemeral, title, issue, true), nil
 }
 
 // HandleUpdate creates a new GitHub issue, using the Key/Value data in the DevHubCommand
-func HandleUpdate(sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+func HandleUpdate(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
 
   owner, repo, err := ValidateOwnerAndRepo(config, command)
   if err != nil {
@@ -292,7 +336,10 @@ func HandleUpdate(sReq *slack.Request, config *config.Config, command *slack.Dev
     return nil, err
   }
 
-  client := NewGithubClient(config)
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
   input := TextToIssueRequest(command)
 
   issue, _, err := client.Issues.Edit(owner, repo, number, input)
@@ -301,29 +348,86 @@ func HandleUpdate(sReq *slack.Request, config *config.Config, command *slack.Dev
     return nil, errr
 	}
 
-  atts := slack.Attachments {
-    FormatBasicIssue(issue),
-    FormatIssueDetails(issue),
-  }
   title := "Update Issue"
-  response := slack.Response{slack.Ephemeral.String(), &title, atts}
-  return &response, nil
+  return IssueResponse(config, slack.Ephemeral, title, issue, true), nil
+}
+
+
+// HandleLabelAdd adds labels to an issue.
+func HandleLabelAdd(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return nil, err
+  }
+  number, err := ValidateNumber(command)
+  if err != nil {
+    return nil, err
+  }
+  labels, _ := command.Values("labels")
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
+  if _, _, err := client.Issues.AddLabelsToIssue(owner, repo, number, labels); err != nil {
+    return nil, fmt.Errorf("Adding labels failed with %s", err.Error())
+  }
+  issue, _, err := client.Issues.Get(owner, repo, number)
+  if err != nil {
+    return nil, fmt.Errorf("Issue fetch failed with %s", err.Error())
+  }
+
+  title := "Labels Added"
+  return IssueResponse(config, slack.Ephemeral, title, issue, true), nil
 }
 
+// HandleLabelRemove removes labels from an issue.
+func HandleLabelRemove(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
 
-// NewGithubClient is a utility function that uses the GITHUB_TOKEN from
-// the config to create a GitHub client.
-func NewGithubClient(config *config.Config) *github.Client {
-  ts := oauth2.StaticTokenSource(
-    &oauth2.Token{AccessToken: config.GetString("GITHUB_TOKEN")},
-  )
-  tc := oauth2.NewClient(oauth2.NoContext, ts)
-  client := github.NewClient(tc)
-  return client
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return nil, err
+  }
+  number, err := ValidateNumber(command)
+  if err != nil {
+    return nil, err
+  }
+  labels, _ := command.Values("labels")
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
+  for _, label := range labels {
+    if _, err := client.Issues.RemoveLabelForIssue(owner, repo, number, label); err != nil {
+      return nil, fmt.Errorf("Removing label %s failed with %s", label, err.Error())
+    }
+  }
+  issue, _, err := client.Issues.Get(owner, repo, number)
+  if err != nil {
+    return nil, fmt.Errorf("Issue fetch failed with %s", err.Error())
+  }
+
+  title := "Labels Removed"
+  return IssueResponse(config, slack.Ephemeral, title, issue, true), nil
 }
 
+// NewGithubClient is a utility function that creates a GitHub client.  It
+// acts as the Slack user if ActAsUser (or AsUser) put their linked token in
+// ctx, and as the bot, with the GITHUB_TOKEN from the config, otherwise.
+// Calls made with the client are cancelled along with ctx.  GITHUB_API_URL
+// points it somewhere other than api.github.com.
+func NewGithubClient(ctx context.Context, config *config.Config) (*githubclient.Client, error) {
+  token, ok := githubclient.TokenFromContext(ctx)
+  if !ok {
+    token = config.App().Github.Token
+  }
+  return githubclient.NewClient(ctx, token, config.App().Github.ApiUrl)
+}
 
-func GetUser(client *github.Client) (*string, error) {
+
+func GetUser(client *githubclient.Client) (*string, error) {
 
   user, _, err := client.Users.Get("")
   if err != nil {
@@ -343,7 +447,7 @@ func TextToIssueRequest(command *slack.DevHubCommand) *github.IssueRequest {
     input.Body = &i
   }
   if i, ok := command.Value("assignee"); ok {
-    input.Body = &i
+    input.Assignee = &i
   }
 
   if i, ok := command.Values("labels"); ok {
@@ -0,0 +1,89 @@
+package commands
+
+import (
+  "fmt"
+  "log"
+  "strings"
+  "context"
+  "net/http"
+  . "github.com/confyrm/gorest/errors"
+  . "github.com/confyrm/gorest/slack/command"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/router/handler"
+)
+
+// Config keys for the Slack Web API.
+const (
+  // SlackBotToken is the xoxb- token used for Web API calls.
+  SlackBotToken = "SLACK_BOT_TOKEN"
+  // SlackApiUrl overrides the Web API base url.  Handy for testing.
+  SlackApiUrl = "SLACK_API_URL"
+)
+
+// This is the list of events that is given to EventsRouter.  Each event is
+// added according to the event type that will be presented by Slack.  The
+// event must also be subscribed to in the Slack app config.
+var EventHandlers = Events {
+  Event{
+    Name: "link_shared",
+    IsLong: true,
+    Handler: HandleLinkShared,
+  },
+  Event{
+    Name: "message",
+    IsLong: true,
+    Handler: HandleThreadReply,
+    Accept: WantsThreadReply,
+  },
+}
+
+// NewSlackClient is a utility function that uses the SLACK_BOT_TOKEN from
+// the config to create a Slack Web API client.
+func NewSlackClient(config *config.Config) *slack.WebClient {
+  app := config.App()
+  return slack.NewWebClient(app.Slack.BotToken, app.Slack.ApiUrl)
+}
+
+// ParseIssueUrl pulls the owner, repo and number out of a GitHub issue or
+// pull request url, such as https://github.com/owner/repo/issues/4
+func ParseIssueUrl(url string) (string, string, int, error) {
+  parts := strings.Split(strings.TrimRight(url, "/"), "/")
+  // https: "" github.com owner repo issues 4
+  if len(parts) < 7 || parts[2] != "github.com" || (parts[5] != "issues" && parts[5] != "pull") {
+    return "", "", -1, fmt.Errorf("Not a GitHub issue url: %s", url)
+  }
+  return ParseIssueRef(fmt.Sprintf("%s/%s#%s", parts[3], parts[4], parts[6]))
+}
+
+// HandleLinkShared unfurls GitHub issue links posted in channels.  The
+// github.com domain must be registered under "App unfurl domains".
+func HandleLinkShared(ctx context.Context, config *config.Config, envelope *slack.EventEnvelope) *StatusError {
+  event := envelope.Event
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return &StatusError{http.StatusInternalServerError, err}
+  }
+
+  unfurls := slack.Unfurls{}
+  for _, link := range event.Links {
+    owner, repo, number, err := ParseIssueUrl(link.Url)
+    if err != nil {
+      continue
+    }
+    issue, _, err := client.Issues.Get(owner, repo, number)
+    if err != nil {
+      log.Printf("[%s] Could not unfurl %s: %s", handler.RequestID(ctx), link.Url, err.Error())
+      continue
+    }
+    unfurls[link.Url] = FormatBasicIssue(issue)
+  }
+  if len(unfurls) == 0 {
+    return nil
+  }
+
+  if err := NewSlackClient(config).ChatUnfurl(ctx, event.Channel, event.MessageTs, unfurls); err != nil {
+    return &StatusError{http.StatusBadGateway, err}
+  }
+  return nil
+}
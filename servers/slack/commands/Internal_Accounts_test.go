@@ -0,0 +1,137 @@
+package commands
+
+import (
+  "fmt"
+  "time"
+  "strings"
+  "testing"
+  "context"
+  "net/url"
+  "net/http"
+  "io/ioutil"
+  "golang.org/x/oauth2"
+
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/store"
+  "github.com/confyrm/gorest/githubclient"
+  "github.com/confyrm/gorest/slack/slacktest"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+// githubLogin answers the GitHub OAuth token exchange, which is always on
+// github.com, and passes everything else through.
+type githubLogin struct{}
+
+func (githubLogin) RoundTrip(req *http.Request) (*http.Response, error) {
+  if req.URL.Host != "github.com" {
+    return http.DefaultTransport.RoundTrip(req)
+  }
+  req.ParseForm()
+  body := fmt.Sprintf(`{"access_token": "gho-%s", "token_type": "bearer"}`, req.PostForm.Get("code"))
+  return &http.Response{
+    StatusCode: http.StatusOK,
+    Header: http.Header{"Content-Type": []string{"application/json"}},
+    Body: ioutil.NopCloser(strings.NewReader(body)),
+    Request: req,
+  }, nil
+}
+
+func TestAccountLinking(t *testing.T) {
+  Convey("Given account linking, with a fake Slack and GitHub", t, func() {
+    gh, _ := newGithub()
+    defer gh.Close()
+    fakeSlack := slacktest.NewServer("secret")
+    defer fakeSlack.Close()
+
+    c := newTestConfig(gh)
+    c.Set(githubclient.ClientId, "gh-client")
+    c.Set(githubclient.ClientSecret, "gh-secret")
+    c.Set(githubclient.RedirectUrl, "https://devhub.example.com/github/oauth")
+    c.Set(githubclient.TokenKey, "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+    c.Set("SLACK_CLIENT_ID", "slack-client")
+    c.Set("SLACK_CLIENT_SECRET", "slack-secret")
+    c.Set("SLACK_OAUTH_REDIRECT_URL", "https://devhub.example.com/slack/oauth")
+    c.Set(SlackApiUrl, fakeSlack.ApiUrl())
+
+    mem := store.NewMemory()
+    So(SetupAccountLinking(c, store.Bucket{mem, TokensBucket}), ShouldBeNil)
+    defer setTokens(nil)
+    ctx := store.WithStore(context.Background(), mem)
+    ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: githubLogin{}})
+
+    // login runs /devhub login as userId, and returns the state of the link.
+    login := func(userId string) string {
+      command := &slack.DevHubCommand{slack.Commands{}, slack.KVPairs{}}
+      resp, err := HandleLogin(ctx, &slack.Request{UserId: userId}, c, command)
+      So(err, ShouldBeNil)
+      link, err := url.Parse(resp.Attachments[0].TitleLink)
+      So(err, ShouldBeNil)
+      So(link.String(), ShouldStartWith, slack.SignInAuthorizeUrl)
+      return link.Query().Get("state")
+    }
+
+    Convey("The Slack user who ran /devhub login should be linked", func() {
+      state := login("U1")
+      session, next, err := SignInWithSlack(ctx, c, fakeSlack.SignInCode("U1"), state)
+      So(err, ShouldBeNil)
+      So(next, ShouldStartWith, "https://github.com/login/oauth/authorize")
+      So(next, ShouldContainSubstring, url.QueryEscape(state))
+
+      account, err := LinkAccount(ctx, c, "abc", state, session)
+      So(err, ShouldBeNil)
+      So(account, ShouldEqual, gh.Login)
+      linked, err := Tokens().Get("U1")
+      So(err, ShouldBeNil)
+      So(linked.Token, ShouldEqual, "gho-abc")
+
+      Convey("And the link should not work again", func() {
+        _, err := LinkAccount(ctx, c, "def", state, session)
+        So(err.Error(), ShouldContainSubstring, "already been used")
+        _, _, err = SignInWithSlack(ctx, c, fakeSlack.SignInCode("U1"), state)
+        So(err.Error(), ShouldContainSubstring, "already been used")
+        linked, _ := Tokens().Get("U1")
+        So(linked.Token, ShouldEqual, "gho-abc")
+      })
+    })
+
+    Convey("A link opened by another Slack user should be refused", func() {
+      state := login("U1")
+      _, _, err := SignInWithSlack(ctx, c, fakeSlack.SignInCode("U2"), state)
+      So(err.Error(), ShouldContainSubstring, "another Slack user")
+      _, err = Tokens().Get("U1")
+      So(err, ShouldEqual, githubclient.ErrNotLinked)
+    })
+
+    Convey("The GitHub callback should need the session of the same login", func() {
+      state := login("U1")
+      other := login("U1")
+      session, _, err := SignInWithSlack(ctx, c, fakeSlack.SignInCode("U1"), other)
+      So(err, ShouldBeNil)
+
+      _, err = LinkAccount(ctx, c, "abc", state, session)
+      So(err.Error(), ShouldContainSubstring, "in another browser")
+      _, err = LinkAccount(ctx, c, "abc", state, "")
+      So(err, ShouldNotBeNil)
+      _, err = LinkAccount(ctx, c, "abc", state, state)
+      So(err, ShouldNotBeNil)
+      _, err = Tokens().Get("U1")
+      So(err, ShouldEqual, githubclient.ErrNotLinked)
+
+      Convey("But it should not use up the login", func() {
+        _, err := LinkAccount(ctx, c, "abc", other, session)
+        So(err, ShouldBeNil)
+      })
+    })
+
+    Convey("A login should expire, and be swept from the store", func() {
+      state := login("U1")
+      pending, err := githubclient.VerifyState("gh-secret", state, time.Now())
+      So(err, ShouldBeNil)
+      later := time.Now().Add(githubclient.StateTTL + time.Minute)
+      So(checkLogin(mem, pending, later).Error(), ShouldContainSubstring, "expired")
+
+      sweepLogins(mem, later)
+      So(checkLogin(mem, pending, time.Now()).Error(), ShouldContainSubstring, "already been used")
+    })
+  })
+}
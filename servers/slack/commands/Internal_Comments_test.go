@@ -0,0 +1,96 @@
+package commands
+
+import (
+  "fmt"
+  "strings"
+  "testing"
+  "context"
+  "encoding/json"
+
+  "github.com/confyrm/gorest/slack"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLastComments(t *testing.T) {
+  gh, _ := newGithub()
+  defer gh.Close()
+  client, err := NewGithubClient(context.Background(), newTestConfig(gh))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // Issue #1 has comments 1 to 7.
+  tests := []struct {
+    Number int
+    Limit int
+    Want []int
+  } {
+    {1, 10, []int{1, 2, 3, 4, 5, 6, 7}},
+    {1, 7, []int{1, 2, 3, 4, 5, 6, 7}},
+    {1, 3, []int{5, 6, 7}},
+    {1, 2, []int{6, 7}},
+    {2, 5, []int{}},
+  }
+  for _, test := range tests {
+    Convey(fmt.Sprintf("Given the last %d comments on #%d", test.Limit, test.Number), t, func() {
+      comments, err := LastComments(client, "o", "r", test.Number, test.Limit)
+      So(err, ShouldBeNil)
+      bodies := []string{}
+      for _, comment := range comments {
+        bodies = append(bodies, *comment.Body)
+      }
+      want := []string{}
+      for _, n := range test.Want {
+        want = append(want, fmt.Sprintf("comment %d", n))
+      }
+      So(bodies, ShouldResemble, want)
+    })
+  }
+
+  Convey("Given comments on a missing issue, there should be an error", t, func() {
+    _, err := LastComments(client, "o", "r", 99, 5)
+    So(err, ShouldNotBeNil)
+  })
+}
+
+// threadParent decodes a message the way Slack sends it, with the slashes
+// in urls escaped.
+func threadParent(botId string, text string, url string) *slack.Event {
+  data, _ := json.Marshal(map[string]interface{} {
+    "type": "message",
+    "bot_id": botId,
+    "text": text,
+    "attachments": []map[string]string{{"title_link": url}},
+  })
+  data = []byte(strings.Replace(string(data), "/", `\/`, -1))
+  event := &slack.Event{}
+  if err := json.Unmarshal(data, event); err != nil {
+    panic(err)
+  }
+  return event
+}
+
+func TestThreadIssue(t *testing.T) {
+  url := "https://github.com/o/r/issues/12"
+  tests := []struct {
+    Name string
+    Parent *slack.Event
+    Ok bool
+  } {
+    {"a new issue message", threadParent("B1", "<@U1|steve> " + NewIssueText, url), true},
+    {"a message from a person", threadParent("", "<@U1|steve> " + NewIssueText, url), false},
+    {"another bot message", threadParent("B1", "Here is an issue", url), false},
+    {"a new issue message with no issue url", threadParent("B1", NewIssueText, "https://example.com/12"), false},
+  }
+  for _, test := range tests {
+    Convey(fmt.Sprintf("Given %s", test.Name), t, func() {
+      owner, repo, number, ok := ThreadIssue(test.Parent)
+      So(ok, ShouldEqual, test.Ok)
+      if test.Ok {
+        So(owner, ShouldEqual, "o")
+        So(repo, ShouldEqual, "r")
+        So(number, ShouldEqual, 12)
+      }
+    })
+  }
+}
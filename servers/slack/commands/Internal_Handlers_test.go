@@ -0,0 +1,524 @@
+package commands
+
+import (
+  "fmt"
+  "strings"
+  "testing"
+  "context"
+  "encoding/json"
+
+  "github.com/google/go-github/github"
+  . "github.com/smartystreets/goconvey/convey"
+  "github.com/confyrm/gorest/store"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/slack/command"
+  "github.com/confyrm/gorest/slack/slacktest"
+  "github.com/confyrm/gorest/githubclient"
+  "github.com/confyrm/gorest/githubclient/githubtest"
+)
+
+// newGithub starts a fake GitHub with an o/r repo:
+//   #1 "Login is broken", labelled bug, with 7 comments
+//   #2 "Add dark mode"
+//   #3 "Fix login", a pull request with passing checks and an approval
+func newGithub() (*githubtest.Server, *githubtest.Repo) {
+  gh := githubtest.NewServer()
+  r := gh.Repo("o", "r")
+  bug := r.AddLabel("bug")
+  r.AddLabel("ui")
+  r.AddMilestone("v1")
+  r.AddAssignee("alice")
+  login := r.AddIssue("Login is broken")
+  login.Labels = []github.Label{*bug}
+  r.AddIssue("Add dark mode")
+  r.AddPullRequest("Fix login", "abc123")
+  r.SetStatus("abc123", "success", "ci")
+  r.AddReview(3, "alice", "APPROVED")
+  for i := 1; i <= 7; i++ {
+    r.AddComment(1, "alice", fmt.Sprintf("comment %d", i))
+  }
+  return gh, r
+}
+
+func newTestConfig(gh *githubtest.Server) *config.Config {
+  return config.New(nil, &map[string]interface{} {
+    githubclient.ApiUrl: gh.URL,
+    "GITHUB_TOKEN": "test-token",
+    githubclient.DefaultOwner: "o",
+    githubclient.DefaultRepo: "r",
+    GithubUsers: map[string]string{"u1": "alice"},
+    MergeUsers: []string{"U1"},
+  })
+}
+
+type handlerTest struct {
+  Name string
+  Text string
+  // Fail, if set, is a method and path pattern for githubtest.Server.Fail.
+  Fail []string
+  // Err is part of the error, or "" if the handler should succeed.
+  Err string
+  // Contains is part of the JSON response.
+  Contains string
+  Check func(r *githubtest.Repo)
+}
+
+var handlerData = []struct {
+  Handler string
+  Func IssueHandlerFunc
+  Tests []handlerTest
+} {
+  {"HandleGet", HandleGet, []handlerTest{
+    {Name: "an issue", Text: "get 1", Contains: "Login is broken"},
+    {Name: "no number", Text: "get", Err: "Issue number was not provided"},
+    {Name: "a missing issue", Text: "get 99", Err: "404"},
+    {Name: "an unknown repo", Text: "get 1 repo=x/y", Err: "404"},
+  }},
+  {"HandleNew", HandleNew, []handlerTest{
+    {Name: "a title, labels and milestone", Text: "new title=Crash labels=bug,ui milestone=1",
+      Contains: NewIssueText, Check: func(r *githubtest.Repo) {
+        issue := r.Issues[4]
+        So(issue, ShouldNotBeNil)
+        So(*issue.Title, ShouldEqual, "Crash")
+        So(issue.Labels, ShouldHaveLength, 2)
+        So(*issue.Milestone.Title, ShouldEqual, "v1")
+      }},
+    {Name: "no title", Text: "new body=text", Err: "Issue title was not provided"},
+    {Name: "no title or trigger id", Text: "new", Err: "Issue title was not provided"},
+    {Name: "an unknown milestone", Text: "new title=Crash milestone=9", Err: "422"},
+    {Name: "a mismatched owner", Text: "new title=Crash owner=a repo=b/c", Err: "owner=a does not match repo=b/c"},
+    {Name: "a GitHub error", Text: "new title=Crash", Fail: []string{"POST", "/repos/o/r/issues"}, Err: "Issue creation failed"},
+  }},
+  {"HandleClose", HandleClose, []handlerTest{
+    {Name: "an open issue", Text: "close 1", Contains: "closed", Check: func(r *githubtest.Repo) {
+      So(*r.Issues[1].State, ShouldEqual, "closed")
+    }},
+    {Name: "a missing issue", Text: "close 99", Err: "404"},
+  }},
+  {"HandleUpdate", HandleUpdate, []handlerTest{
+    {Name: "a new title and assignee", Text: "update 2 title=Dark assignee=alice", Contains: "Dark",
+      Check: func(r *githubtest.Repo) {
+        So(*r.Issues[2].Title, ShouldEqual, "Dark")
+        So(*r.Issues[2].Assignee.Login, ShouldEqual, "alice")
+      }},
+    {Name: "a bad state", Text: "update 2 state=gone", Err: "422"},
+    {Name: "no number", Text: "update title=x", Err: "Issue number was not provided"},
+  }},
+  {"HandleLabelAdd", HandleLabelAdd, []handlerTest{
+    {Name: "a label", Text: "label add number=2 labels=ui", Contains: "Labels Added", Check: func(r *githubtest.Repo) {
+      So(r.Issues[2].Labels, ShouldHaveLength, 1)
+    }},
+    {Name: "a GitHub error", Text: "label add number=2 labels=ui", Fail: []string{"POST", "/repos/o/r/issues/2/labels"},
+      Err: "Adding labels failed"},
+  }},
+  {"HandleLabelRemove", HandleLabelRemove, []handlerTest{
+    {Name: "a label", Text: "label remove number=1 labels=bug", Contains: "Labels Removed", Check: func(r *githubtest.Repo) {
+      So(r.Issues[1].Labels, ShouldBeEmpty)
+    }},
+    {Name: "a label the issue does not have", Text: "label remove number=2 labels=bug", Err: "404"},
+  }},
+  {"HandleList", HandleList, []handlerTest{
+    {Name: "the open issues", Text: "list", Contains: "Add dark mode"},
+    {Name: "a label", Text: "list labels=bug limit=1", Contains: "Page 1 of 1"},
+    {Name: "one per page", Text: "list limit=1", Contains: "Page 1 of 3"},
+    {Name: "a bad limit", Text: "list limit=500", Err: "limit must be between"},
+    {Name: "a GitHub error", Text: "list", Fail: []string{"GET", "/repos/o/r/issues"}, Err: "Issue list failed"},
+  }},
+  {"HandleSearch", HandleSearch, []handlerTest{
+    {Name: "text", Text: "search text=login", Contains: "1 issues"},
+    {Name: "nothing found", Text: "search text=nothing", Contains: "No issues found"},
+    {Name: "a GitHub error", Text: "search text=login", Fail: []string{"GET", "/search/issues"}, Err: "Issue search failed"},
+  }},
+  {"HandleComment", HandleComment, []handlerTest{
+    {Name: "a comment", Text: "comment 2 body=Thanks", Contains: "commented on o/r#2", Check: func(r *githubtest.Repo) {
+      So(r.Comments[2], ShouldHaveLength, 1)
+      So(*r.Comments[2][0].Body, ShouldContainSubstring, "Thanks")
+    }},
+    {Name: "no body", Text: "comment 2", Err: "Comment body was not provided"},
+    {Name: "a missing issue", Text: "comment 99 body=Thanks", Err: "Comment failed"},
+  }},
+  {"HandleComments", HandleComments, []handlerTest{
+    {Name: "the last comments", Text: "comments 1 limit=3", Contains: "comment 7", Check: nil},
+    {Name: "no comments", Text: "comments 2", Contains: "has no comments"},
+    {Name: "a bad limit", Text: "comments 1 limit=99", Err: "limit must be between"},
+    {Name: "a GitHub error", Text: "comments 1", Fail: []string{"GET", "/repos/o/r/issues/1/comments"}, Err: "Comment fetch failed"},
+  }},
+  {"HandlePullList", HandlePullList, []handlerTest{
+    {Name: "the open pull requests", Text: "pr list", Contains: "Fix login"},
+    {Name: "no closed pull requests", Text: "pr list state=closed", Contains: "No closed pull requests"},
+    {Name: "a GitHub error", Text: "pr list", Fail: []string{"GET", "/repos/o/r/pulls"}, Err: "Pull request list failed"},
+  }},
+  {"HandlePullGet", HandlePullGet, []handlerTest{
+    {Name: "a pull request", Text: "pr get number=3", Contains: "Fix login"},
+    {Name: "a missing pull request", Text: "pr get number=1", Err: "Pull request fetch failed"},
+    {Name: "a review error", Text: "pr get number=3", Fail: []string{"GET", "/repos/o/r/pulls/3/reviews"}, Err: "Review fetch failed"},
+  }},
+  {"HandlePullChecks", HandlePullChecks, []handlerTest{
+    {Name: "passing checks", Text: "pr checks number=3", Contains: "ci"},
+    {Name: "a status error", Text: "pr checks number=3", Fail: []string{"GET", "/repos/o/r/commits/*/status"},
+      Err: "Check status fetch failed"},
+  }},
+  {"HandlePullReview", HandlePullReview, []handlerTest{
+    {Name: "a collaborator", Text: "pr review number=3 reviewers=@alice", Contains: "asked alice to review",
+      Check: func(r *githubtest.Repo) {
+        So(r.Reviewers[3], ShouldResemble, []string{"alice"})
+      }},
+    {Name: "someone else", Text: "pr review number=3 reviewers=mallory", Err: "Review request failed"},
+  }},
+  {"HandlePullMerge", HandlePullMerge, []handlerTest{
+    {Name: "an open pull request", Text: "pr merge number=3", Contains: MergeRef("o/r#3", "merge", "abc123"), Check: func(r *githubtest.Repo) {
+      So(*r.PullRequests[3].Merged, ShouldBeFalse)
+    }},
+    {Name: "a missing pull request", Text: "pr merge number=2", Err: "Pull request fetch failed"},
+  }},
+  {"HandleConfigSet", HandleConfigSet, []handlerTest{
+    {Name: "a repo", Text: "config set repo=x/y", Contains: "*x/y*"},
+    {Name: "nothing", Text: "config set", Err: "Give a repo"},
+    {Name: "a mismatched owner", Text: "config set owner=a repo=x/y", Err: "does not match"},
+  }},
+  {"HandleConfigGet", HandleConfigGet, []handlerTest{
+    {Name: "no channel repo", Text: "config get", Contains: "global default, *o/r*"},
+  }},
+  {"HandleConfigUnset", HandleConfigUnset, []handlerTest{
+    {Name: "no channel repo", Text: "config unset", Contains: "now uses *o/r*"},
+  }},
+  {"HandleLogin", HandleLogin, []handlerTest{
+    {Name: "linking off", Text: "login", Err: "not set up"},
+  }},
+  {"HandleLogout", HandleLogout, []handlerTest{
+    {Name: "linking off", Text: "logout", Err: "not set up"},
+  }},
+}
+
+func runHandlerTest(h IssueHandlerFunc, test handlerTest, sReq *slack.Request) {
+  gh, r := newGithub()
+  defer gh.Close()
+  if len(test.Fail) == 2 {
+    gh.Fail(test.Fail[0], test.Fail[1], 500)
+  }
+  sReq.Text = test.Text
+  command, err := sReq.TextToCommand()
+  So(err, ShouldBeNil)
+
+  ctx := store.WithStore(context.Background(), store.NewMemory())
+  resp, err := h(ctx, sReq, newTestConfig(gh), command)
+  if test.Err != "" {
+    So(err, ShouldNotBeNil)
+    So(err.Error(), ShouldContainSubstring, test.Err)
+    return
+  }
+  So(err, ShouldBeNil)
+  out, _ := json.Marshal(resp)
+  So(string(out), ShouldContainSubstring, test.Contains)
+  if test.Check != nil {
+    test.Check(r)
+  }
+}
+
+func TestHandlers(t *testing.T) {
+  for _, d := range handlerData {
+    for _, test := range d.Tests {
+      Convey(fmt.Sprintf("Given %s with %s", d.Handler, test.Name), t, func() {
+        runHandlerTest(d.Func, test, &slack.Request{UserId: "U1", UserName: "steve", ChannelId: "C1"})
+      })
+    }
+  }
+}
+
+func TestHandlersAsOtherUser(t *testing.T) {
+  Convey("Given HandlePullMerge by someone not in GITHUB_MERGE_USERS", t, func() {
+    runHandlerTest(HandlePullMerge, handlerTest{Text: "pr merge number=3", Err: "not allowed to merge"},
+      &slack.Request{UserId: "U2", UserName: "mallory"})
+  })
+  Convey("Given HandlePullMerge by someone who named themselves after a merge user", t, func() {
+    runHandlerTest(HandlePullMerge, handlerTest{Text: "pr merge number=3", Err: "not allowed to merge"},
+      &slack.Request{UserId: "U2", UserName: "U1"})
+  })
+}
+
+var actionData = []struct {
+  Name string
+  Func command.ActionHandlerFunc
+  UserId string
+  Value string
+  Fail []string
+  Err string
+  Contains string
+  Check func(r *githubtest.Repo)
+} {
+  {Name: "HandleCloseAction", Func: HandleCloseAction, UserId: "U1", Value: "o/r#1", Contains: "closed an issue",
+    Check: func(r *githubtest.Repo) {
+      So(*r.Issues[1].State, ShouldEqual, "closed")
+    }},
+  {Name: "HandleCloseAction with a bad ref", Func: HandleCloseAction, UserId: "U1", Value: "nope", Err: "Bad issue reference"},
+  {Name: "HandleCloseAction with a GitHub error", Func: HandleCloseAction, UserId: "U1", Value: "o/r#1",
+    Fail: []string{"PATCH", "/repos/o/r/issues/1"}, Err: "Issue close failed"},
+  {Name: "HandleAssignAction", Func: HandleAssignAction, UserId: "U1", Value: "o/r#2", Contains: "took an issue",
+    Check: func(r *githubtest.Repo) {
+      So(*r.Issues[2].Assignee.Login, ShouldEqual, "alice")
+    }},
+  {Name: "HandleAssignAction by an unknown user", Func: HandleAssignAction, UserId: "U2", Value: "o/r#2",
+    Err: "No GitHub user is known"},
+  {Name: "HandleMergeAction", Func: HandleMergeAction, UserId: "U1", Value: "o/r#3 squash abc123", Contains: "merged o/r#3 (squash)",
+    Check: func(r *githubtest.Repo) {
+      So(*r.PullRequests[3].Merged, ShouldBeTrue)
+      So(*r.Issues[3].State, ShouldEqual, "closed")
+    }},
+  {Name: "HandleMergeAction by someone else", Func: HandleMergeAction, UserId: "U2", Value: "o/r#3 merge abc123",
+    Err: "not allowed to merge"},
+  {Name: "HandleMergeAction after the pull request changed", Func: HandleMergeAction, UserId: "U1", Value: "o/r#3 merge def456",
+    Err: "Head branch was modified",
+    Check: func(r *githubtest.Repo) {
+      So(*r.PullRequests[3].Merged, ShouldBeFalse)
+    }},
+  {Name: "HandleMergeAction with no commit", Func: HandleMergeAction, UserId: "U1", Value: "o/r#3 merge",
+    Err: "does not say which commit"},
+  {Name: "HandleMergeAction with a GitHub error", Func: HandleMergeAction, UserId: "U1", Value: "o/r#3 merge abc123",
+    Fail: []string{"PUT", "/repos/o/r/pulls/3/merge"}, Err: "Merge failed"},
+  {Name: "HandleCancelMergeAction", Func: HandleCancelMergeAction, UserId: "U1", Value: "o/r#3", Contains: "was not merged"},
+  {Name: "HandlePageAction", Func: HandlePageAction, UserId: "U1", Value: "issue list limit=1 page=2",
+    Contains: "Page 2 of 3"},
+  {Name: "HandlePageAction with a pr list", Func: HandlePageAction, UserId: "U1", Value: "pr list limit=5",
+    Err: "Not a paging command"},
+}
+
+func TestActionHandlers(t *testing.T) {
+  for _, d := range actionData {
+    Convey(fmt.Sprintf("Given %s", d.Name), t, func() {
+      gh, r := newGithub()
+      defer gh.Close()
+      if len(d.Fail) == 2 {
+        gh.Fail(d.Fail[0], d.Fail[1], 500)
+      }
+      interaction := &slack.Interaction{Type: slack.InteractiveMessage}
+      interaction.User.Id = d.UserId
+      interaction.User.Name = strings.ToLower(d.UserId)
+      action := &slack.Action{Type: "button", Value: d.Value}
+
+      resp, statusErr := d.Func(context.Background(), newTestConfig(gh), interaction, action)
+      if d.Err != "" {
+        So(statusErr, ShouldNotBeNil)
+        So(statusErr.Error(), ShouldContainSubstring, d.Err)
+      } else {
+        So(statusErr, ShouldBeNil)
+        out, _ := json.Marshal(resp)
+        So(string(out), ShouldContainSubstring, d.Contains)
+      }
+      if d.Check != nil {
+        d.Check(r)
+      }
+    })
+  }
+}
+
+// newTestSlack starts a fake Slack that knows U1, and points c at it.
+func newTestSlack(c *config.Config) *slacktest.Server {
+  s := slacktest.NewServer("secret")
+  s.AddUser(slack.User{Id: "U1", Name: "steve", RealName: "Steve Jobs"})
+  c.Set(SlackBotToken, "xoxb-test")
+  c.Set(SlackApiUrl, s.ApiUrl())
+  return s
+}
+
+// newIssueParent is the message HandleNew posted for o/r#2.
+var newIssueParent = threadParent("B1", "<@U1|steve> " + NewIssueText, "https://github.com/o/r/issues/2")
+
+var eventData = []struct {
+  Name string
+  Func command.EventHandlerFunc
+  Event slack.Event
+  ThreadSync bool
+  // Parent, if set, starts the thread conversations.replies returns.
+  Parent *slack.Event
+  // SlackFail is a Web API method and the error it should fail with.
+  SlackFail []string
+  Fail []string
+  Err string
+  Check func(r *githubtest.Repo, s *slacktest.Server)
+} {
+  {Name: "HandleLinkShared with issue and pull request links", Func: HandleLinkShared,
+    Event: slack.Event{Type: "link_shared", Channel: "C1", MessageTs: "1.000100", Links: []slack.SharedLink{
+      {Domain: "github.com", Url: "https://github.com/o/r/issues/2"},
+      {Domain: "github.com", Url: "https://github.com/o/r/pull/3"},
+    }},
+    Check: func(r *githubtest.Repo, s *slacktest.Server) {
+      calls := s.Calls("chat.unfurl")
+      So(len(calls), ShouldEqual, 1)
+      var args struct {
+        Ts string `json:"ts"`
+        Unfurls slack.Unfurls `json:"unfurls"`
+      }
+      So(calls[0].Decode(&args), ShouldBeNil)
+      So(args.Ts, ShouldEqual, "1.000100")
+      So(len(args.Unfurls), ShouldEqual, 2)
+      So(args.Unfurls["https://github.com/o/r/issues/2"].Title, ShouldContainSubstring, "Add dark mode")
+      So(args.Unfurls["https://github.com/o/r/pull/3"].Title, ShouldContainSubstring, "Fix login")
+    }},
+  {Name: "HandleLinkShared with no issue links", Func: HandleLinkShared,
+    Event: slack.Event{Type: "link_shared", Channel: "C1", MessageTs: "1.000100", Links: []slack.SharedLink{
+      {Domain: "github.com", Url: "https://github.com/o/r"},
+      {Domain: "github.com", Url: "https://github.com/o/r/issues/99"},
+    }},
+    Check: func(r *githubtest.Repo, s *slacktest.Server) {
+      So(s.Calls("chat.unfurl"), ShouldBeEmpty)
+    }},
+  {Name: "HandleLinkShared when Slack refuses the unfurl", Func: HandleLinkShared,
+    Event: slack.Event{Type: "link_shared", Channel: "C1", MessageTs: "1.000100", Links: []slack.SharedLink{
+      {Domain: "github.com", Url: "https://github.com/o/r/issues/1"},
+    }},
+    SlackFail: []string{"chat.unfurl", "cannot_unfurl_url"}, Err: "cannot_unfurl_url"},
+  {Name: "HandleThreadReply to a new issue", Func: HandleThreadReply,
+    Event: slack.Event{Type: "message", Channel: "C1", User: "U1", Text: "Same here", Ts: "2.000100", ThreadTs: "1.000100"},
+    ThreadSync: true, Parent: newIssueParent,
+    Check: func(r *githubtest.Repo, s *slacktest.Server) {
+      So(len(r.Comments[2]), ShouldEqual, 1)
+      So(*r.Comments[2][0].Body, ShouldStartWith, "Same here")
+      So(*r.Comments[2][0].Body, ShouldContainSubstring, "Posted from Slack by Steve Jobs (@alice)")
+    }},
+  {Name: "HandleThreadReply with thread sync off", Func: HandleThreadReply,
+    Event: slack.Event{Type: "message", Channel: "C1", User: "U1", Text: "Same here", Ts: "2.000100", ThreadTs: "1.000100"},
+    Parent: newIssueParent,
+    Check: func(r *githubtest.Repo, s *slacktest.Server) {
+      So(s.Calls(""), ShouldBeEmpty)
+      So(r.Comments[2], ShouldBeEmpty)
+    }},
+  {Name: "HandleThreadReply to a message from a person", Func: HandleThreadReply,
+    Event: slack.Event{Type: "message", Channel: "C1", User: "U1", Text: "Same here", Ts: "2.000100", ThreadTs: "1.000100"},
+    ThreadSync: true, Parent: threadParent("", "<@U1|steve> " + NewIssueText, "https://github.com/o/r/issues/2"),
+    Check: func(r *githubtest.Repo, s *slacktest.Server) {
+      So(len(s.Calls("conversations.replies")), ShouldEqual, 1)
+      So(r.Comments[2], ShouldBeEmpty)
+    }},
+  {Name: "HandleThreadReply when the thread can not be read", Func: HandleThreadReply,
+    Event: slack.Event{Type: "message", Channel: "C1", User: "U1", Text: "Same here", Ts: "2.000100", ThreadTs: "1.000100"},
+    ThreadSync: true, SlackFail: []string{"conversations.replies", "missing_scope"}, Err: "missing_scope"},
+  {Name: "HandleThreadReply with a GitHub error", Func: HandleThreadReply,
+    Event: slack.Event{Type: "message", Channel: "C1", User: "U1", Text: "Same here", Ts: "2.000100", ThreadTs: "1.000100"},
+    ThreadSync: true, Parent: newIssueParent,
+    Fail: []string{"POST", "/repos/o/r/issues/2/comments"}, Err: "Comment failed"},
+}
+
+func TestEventHandlers(t *testing.T) {
+  for _, d := range eventData {
+    Convey(fmt.Sprintf("Given %s", d.Name), t, func() {
+      gh, r := newGithub()
+      defer gh.Close()
+      if len(d.Fail) == 2 {
+        gh.Fail(d.Fail[0], d.Fail[1], 500)
+      }
+      c := newTestConfig(gh)
+      c.Set(SlackThreadSync, d.ThreadSync)
+      s := newTestSlack(c)
+      defer s.Close()
+      if d.Parent != nil {
+        s.SetReplies(d.Event.Channel, d.Event.ThreadTs, *d.Parent, d.Event)
+      }
+      if len(d.SlackFail) == 2 {
+        s.Fail(d.SlackFail[0], d.SlackFail[1])
+      }
+
+      statusErr := d.Func(context.Background(), c, &slack.EventEnvelope{Type: slack.EventCallback, Event: d.Event})
+      if d.Err != "" {
+        So(statusErr, ShouldNotBeNil)
+        So(statusErr.Error(), ShouldContainSubstring, d.Err)
+      } else {
+        So(statusErr, ShouldBeNil)
+      }
+      if d.Check != nil {
+        d.Check(r, s)
+      }
+    })
+  }
+}
+
+// newIssueForm is a submitted new issue form for o/r, opened in C2.
+func newIssueForm(metadata string, title string) *slack.View {
+  view := &slack.View{Id: "V1", Type: "modal", CallbackId: NewIssueModal, PrivateMetadata: metadata}
+  view.State.Values = map[string]map[string]slack.ViewStateValue {
+    "title": {"title": {Type: "plain_text_input", Value: title}},
+    "body": {"body": {Type: "plain_text_input", Value: "  "}},
+    "labels": {"labels": {Type: "multi_static_select", SelectedOptions: []slack.OptionValue{{Value: "bug"}, {Value: "ui"}}}},
+    "assignee": {"assignee": {Type: "static_select", SelectedOption: &slack.OptionValue{Value: "alice"}}},
+  }
+  return view
+}
+
+const formMetadata = `{"owner": "o", "repo": "r", "channel": "C2"}`
+
+var submissionData = []struct {
+  Name string
+  View *slack.View
+  SlackFail []string
+  Fail []string
+  Err string
+  Check func(r *githubtest.Repo, s *slacktest.Server)
+} {
+  {Name: "a new issue form", View: newIssueForm(formMetadata, "Crash on save"),
+    Check: func(r *githubtest.Repo, s *slacktest.Server) {
+      issue := r.Issues[4]
+      So(issue, ShouldNotBeNil)
+      So(*issue.Title, ShouldEqual, "Crash on save")
+      So(issue.Body, ShouldBeNil)
+      So(len(issue.Labels), ShouldEqual, 2)
+      So(*issue.Assignee.Login, ShouldEqual, "alice")
+      calls := s.Calls("chat.postMessage")
+      So(len(calls), ShouldEqual, 1)
+      msg, err := calls[0].Message()
+      So(err, ShouldBeNil)
+      So(msg.Channel, ShouldEqual, "C2")
+    }},
+  {Name: "a form with bad metadata", View: newIssueForm("o/r", "Crash on save"), Err: "Bad new issue form metadata",
+    Check: func(r *githubtest.Repo, s *slacktest.Server) {
+      So(r.Issues[4], ShouldBeNil)
+      So(s.Calls(""), ShouldBeEmpty)
+    }},
+  {Name: "a form GitHub refuses", View: newIssueForm(formMetadata, "Crash on save"),
+    Fail: []string{"POST", "/repos/o/r/issues"}, Err: "Issue creation failed",
+    Check: func(r *githubtest.Repo, s *slacktest.Server) {
+      calls := s.Calls("chat.postMessage")
+      So(len(calls), ShouldEqual, 1)
+      msg, err := calls[0].Message()
+      So(err, ShouldBeNil)
+      So(msg.Channel, ShouldEqual, "U1")
+      So(*msg.Text, ShouldStartWith, "Your issue was not created")
+    }},
+  {Name: "a form whose issue can not be posted", View: newIssueForm(formMetadata, "Crash on save"),
+    SlackFail: []string{"chat.postMessage", "not_in_channel"}, Err: "Issue created, but could not be posted",
+    Check: func(r *githubtest.Repo, s *slacktest.Server) {
+      So(r.Issues[4], ShouldNotBeNil)
+    }},
+}
+
+func TestNewIssueSubmission(t *testing.T) {
+  for _, d := range submissionData {
+    Convey(fmt.Sprintf("Given HandleNewIssueSubmission with %s", d.Name), t, func() {
+      gh, r := newGithub()
+      defer gh.Close()
+      if len(d.Fail) == 2 {
+        gh.Fail(d.Fail[0], d.Fail[1], 500)
+      }
+      c := newTestConfig(gh)
+      s := newTestSlack(c)
+      defer s.Close()
+      if len(d.SlackFail) == 2 {
+        s.Fail(d.SlackFail[0], d.SlackFail[1])
+      }
+      interaction := &slack.Interaction{Type: slack.ViewSubmission, View: d.View}
+      interaction.User.Id = "U1"
+      interaction.User.Name = "steve"
+
+      resp, statusErr := HandleNewIssueSubmission(context.Background(), c, interaction, nil)
+      So(resp, ShouldBeNil)
+      if d.Err != "" {
+        So(statusErr, ShouldNotBeNil)
+        So(statusErr.Error(), ShouldContainSubstring, d.Err)
+      } else {
+        So(statusErr, ShouldBeNil)
+      }
+      if d.Check != nil {
+        d.Check(r, s)
+      }
+    })
+  }
+}
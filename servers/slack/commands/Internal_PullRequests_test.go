@@ -0,0 +1,51 @@
+package commands
+
+import (
+  "testing"
+  "github.com/confyrm/gorest/config"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCanMerge(t *testing.T) {
+  Convey("Given merge users from the env", t, func() {
+    c := config.New(nil, &map[string]interface{} {MergeUsers: "U1, U3"})
+
+    Convey("A listed user id should be allowed", func() {
+      So(CanMerge(c, "U1"), ShouldBeNil)
+      So(CanMerge(c, "U3"), ShouldBeNil)
+    })
+    Convey("Ids should be matched exactly", func() {
+      So(CanMerge(c, "u1"), ShouldNotBeNil)
+      So(CanMerge(c, "U12"), ShouldNotBeNil)
+      So(CanMerge(c, ""), ShouldNotBeNil)
+    })
+  })
+}
+
+func TestMergeRef(t *testing.T) {
+  Convey("Given a Merge button value", t, func() {
+    value := MergeRef("o/r#3", "squash", "abc123")
+
+    Convey("It should parse back into the ref, method and head commit", func() {
+      ref, method, sha := ParseMergeRef(value)
+      So(ref, ShouldEqual, "o/r#3")
+      So(method, ShouldEqual, "squash")
+      So(sha, ShouldEqual, "abc123")
+    })
+  })
+
+  Convey("Given a value with no method or commit", t, func() {
+    ref, method, sha := ParseMergeRef("o/r#3")
+    So(ref, ShouldEqual, "o/r#3")
+    So(method, ShouldEqual, "merge")
+    So(sha, ShouldEqual, "")
+  })
+}
+
+func TestCapitalize(t *testing.T) {
+  Convey("Capitalize should upper case only the first letter", t, func() {
+    So(Capitalize("squash"), ShouldEqual, "Squash")
+    So(Capitalize("éclair pie"), ShouldEqual, "Éclair pie")
+    So(Capitalize(""), ShouldEqual, "")
+  })
+}
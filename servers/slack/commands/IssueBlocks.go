@@ -0,0 +1,95 @@
+package commands
+
+import (
+  "fmt"
+  "github.com/google/go-github/github"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack"
+)
+
+// UseBlocks is the config key that switches issue responses from legacy
+// attachments to Block Kit.  Leave it off for workspaces with older clients.
+const UseBlocks = "SLACK_USE_BLOCKS"
+
+// IssueResponse renders an issue as either Block Kit blocks or legacy
+// attachments, depending on SLACK_USE_BLOCKS.  If details is true, the
+// status, creator, assignee and milestone are included.
+func IssueResponse(config *config.Config, responseType slack.ResponseType, title string, issue *github.Issue, details bool) *slack.Response {
+  response := slack.Response{Type: responseType.String(), Text: &title}
+
+  if config.App().Slack.UseBlocks {
+    response.Blocks = slack.Blocks{slack.NewSection(slack.Truncate(title, slack.MaxSectionTextLength))}
+    response.Blocks = append(response.Blocks, FormatBasicIssueBlocks(issue)...)
+    if details {
+      response.Blocks = append(response.Blocks, FormatIssueDetailsBlocks(issue)...)
+    }
+    return &response
+  }
+
+  response.Attachments = slack.Attachments{FormatBasicIssue(issue)}
+  if details {
+    response.Attachments = append(response.Attachments, FormatIssueDetails(issue))
+  }
+  return &response
+}
+
+// FormatBasicIssueBlocks is the Block Kit version of FormatBasicIssue.
+func FormatBasicIssueBlocks(issue *github.Issue) slack.Blocks {
+  issueNumber := GetSafeInt(issue.Number)
+  issueBody := GetSafeString(issue.Body)
+  issueUrl := GetSafeString(issue.HTMLURL)
+  issueTitle := GetSafeString(issue.Title)
+
+  text := fmt.Sprintf("*<%s|#%d>: %s*", issueUrl, issueNumber, issueTitle)
+  if len(issueBody) > 0 {
+    text = fmt.Sprintf("%s\n%s", text, issueBody)
+  }
+  blocks := slack.Blocks{slack.NewSection(slack.Truncate(text, slack.MaxSectionTextLength))}
+
+  if buttons := IssueButtons(issue); len(buttons) > 0 {
+    blocks = append(blocks, slack.NewActions(IssueCallback, buttons...))
+  }
+  return blocks
+}
+
+// FormatIssueDetailsBlocks is the Block Kit version of FormatIssueDetails.
+func FormatIssueDetailsBlocks(issue *github.Issue) slack.Blocks {
+  issueState := GetSafeString(issue.State)
+  createdBy := MakeUserLink(issue.User)
+  assignee := MakeUserLink(issue.Assignee)
+  milestoneTitle, _ := SafeMilestoneTitleAndNumber(issue.Milestone)
+
+  section := slack.NewSection("*Details*").
+    AddField(fmt.Sprintf("*Status*\n%s", orNone(issueState))).
+    AddField(fmt.Sprintf("*Created by*\n%s", orNone(createdBy))).
+    AddField(fmt.Sprintf("*Assigned*\n%s", orNone(assignee))).
+    AddField(fmt.Sprintf("*Milestone*\n%s", orNone(milestoneTitle)))
+  return slack.Blocks{section}
+}
+
+// IssueButtons are the Block Kit versions of IssueActions.  They use the
+// same keys, so the same action handlers serve both.
+func IssueButtons(issue *github.Issue) []slack.Element {
+  ref := IssueRef(issue)
+  if ref == "" {
+    return nil
+  }
+  buttons := []slack.Element{}
+  if GetSafeString(issue.State) != "closed" {
+    closeButton := slack.NewButton(CloseIssue, "Close", ref)
+    closeButton.Style = slack.DANGER
+    closeButton.Confirm = slack.NewConfirmationDialog("Close this issue?",
+      fmt.Sprintf("This will close %s for everyone.", ref), "Close it", "Never mind")
+    buttons = append(buttons, closeButton)
+  }
+  buttons = append(buttons, slack.NewButton(AssignIssue, "Assign to me", ref))
+  return buttons
+}
+
+// orNone keeps empty fields from collapsing in Slack.
+func orNone(s string) string {
+  if len(s) == 0 {
+    return "_none_"
+  }
+  return s
+}
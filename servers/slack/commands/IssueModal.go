@@ -0,0 +1,189 @@
+package commands
+
+import (
+  "fmt"
+  "log"
+  "strings"
+  "context"
+  "net/http"
+  "encoding/json"
+  "github.com/google/go-github/github"
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/router/handler"
+)
+
+// NewIssueModal is the callback_id of the new issue modal.  Its
+// view_submission is routed on it.
+const NewIssueModal = "issue_new_modal"
+
+// Block ids of the new issue modal inputs.  Each input's action_id is the
+// same as its block_id, and is the DevHubCommand key it fills in.
+const (
+  modalTitle = "title"
+  modalBody = "body"
+  modalLabels = "labels"
+  modalMilestone = "milestone"
+  modalAssignee = "assignee"
+)
+
+// modalMetadata is the private_metadata of the new issue modal.  It says
+// where the issue goes, and where to post it once it is created.
+type modalMetadata struct {
+  Owner string `json:"owner"`
+  Repo string `json:"repo"`
+  Channel string `json:"channel"`
+}
+
+// WantsModal is true for a bare "/devhub new", with nothing but the repo.
+func WantsModal(command *slack.DevHubCommand) bool {
+  for key := range command.Params {
+    if key != "owner" && key != "repo" {
+      return false
+    }
+  }
+  return true
+}
+
+// OpenNewIssueModal opens the new issue form.  The trigger id expires in 3
+// seconds, so the modal is opened first, and then updated with the repo's
+// labels, milestones and assignees once they have been fetched.
+func OpenNewIssueModal(ctx context.Context, sReq *slack.Request, config *config.Config, owner string, repo string) error {
+  if sReq.TriggerId == "" {
+    return fmt.Errorf("Issue title was not provided")
+  }
+  meta, err := json.Marshal(modalMetadata{owner, repo, sReq.ChannelId})
+  if err != nil {
+    return err
+  }
+
+  sc := NewSlackClient(config)
+  loading := NewIssueModalView(owner, repo, "Loading labels and milestones…", nil, nil, nil)
+  loading.PrivateMetadata = string(meta)
+  opened, err := sc.ViewsOpen(ctx, sReq.TriggerId, loading)
+  if err != nil {
+    return fmt.Errorf("Could not open the new issue form: %s", err.Error())
+  }
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return err
+  }
+  note := ""
+  labels, _, err := client.Issues.ListLabels(owner, repo, &github.ListOptions{PerPage: slack.MaxSelectOptions})
+  if err != nil {
+    note = fmt.Sprintf("Could not load labels: %s", err.Error())
+  }
+  milestones, _, err := client.Issues.ListMilestones(owner, repo, &github.MilestoneListOptions{State: "open",
+    ListOptions: github.ListOptions{PerPage: slack.MaxSelectOptions}})
+  if err != nil {
+    note = fmt.Sprintf("Could not load milestones: %s", err.Error())
+  }
+  assignees, _, err := client.Issues.ListAssignees(owner, repo, &github.ListOptions{PerPage: slack.MaxSelectOptions})
+  if err != nil {
+    note = fmt.Sprintf("Could not load assignees: %s", err.Error())
+  }
+
+  modal := NewIssueModalView(owner, repo, note, labels, milestones, assignees)
+  modal.PrivateMetadata = string(meta)
+  if err := sc.ViewsUpdate(ctx, opened, modal); err != nil {
+    // The user still has a form, just without the menus.
+    log.Printf("[%s] Could not update the new issue form: %s", handler.RequestID(ctx), err.Error())
+  }
+  return nil
+}
+
+// NewIssueModalView is the new issue form.  Menus with nothing to choose
+// from are left out.  note, if given, is shown under the repo name.
+func NewIssueModalView(owner string, repo string, note string, labels []*github.Label, milestones []*github.Milestone, assignees []*github.User) *slack.Modal {
+  context := fmt.Sprintf("Creating an issue in *%s/%s*", owner, repo)
+  if note != "" {
+    context = fmt.Sprintf("%s\n_%s_", context, note)
+  }
+  blocks := slack.Blocks{
+    slack.NewContext(slack.NewMarkdown(slack.Truncate(context, slack.MaxSectionTextLength))),
+    slack.NewInput(modalTitle, "Title", slack.NewTextInput(modalTitle, false)),
+  }
+  body := slack.NewInput(modalBody, "Description", slack.NewTextInput(modalBody, true))
+  body.Optional = true
+  blocks = append(blocks, body)
+
+  options := []*slack.Option{}
+  for _, label := range labels {
+    if name := GetSafeString(label.Name); name != "" && len(options) < slack.MaxSelectOptions {
+      options = append(options, slack.NewOption(name, name))
+    }
+  }
+  if len(options) > 0 {
+    input := slack.NewInput(modalLabels, "Labels", slack.NewSelect(modalLabels, "Choose labels", true, options...))
+    input.Optional = true
+    blocks = append(blocks, input)
+  }
+
+  options = []*slack.Option{}
+  for _, milestone := range milestones {
+    if title := GetSafeString(milestone.Title); title != "" && milestone.Number != nil && len(options) < slack.MaxSelectOptions {
+      options = append(options, slack.NewOption(title, fmt.Sprintf("%d", *milestone.Number)))
+    }
+  }
+  if len(options) > 0 {
+    input := slack.NewInput(modalMilestone, "Milestone", slack.NewSelect(modalMilestone, "Choose a milestone", false, options...))
+    input.Optional = true
+    blocks = append(blocks, input)
+  }
+
+  options = []*slack.Option{}
+  for _, user := range assignees {
+    if login := GetSafeString(user.Login); login != "" && len(options) < slack.MaxSelectOptions {
+      options = append(options, slack.NewOption(login, login))
+    }
+  }
+  if len(options) > 0 {
+    input := slack.NewInput(modalAssignee, "Assignee", slack.NewSelect(modalAssignee, "Choose someone", false, options...))
+    input.Optional = true
+    blocks = append(blocks, input)
+  }
+
+  return slack.NewModal(NewIssueModal, "New issue", "Create", blocks...)
+}
+
+// HandleNewIssueSubmission creates the issue from the submitted form, with
+// HandleNew, and posts it to the channel the form was opened from.  Slack
+// has already checked that the title was given.
+func HandleNewIssueSubmission(ctx context.Context, config *config.Config, interaction *slack.Interaction, action *slack.Action) (*slack.Response, *StatusError) {
+  view := interaction.View
+  var meta modalMetadata
+  if err := json.Unmarshal([]byte(view.PrivateMetadata), &meta); err != nil {
+    return nil, &StatusError{http.StatusBadRequest, fmt.Errorf("Bad new issue form metadata: %s", err.Error())}
+  }
+
+  command := &slack.DevHubCommand{slack.Commands{}, slack.KVPairs{"owner": meta.Owner, "repo": meta.Repo}}
+  for _, key := range []string{modalTitle, modalBody} {
+    if value := strings.TrimSpace(view.Text(key, key)); value != "" {
+      command.Params[key] = value
+    }
+  }
+  for _, key := range []string{modalLabels, modalMilestone, modalAssignee} {
+    if values := view.Selected(key, key); len(values) > 0 {
+      command.Params[key] = strings.Join(values, ",")
+    }
+  }
+
+  sReq := interaction.Request()
+  sReq.ChannelId = meta.Channel
+  sc := NewSlackClient(config)
+  response, err := HandleNew(ctx, sReq, config, command)
+  if err != nil {
+    // There is no response_url for a modal, so tell the user in a DM.
+    text := fmt.Sprintf("Your issue was not created: %s", err.Error())
+    if _, errr := sc.ChatPostMessage(ctx, &slack.Message{Channel: sReq.UserId, Text: &text}); errr != nil {
+      log.Printf("[%s] Could not tell %s: %s", handler.RequestID(ctx), sReq.UserId, errr.Error())
+    }
+    return nil, &StatusError{http.StatusBadGateway, err}
+  }
+  if _, err := sc.ChatPostMessage(ctx, slack.NewMessage(meta.Channel, response)); err != nil {
+    return nil, &StatusError{http.StatusBadGateway, fmt.Errorf("Issue created, but could not be posted: %s", err.Error())}
+  }
+  return nil, nil
+}
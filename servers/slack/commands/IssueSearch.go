@@ -0,0 +1,264 @@
+package commands
+
+import (
+  "fmt"
+  "strings"
+  "context"
+  "net/http"
+  "github.com/google/go-github/github"
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack"
+)
+
+// Action keys for the paging buttons on issue lists.
+const (
+  IssueListCallback = "issue_list"
+  IssuePage = "issue_page"
+)
+
+// Default and max page size for list and search.
+const (
+  DefaultPageSize = 10
+  MaxPageSize = 50
+)
+
+// GitHub search only ever returns the first 1000 results.
+const MaxSearchResults = 1000
+
+// HandleList lists the issues in a repo, using the issue list API.
+func HandleList(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return nil, err
+  }
+  page, perPage, err := ValidatePage(command)
+  if err != nil {
+    return nil, err
+  }
+
+  opt := &github.IssueListByRepoOptions{
+    State: command.ValueOrDefault("state", "open"),
+    Assignee: command.ValueOrDefault("assignee", ""),
+    Creator: command.ValueOrDefault("author", ""),
+    Milestone: command.ValueOrDefault("milestone", ""),
+    ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+  }
+  if labels, ok := command.Values("labels"); ok {
+    opt.Labels = labels
+  }
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
+  issues, resp, err := client.Issues.ListByRepo(owner, repo, opt)
+  if err != nil {
+    return nil, fmt.Errorf("Issue list failed with %s", err.Error())
+  }
+
+  // The list API has no total, but it does say whether there is a next page.
+  lastPage := page
+  if resp != nil && resp.LastPage > 0 {
+    lastPage = resp.LastPage
+  }
+  title := fmt.Sprintf("%s issues in %s/%s", Capitalize(opt.State), owner, repo)
+  return IssueListResponse(title, issues, command, page, lastPage, -1), nil
+}
+
+// HandleSearch finds issues with the search API.  The filters are turned
+// into search qualifiers, and text is searched for in titles and bodies.
+func HandleSearch(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return nil, err
+  }
+  page, perPage, err := ValidatePage(command)
+  if err != nil {
+    return nil, err
+  }
+
+  query := SearchQuery(owner, repo, command)
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
+  result, _, err := client.Search.Issues(query, &github.SearchOptions{
+    Sort: "updated",
+    Order: "desc",
+    ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+  })
+  if err != nil {
+    return nil, fmt.Errorf("Issue search failed with %s", err.Error())
+  }
+
+  issues := make([]*github.Issue, len(result.Issues))
+  for i := range result.Issues {
+    issues[i] = &result.Issues[i]
+  }
+  total := GetSafeInt(result.Total)
+  reachable := total
+  if reachable > MaxSearchResults {
+    reachable = MaxSearchResults
+  }
+  lastPage := (reachable + perPage - 1) / perPage
+  if lastPage < 1 {
+    lastPage = 1
+  }
+
+  title := fmt.Sprintf("Search results for `%s`", query)
+  return IssueListResponse(title, issues, command, page, lastPage, total), nil
+}
+
+// ValidatePage returns the page and page size from the command.
+func ValidatePage(command *slack.DevHubCommand) (int, int, error) {
+  page := command.ValueToIntOrDefault("page", 1)
+  if page < 1 {
+    return -1, -1, fmt.Errorf("page must be 1 or more")
+  }
+  perPage := command.ValueToIntOrDefault("limit", DefaultPageSize)
+  if perPage < 1 || perPage > MaxPageSize {
+    return -1, -1, fmt.Errorf("limit must be between 1 and %d", MaxPageSize)
+  }
+  return page, perPage, nil
+}
+
+// SearchQuery builds a GitHub issue search query from the command.
+func SearchQuery(owner string, repo string, command *slack.DevHubCommand) string {
+  terms := []string{fmt.Sprintf("repo:%s/%s", owner, repo), "is:issue"}
+  if text, ok := command.Value("text"); ok {
+    terms = append(terms, text)
+  }
+  if state := command.ValueOrDefault("state", "open"); state != "all" {
+    terms = append(terms, "state:" + state)
+  }
+  if labels, ok := command.Values("labels"); ok {
+    for _, label := range labels {
+      terms = append(terms, "label:" + searchValue(label))
+    }
+  }
+  if assignee, ok := command.Value("assignee"); ok {
+    if assignee == "none" {
+      terms = append(terms, "no:assignee")
+    } else {
+      terms = append(terms, "assignee:" + assignee)
+    }
+  }
+  if milestone, ok := command.Value("milestone"); ok {
+    if milestone == "none" {
+      terms = append(terms, "no:milestone")
+    } else {
+      terms = append(terms, "milestone:" + searchValue(milestone))
+    }
+  }
+  if author, ok := command.Value("author"); ok {
+    terms = append(terms, "author:" + author)
+  }
+  return strings.Join(terms, " ")
+}
+
+// searchValue quotes a qualifier value that has spaces.
+func searchValue(value string) string {
+  if strings.ContainsAny(value, " \t") {
+    return `"` + strings.Replace(value, `"`, "", -1) + `"`
+  }
+  return value
+}
+
+// IssueListResponse shows one line per issue, and buttons to move between
+// pages.  total is -1 if it is not known.
+func IssueListResponse(title string, issues []*github.Issue, command *slack.DevHubCommand, page int, lastPage int, total int) *slack.Response {
+  if len(issues) == 0 {
+    text := title + "\nNo issues found."
+    return &slack.Response{Type: slack.Ephemeral.String(), Text: &text}
+  }
+
+  lines := make([]string, 0, len(issues))
+  for _, issue := range issues {
+    lines = append(lines, FormatIssueLine(issue))
+  }
+  text := slack.Truncate(strings.Join(lines, "\n"), slack.MaxSectionTextLength)
+
+  footer := fmt.Sprintf("Page %d of %d", page, lastPage)
+  if total >= 0 {
+    footer = fmt.Sprintf("%s.  %d issues", footer, total)
+  }
+  att := slack.Attachment {
+    Fallback: text,
+    Text: text,
+    Color: slack.GOOD,
+    Footer: footer,
+    MarkdownIn: []string{"text"},
+    CallbackId: IssueListCallback,
+    Actions: PageActions(command, page, lastPage),
+  }
+  return &slack.Response{Type: slack.Ephemeral.String(), Text: &title, Attachments: slack.Attachments{att}}
+}
+
+// FormatIssueLine is a one line summary, for lists.
+func FormatIssueLine(issue *github.Issue) string {
+  line := fmt.Sprintf("<%s|#%d> %s", GetSafeString(issue.HTMLURL), GetSafeInt(issue.Number), GetSafeString(issue.Title))
+  if len(issue.Labels) > 0 {
+    names := make([]string, len(issue.Labels))
+    for i, label := range issue.Labels {
+      names[i] = GetSafeString(label.Name)
+    }
+    line = fmt.Sprintf("%s `%s`", line, strings.Join(names, "` `"))
+  }
+  if issue.Assignee != nil && issue.Assignee.Login != nil {
+    line = fmt.Sprintf("%s → %s", line, *issue.Assignee.Login)
+  }
+  return line
+}
+
+// PageActions returns "Previous" and "Next" buttons.  Each button's value is
+// the command text for its page, so HandlePageAction can simply run the
+// command again.
+func PageActions(command *slack.DevHubCommand, page int, lastPage int) slack.AttachmentActions {
+  actions := slack.AttachmentActions{}
+  button := func(text string, to int) slack.AttachmentAction {
+    params := make(slack.KVPairs, len(command.Params))
+    for k, v := range command.Params {
+      params[k] = v
+    }
+    params["page"] = fmt.Sprintf("%d", to)
+    next := slack.DevHubCommand{command.Commands, params}
+    return slack.AttachmentAction{Name: IssuePage, Text: text, Type: "button", Value: next.Text()}
+  }
+  if page > 1 {
+    actions = append(actions, button("Previous", page - 1))
+  }
+  if page < lastPage {
+    actions = append(actions, button("Next", page + 1))
+  }
+  return actions
+}
+
+// HandlePageAction runs the list or search in a paging button's value, and
+// replaces the list with the new page.
+func HandlePageAction(ctx context.Context, config *config.Config, interaction *slack.Interaction, action *slack.Action) (*slack.Response, *StatusError) {
+  sReq := interaction.Request()
+  sReq.Text = action.SelectedValue()
+  command, err := sReq.TextToCommand()
+  if err != nil {
+    return nil, &StatusError{http.StatusBadRequest, err}
+  }
+  sub, err := DevHubSchema.Validate(command)
+  if err != nil {
+    return nil, &StatusError{http.StatusBadRequest, err}
+  }
+  // The commands are now the canonical path, e.g. "issue list".  pr list
+  // has a different handler, so only take issue lists.
+  if first, _ := command.Peek(); first == "pr" || (sub.Name != "list" && sub.Name != "search") {
+    return nil, &StatusError{http.StatusBadRequest, fmt.Errorf("Not a paging command: %s", sReq.Text)}
+  }
+
+  response, statusErr := sub.Handler(ctx, config, sReq, command)
+  if statusErr != nil {
+    return nil, statusErr
+  }
+  response.ReplaceOriginal = true
+  return response, nil
+}
@@ -0,0 +1,498 @@
+package commands
+
+import (
+  "fmt"
+  "strings"
+  "context"
+  "net/http"
+  "github.com/google/go-github/github"
+  "github.com/confyrm/gorest/githubclient"
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack"
+)
+
+// MergeUsers is the config key for the Slack user ids that may merge pull
+// requests from Slack.  If it is empty, nobody can.  Names are not matched,
+// since anyone can change theirs.
+const MergeUsers = "GITHUB_MERGE_USERS"
+
+// Action keys for the merge confirmation buttons.
+const (
+  PullRequestCallback = "pull_request"
+  MergePullRequest = "pr_merge"
+  CancelMerge = "pr_merge_cancel"
+)
+
+// Default and max number of pull requests shown by pr list.
+const (
+  DefaultPullListSize = 10
+  MaxPullListSize = 50
+)
+
+// HandlePullList lists the pull requests in a repo.
+func HandlePullList(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return nil, err
+  }
+
+  limit := command.ValueToIntOrDefault("limit", DefaultPullListSize)
+  if limit < 1 || limit > MaxPullListSize {
+    return nil, fmt.Errorf("limit must be between 1 and %d", MaxPullListSize)
+  }
+  opt := &github.PullRequestListOptions{
+    State: command.ValueOrDefault("state", "open"),
+    Base: command.ValueOrDefault("base", ""),
+    ListOptions: github.ListOptions{PerPage: limit},
+  }
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
+  pulls, _, err := client.PullRequests.List(owner, repo, opt)
+  if err != nil {
+    return nil, fmt.Errorf("Pull request list failed with %s", err.Error())
+  }
+
+  title := fmt.Sprintf("%s pull requests in %s/%s", Capitalize(opt.State), owner, repo)
+  if len(pulls) == 0 {
+    text := fmt.Sprintf("No %s pull requests in %s/%s", opt.State, owner, repo)
+    return &slack.Response{Type: slack.Ephemeral.String(), Text: &text}, nil
+  }
+  if len(pulls) > limit {
+    pulls = pulls[:limit]
+  }
+
+  lines := make([]string, 0, len(pulls))
+  for _, pr := range pulls {
+    lines = append(lines, FormatPullRequestLine(pr))
+  }
+  text := slack.Truncate(strings.Join(lines, "\n"), slack.MaxSectionTextLength)
+  atts := slack.Attachments {
+    slack.Attachment {
+      Fallback: text,
+      Text: text,
+      Color: slack.GOOD,
+      MarkdownIn: []string{"text"},
+    },
+  }
+  return &slack.Response{Type: slack.Ephemeral.String(), Text: &title, Attachments: atts}, nil
+}
+
+// HandlePullGet shows a pull request, with its mergeability, reviews and
+// checks.
+func HandlePullGet(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return nil, err
+  }
+  number, err := ValidateNumber(command)
+  if err != nil {
+    return nil, err
+  }
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
+  pr, reviews, status, err := FetchPullRequest(client, owner, repo, number)
+  if err != nil {
+    return nil, err
+  }
+
+  title := "Get Pull Request"
+  atts := slack.Attachments {
+    FormatBasicPullRequest(pr),
+    FormatPullRequestDetails(pr, reviews, status),
+  }
+  return &slack.Response{Type: slack.Ephemeral.String(), Text: &title, Attachments: atts}, nil
+}
+
+// HandlePullChecks shows the CI status of a pull request's head commit.
+func HandlePullChecks(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return nil, err
+  }
+  number, err := ValidateNumber(command)
+  if err != nil {
+    return nil, err
+  }
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
+  pr, _, err := client.PullRequests.Get(owner, repo, number)
+  if err != nil {
+    return nil, fmt.Errorf("Pull request fetch failed with %s", err.Error())
+  }
+  status, err := fetchStatus(client, owner, repo, pr)
+  if err != nil {
+    return nil, err
+  }
+
+  title := fmt.Sprintf("Checks for <%s|%s/%s#%d>", GetSafeString(pr.HTMLURL), owner, repo, number)
+  atts := slack.Attachments{FormatChecks(status)}
+  return &slack.Response{Type: slack.Ephemeral.String(), Text: &title, Attachments: atts}, nil
+}
+
+// HandlePullReview requests reviews of a pull request.
+func HandlePullReview(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return nil, err
+  }
+  number, err := ValidateNumber(command)
+  if err != nil {
+    return nil, err
+  }
+  reviewers, _ := command.Values("reviewers")
+  for i := range reviewers {
+    reviewers[i] = strings.TrimPrefix(reviewers[i], "@")
+  }
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
+  pr, _, err := client.PullRequests.RequestReviewers(owner, repo, number, reviewers)
+  if err != nil {
+    return nil, fmt.Errorf("Review request failed with %s", err.Error())
+  }
+
+  title := fmt.Sprintf("<@%s|%s> asked %s to review a pull request", sReq.UserId, sReq.UserName, strings.Join(reviewers, ", "))
+  atts := slack.Attachments{FormatBasicPullRequest(pr)}
+  return &slack.Response{Type: slack.InChannel.String(), Text: &title, Attachments: atts}, nil
+}
+
+// HandlePullMerge does not merge.  It checks that the user is allowed to
+// merge, and that the pull request can be merged, then asks the user to
+// confirm.  The merge is done by HandleMergeAction.
+func HandlePullMerge(ctx context.Context, sReq *slack.Request, config *config.Config, command *slack.DevHubCommand) (*slack.Response, error) {
+
+  if err := CanMerge(config, sReq.UserId); err != nil {
+    return nil, err
+  }
+  owner, repo, err := ValidateOwnerAndRepo(config, command)
+  if err != nil {
+    return nil, err
+  }
+  number, err := ValidateNumber(command)
+  if err != nil {
+    return nil, err
+  }
+  method := command.ValueOrDefault("method", "merge")
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, err
+  }
+  pr, reviews, status, err := FetchPullRequest(client, owner, repo, number)
+  if err != nil {
+    return nil, err
+  }
+  if GetSafeString(pr.State) != "open" {
+    return nil, fmt.Errorf("%s/%s#%d is %s", owner, repo, number, pullRequestState(pr))
+  }
+  if pr.Mergeable != nil && !*pr.Mergeable {
+    return nil, fmt.Errorf("%s/%s#%d can not be merged.  Resolve its conflicts first", owner, repo, number)
+  }
+
+  ref := fmt.Sprintf("%s/%s#%d", owner, repo, number)
+  confirm := slack.Attachment {
+    Title: fmt.Sprintf("%s merge %s?", Capitalize(method), ref),
+    Fallback: fmt.Sprintf("Confirm %s merge of %s", method, ref),
+    Color: slack.WARNING,
+    CallbackId: PullRequestCallback,
+    Actions: slack.AttachmentActions {
+      slack.AttachmentAction {
+        Name: MergePullRequest,
+        Text: "Merge",
+        Type: "button",
+        Value: MergeRef(ref, method, GetSafeString(pr.Head.SHA)),
+        Style: slack.PRIMARY,
+      },
+      slack.AttachmentAction {
+        Name: CancelMerge,
+        Text: "Cancel",
+        Type: "button",
+        Value: ref,
+      },
+    },
+  }
+
+  title := "Merge Pull Request"
+  atts := slack.Attachments {
+    FormatBasicPullRequest(pr),
+    FormatPullRequestDetails(pr, reviews, status),
+    confirm,
+  }
+  return &slack.Response{Type: slack.Ephemeral.String(), Text: &title, Attachments: atts}, nil
+}
+
+// HandleMergeAction merges the pull request behind a confirmed "Merge"
+// button.  The allow-list is checked again, against whoever clicked.  Only
+// the head commit the user confirmed is merged.  If the pull request has
+// changed since, GitHub refuses.
+func HandleMergeAction(ctx context.Context, config *config.Config, interaction *slack.Interaction, action *slack.Action) (*slack.Response, *StatusError) {
+  if err := CanMerge(config, interaction.User.Id); err != nil {
+    return nil, &StatusError{http.StatusForbidden, err}
+  }
+  ref, method, sha := ParseMergeRef(action.SelectedValue())
+  owner, repo, number, err := ParseIssueRef(ref)
+  if err != nil {
+    return nil, &StatusError{http.StatusBadRequest, err}
+  }
+  if sha == "" {
+    return nil, &StatusError{http.StatusBadRequest,
+      fmt.Errorf("This Merge button does not say which commit to merge.  Run pr merge %d again", number)}
+  }
+
+  client, err := NewGithubClient(ctx, config)
+  if err != nil {
+    return nil, &StatusError{http.StatusInternalServerError, err}
+  }
+  result, _, err := client.PullRequests.Merge(owner, repo, number, "", &github.PullRequestOptions{MergeMethod: method, SHA: sha})
+  if err != nil {
+    return nil, &StatusError{http.StatusBadGateway, fmt.Errorf("Merge failed with %s", err.Error())}
+  }
+  if result.Merged == nil || !*result.Merged {
+    return nil, &StatusError{http.StatusConflict, fmt.Errorf("%s was not merged: %s", ref, GetSafeString(result.Message))}
+  }
+
+  title := fmt.Sprintf("<@%s|%s> merged %s (%s)", interaction.User.Id, interaction.UserName(), ref, method)
+  response := &slack.Response{Type: slack.InChannel.String(), Text: &title}
+  if pr, _, err := client.PullRequests.Get(owner, repo, number); err == nil {
+    response.Attachments = slack.Attachments{FormatBasicPullRequest(pr)}
+  }
+  return response, nil
+}
+
+// HandleCancelMergeAction answers the "Cancel" button.
+func HandleCancelMergeAction(ctx context.Context, config *config.Config, interaction *slack.Interaction, action *slack.Action) (*slack.Response, *StatusError) {
+  text := fmt.Sprintf("Ok, %s was not merged.", action.SelectedValue())
+  return &slack.Response{Type: slack.Ephemeral.String(), Text: &text}, nil
+}
+
+// CanMerge checks the Slack user id against GITHUB_MERGE_USERS.
+func CanMerge(config *config.Config, userId string) error {
+  for _, user := range config.App().Github.MergeUsers {
+    if user != "" && user == userId {
+      return nil
+    }
+  }
+  return fmt.Errorf("You are not allowed to merge pull requests.  Ask an admin to add your Slack user id (%s) to %s.", userId, MergeUsers)
+}
+
+// MergeRef is the value of the "Merge" button: the pull request ref, the
+// merge method, and the head commit the user is confirming.
+func MergeRef(ref string, method string, sha string) string {
+  return strings.Join([]string{ref, method, sha}, " ")
+}
+
+// ParseMergeRef is the reverse of MergeRef.  The method defaults to merge,
+// and the sha to "".
+func ParseMergeRef(value string) (string, string, string) {
+  parts := strings.SplitN(value, " ", 3)
+  for len(parts) < 3 {
+    parts = append(parts, "")
+  }
+  if parts[1] == "" {
+    parts[1] = "merge"
+  }
+  return parts[0], parts[1], parts[2]
+}
+
+// FetchPullRequest gets a pull request, along with its reviews and the
+// combined status of its head commit.
+func FetchPullRequest(client *githubclient.Client, owner string, repo string, number int) (*github.PullRequest, []*github.PullRequestReview, *github.CombinedStatus, error) {
+  pr, _, err := client.PullRequests.Get(owner, repo, number)
+  if err != nil {
+    return nil, nil, nil, fmt.Errorf("Pull request fetch failed with %s", err.Error())
+  }
+  reviews, _, err := client.PullRequests.ListReviews(owner, repo, number)
+  if err != nil {
+    return nil, nil, nil, fmt.Errorf("Review fetch failed with %s", err.Error())
+  }
+  status, err := fetchStatus(client, owner, repo, pr)
+  if err != nil {
+    return nil, nil, nil, err
+  }
+  return pr, reviews, status, nil
+}
+
+func fetchStatus(client *githubclient.Client, owner string, repo string, pr *github.PullRequest) (*github.CombinedStatus, error) {
+  if pr.Head == nil || pr.Head.SHA == nil {
+    return nil, fmt.Errorf("Pull request #%d has no head commit", GetSafeInt(pr.Number))
+  }
+  status, _, err := client.Repositories.GetCombinedStatus(owner, repo, *pr.Head.SHA, nil)
+  if err != nil {
+    return nil, fmt.Errorf("Check status fetch failed with %s", err.Error())
+  }
+  return status, nil
+}
+
+// pullRequestState is open, closed or merged.
+func pullRequestState(pr *github.PullRequest) string {
+  if pr.Merged != nil && *pr.Merged {
+    return "merged"
+  }
+  if pr.MergedAt != nil {
+    return "merged"
+  }
+  return GetSafeString(pr.State)
+}
+
+// FormatPullRequestLine is a one line summary, for lists.
+func FormatPullRequestLine(pr *github.PullRequest) string {
+  var branches string
+  if pr.Head != nil && pr.Base != nil {
+    branches = fmt.Sprintf(" `%s` into `%s`", GetSafeString(pr.Head.Ref), GetSafeString(pr.Base.Ref))
+  }
+  var author string
+  if pr.User != nil {
+    author = " by " + GetSafeString(pr.User.Login)
+  }
+  return fmt.Sprintf("<%s|#%d> %s%s%s", GetSafeString(pr.HTMLURL), GetSafeInt(pr.Number),
+    GetSafeString(pr.Title), branches, author)
+}
+
+// FormatBasicPullRequest is the pull request version of FormatBasicIssue.
+func FormatBasicPullRequest(pr *github.PullRequest) slack.Attachment {
+  number := GetSafeInt(pr.Number)
+  url := GetSafeString(pr.HTMLURL)
+  title := GetSafeString(pr.Title)
+
+  var branches string
+  if pr.Head != nil && pr.Base != nil {
+    branches = fmt.Sprintf("`%s` into `%s`\n", GetSafeString(pr.Head.Ref), GetSafeString(pr.Base.Ref))
+  }
+
+  return slack.Attachment {
+    Title: fmt.Sprintf("<%s|#%d>: %s", url, number, title),
+    Fallback: fmt.Sprintf("#%d: %s\n%s", number, url, title),
+    Text: slack.Truncate(branches + GetSafeString(pr.Body), slack.MaxSectionTextLength),
+    Color: slack.GOOD,
+    MarkdownIn: []string{"title", "text"},
+  }
+}
+
+// FormatPullRequestDetails shows state, mergeability, reviews and checks.
+func FormatPullRequestDetails(pr *github.PullRequest, reviews []*github.PullRequestReview, status *github.CombinedStatus) slack.Attachment {
+  state := pullRequestState(pr)
+  mergeable := "Unknown.  GitHub is still checking"
+  if pr.Mergeable != nil {
+    if *pr.Mergeable {
+      mergeable = "Yes"
+    } else {
+      mergeable = "No.  There are conflicts"
+    }
+  }
+  review := ReviewSummary(reviews)
+  checks := "None"
+  if status != nil {
+    checks = fmt.Sprintf("%s (%d)", GetSafeString(status.State), len(status.Statuses))
+  }
+
+  color := slack.GOOD
+  switch {
+  case state == "merged":
+    color = "#6f42c1"
+  case pr.Mergeable != nil && !*pr.Mergeable:
+    color = slack.DANGER
+  case status != nil && GetSafeString(status.State) != "success":
+    color = slack.WARNING
+  }
+
+  return slack.Attachment {
+    Title: "Details",
+    Fallback: fmt.Sprintf("State: %s, Mergeable: %s, Reviews: %s, Checks: %s", state, mergeable, review, checks),
+    Color: color,
+    Fields: slack.AttachmentFields {
+      slack.AttachmentField{Title: "State", Value: state, Short: true},
+      slack.AttachmentField{Title: "Mergeable", Value: mergeable, Short: true},
+      slack.AttachmentField{Title: "Reviews", Value: review, Short: true},
+      slack.AttachmentField{Title: "Checks", Value: checks, Short: true},
+      slack.AttachmentField{Title: "Changes", Short: true,
+        Value: fmt.Sprintf("+%d -%d in %d files", GetSafeInt(pr.Additions), GetSafeInt(pr.Deletions), GetSafeInt(pr.ChangedFiles))},
+      slack.AttachmentField{Title: "Author", Value: MakeUserLink(pr.User), Short: true},
+    },
+  }
+}
+
+// ReviewSummary gives each reviewer's latest decision.  Comments only
+// count if the reviewer has not approved or requested changes.
+func ReviewSummary(reviews []*github.PullRequestReview) string {
+  var order []string
+  latest := make(map[string]string)
+  for _, review := range reviews {
+    if review == nil || review.User == nil {
+      continue
+    }
+    login := GetSafeString(review.User.Login)
+    state := GetSafeString(review.State)
+    prev, seen := latest[login]
+    if !seen {
+      order = append(order, login)
+    }
+    if state == "COMMENTED" && seen && prev != "COMMENTED" {
+      continue
+    }
+    latest[login] = state
+  }
+  if len(order) == 0 {
+    return "None"
+  }
+  summary := make([]string, len(order))
+  for i, login := range order {
+    summary[i] = fmt.Sprintf("%s: %s", login, strings.ToLower(strings.Replace(latest[login], "_", " ", -1)))
+  }
+  return strings.Join(summary, "\n")
+}
+
+// FormatChecks lists each status check.
+func FormatChecks(status *github.CombinedStatus) slack.Attachment {
+  state := GetSafeString(status.State)
+  color := slack.WARNING
+  switch state {
+  case "success":
+    color = slack.GOOD
+  case "failure", "error":
+    color = slack.DANGER
+  }
+
+  lines := make([]string, 0, len(status.Statuses))
+  for _, s := range status.Statuses {
+    icon := ":hourglass:"
+    switch GetSafeString(s.State) {
+    case "success":
+      icon = ":white_check_mark:"
+    case "failure", "error":
+      icon = ":x:"
+    }
+    name := GetSafeString(s.Context)
+    if url := GetSafeString(s.TargetURL); url != "" {
+      name = fmt.Sprintf("<%s|%s>", url, name)
+    }
+    lines = append(lines, fmt.Sprintf("%s %s %s", icon, name, GetSafeString(s.Description)))
+  }
+  text := "No checks reported"
+  if len(lines) > 0 {
+    text = slack.Truncate(strings.Join(lines, "\n"), slack.MaxSectionTextLength)
+  }
+
+  return slack.Attachment {
+    Title: fmt.Sprintf("Checks: %s", state),
+    Fallback: fmt.Sprintf("Checks: %s", state),
+    Text: text,
+    Color: color,
+    MarkdownIn: []string{"text"},
+  }
+}
@@ -0,0 +1,344 @@
+package commands
+
+import (
+  . "github.com/confyrm/gorest/slack/command"
+)
+
+// Params shared by the issue subcommands.
+var (
+  repoParam = Param{Name: "repo", Help: "Repo name, or owner/name.  Defaults to the channel's repo, then the global default."}
+  ownerParam = Param{Name: "owner", Aliases: []string{"org"}, Help: "Repo owner.  Defaults to the channel's owner, then the global default."}
+  numberParam = Param{Name: "number", Type: IntParam, Required: true, Position: 1,
+    Aliases: []string{"issue"}, Help: "Issue number."}
+  titleParam = Param{Name: "title", Help: "Issue title."}
+  bodyParam = Param{Name: "body", Aliases: []string{"description"}, Help: "Text for the issue body."}
+  labelsParam = Param{Name: "labels", Type: ListParam, Aliases: []string{"label"},
+    Help: "A comma separated list of labels."}
+  milestoneParam = Param{Name: "milestone", Type: IntParam,
+    Help: "Milestone number.  The milestone must exist in the repo."}
+  assigneeParam = Param{Name: "assignee", Type: UserParam, Aliases: []string{"assign"},
+    Help: "GitHub user to assign the issue to."}
+)
+
+// Filters shared by list and search.  They take a milestone too, but
+// list wants its number and search wants its title.
+var filterParams = Params{
+  Param{Name: "state", Type: EnumParam, Values: []string{"open", "closed", "all"}, Default: "open",
+    Help: "Which issues to show."},
+  labelsParam,
+  Param{Name: "assignee", Type: UserParam, Help: "Only issues assigned to this GitHub user, or none."},
+  Param{Name: "author", Type: UserParam, Aliases: []string{"creator"}, Help: "Only issues opened by this GitHub user."},
+  Param{Name: "page", Type: IntParam, Help: "Which page to show.  Defaults to 1."},
+  Param{Name: "limit", Type: IntParam, Help: "Issues per page.  At most 50."},
+  ownerParam,
+  repoParam,
+}
+
+// The issue subcommands.  They are available both at the top level, as in
+// /devhub get 152, and under issue, as in /devhub issue get 152.
+var (
+  newIssue = Subcommand{
+    Name: "new",
+    Aliases: []string{"create"},
+    Summary: "create a new issue, or open a form to fill one in",
+    Handler: IssueHandler(HandleNew),
+    Params: Params{
+      titleParam,
+      bodyParam,
+      labelsParam,
+      milestoneParam,
+      assigneeParam,
+      ownerParam,
+      repoParam,
+    },
+    Examples: []string{
+      "new",
+      "new title=Here is my title labels=label1,label2",
+      `new title:"Fix a = b" --labels bug --labels ui repo=my-repo`,
+    },
+  }
+  getIssue = Subcommand{
+    Name: "get",
+    Aliases: []string{"show"},
+    Summary: "display an issue",
+    Handler: IssueHandler(HandleGet),
+    Params: Params{
+      numberParam,
+      ownerParam,
+      repoParam,
+    },
+    Examples: []string{
+      "get 152",
+      "get 152 repo=my-repo",
+    },
+  }
+  updateIssue = Subcommand{
+    Name: "update",
+    Aliases: []string{"edit"},
+    Summary: "update an issue.  Provide only the keys you want to change",
+    Handler: IssueHandler(HandleUpdate),
+    Params: Params{
+      numberParam,
+      titleParam,
+      bodyParam,
+      labelsParam,
+      milestoneParam,
+      assigneeParam,
+      Param{Name: "state", Type: EnumParam, Values: []string{"open", "closed"}, Help: "Issue state."},
+      ownerParam,
+      repoParam,
+    },
+    Examples: []string{
+      "update 617 title=Here is my updated title",
+      "update 617 labels=label1 state=closed",
+    },
+  }
+  closeIssue = Subcommand{
+    Name: "close",
+    Summary: "mark an issue as closed",
+    Handler: IssueHandler(HandleClose),
+    Params: Params{
+      numberParam,
+      ownerParam,
+      repoParam,
+    },
+    Examples: []string{
+      "close 152",
+      "close 152 repo=my-repo",
+    },
+  }
+  listIssues = Subcommand{
+    Name: "list",
+    Aliases: []string{"ls"},
+    Summary: "list the issues in a repo, a page at a time",
+    Handler: IssueHandler(HandleList),
+    Params: append(filterParams,
+      Param{Name: "milestone", Help: "Milestone number, * for any, or none."},
+    ),
+    Examples: []string{
+      "list",
+      "list labels=bug assignee=steve",
+      "list state=closed page=2 limit=20",
+    },
+  }
+  searchIssues = Subcommand{
+    Name: "search",
+    Aliases: []string{"find"},
+    Summary: "search issue titles and bodies, a page at a time",
+    Handler: IssueHandler(HandleSearch),
+    Params: append(Params{
+      Param{Name: "text", Position: 1, Aliases: []string{"q", "query"},
+        Help: "Text to search for.  Quote it if it has more than one word."},
+    }, append(filterParams,
+      Param{Name: "milestone", Help: "Milestone title, or none."},
+    )...),
+    Examples: []string{
+      `search "login page"`,
+      "search crash labels=bug state=all",
+      "search text:timeout author=ann",
+    },
+  }
+  commentIssue = Subcommand{
+    Name: "comment",
+    Summary: "comment on an issue",
+    Handler: IssueHandler(HandleComment),
+    Params: Params{
+      numberParam,
+      Param{Name: "body", Required: true, Aliases: []string{"text"}, Help: "The comment.  It is signed with your Slack name."},
+      ownerParam,
+      repoParam,
+    },
+    Examples: []string{
+      `comment 152 body="Fixed in the next build"`,
+    },
+  }
+  listComments = Subcommand{
+    Name: "comments",
+    Summary: "show the last comments on an issue",
+    Handler: IssueHandler(HandleComments),
+    Params: Params{
+      numberParam,
+      Param{Name: "limit", Type: IntParam, Help: "How many to show.  Defaults to 5, at most 20."},
+      ownerParam,
+      repoParam,
+    },
+    Examples: []string{
+      "comments 152",
+      "comments 152 limit=10",
+    },
+  }
+  issueLabel = Subcommand{
+    Name: "label",
+    Aliases: []string{"labels"},
+    Summary: "add or remove issue labels",
+    Subcommands: []Subcommand{
+      {
+        Name: "add",
+        Summary: "add labels to an issue",
+        Handler: IssueHandler(HandleLabelAdd),
+        Params: Params{numberParam, required(labelsParam), ownerParam, repoParam},
+        Examples: []string{"issue label add 152 labels=bug,ui"},
+      },
+      {
+        Name: "remove",
+        Aliases: []string{"rm"},
+        Summary: "remove labels from an issue",
+        Handler: IssueHandler(HandleLabelRemove),
+        Params: Params{numberParam, required(labelsParam), ownerParam, repoParam},
+        Examples: []string{"issue label remove 152 labels=bug"},
+      },
+    },
+  }
+)
+
+// The pull request subcommands.
+var pullRequest = Subcommand{
+  Name: "pr",
+  Aliases: []string{"pull"},
+  Summary: "work with pull requests",
+  Subcommands: []Subcommand{
+    {
+      Name: "list",
+      Summary: "list pull requests",
+      Handler: IssueHandler(HandlePullList),
+      Params: Params{
+        Param{Name: "state", Type: EnumParam, Values: []string{"open", "closed", "all"}, Default: "open",
+          Help: "Which pull requests to list."},
+        Param{Name: "base", Help: "Only list pull requests into this branch."},
+        Param{Name: "limit", Type: IntParam, Help: "How many to list.  At most 50."},
+        ownerParam,
+        repoParam,
+      },
+      Examples: []string{"pr list", "pr list state=closed base=master"},
+    },
+    {
+      Name: "get",
+      Aliases: []string{"show"},
+      Summary: "show a pull request, with its reviews and checks",
+      Handler: IssueHandler(HandlePullGet),
+      Params: Params{prNumberParam, ownerParam, repoParam},
+      Examples: []string{"pr get 42"},
+    },
+    {
+      Name: "checks",
+      Summary: "show the CI checks of a pull request",
+      Handler: IssueHandler(HandlePullChecks),
+      Params: Params{prNumberParam, ownerParam, repoParam},
+      Examples: []string{"pr checks 42"},
+    },
+    {
+      Name: "review",
+      Summary: "ask people to review a pull request",
+      Handler: IssueHandler(HandlePullReview),
+      Params: Params{
+        prNumberParam,
+        Param{Name: "reviewers", Type: ListParam, Required: true, Aliases: []string{"reviewer"},
+          Help: "GitHub logins to ask for a review."},
+        ownerParam,
+        repoParam,
+      },
+      Examples: []string{"pr review 42 reviewers=steve,ann"},
+    },
+    {
+      Name: "merge",
+      Summary: "merge a pull request, after you confirm.  Only for " + MergeUsers,
+      Handler: IssueHandler(HandlePullMerge),
+      Params: Params{
+        prNumberParam,
+        Param{Name: "method", Type: EnumParam, Values: []string{"merge", "squash", "rebase"}, Default: "merge",
+          Help: "How to merge."},
+        ownerParam,
+        repoParam,
+      },
+      Examples: []string{"pr merge 42", "pr merge 42 method=squash"},
+    },
+  },
+}
+
+var prNumberParam = Param{Name: "number", Type: IntParam, Required: true, Position: 1,
+  Aliases: []string{"pr"}, Help: "Pull request number."}
+
+// The channel config subcommands.
+var channelConfig = Subcommand{
+  Name: "config",
+  Summary: "set the default repo for this channel",
+  Subcommands: []Subcommand{
+    {
+      Name: "set",
+      Summary: "set this channel's default repo.  It is used when a command has no repo=",
+      Handler: IssueHandler(HandleConfigSet),
+      Params: Params{
+        Param{Name: "repo", Help: "Repo name, or owner/name."},
+        Param{Name: "owner", Aliases: []string{"org"}, Help: "Repo owner."},
+      },
+      Examples: []string{"config set repo=confyrm/devhub", "config set owner=confyrm repo=devhub"},
+    },
+    {
+      Name: "get",
+      Aliases: []string{"show"},
+      Summary: "show this channel's default repo",
+      Handler: IssueHandler(HandleConfigGet),
+      Examples: []string{"config get"},
+    },
+    {
+      Name: "unset",
+      Aliases: []string{"clear"},
+      Summary: "go back to the global default repo",
+      Handler: IssueHandler(HandleConfigUnset),
+      Examples: []string{"config unset"},
+    },
+  },
+}
+
+// DevHubSchema declares the /devhub subcommand tree.  SlashRouter validates
+// commands against it before queueing DevHub, DevHub routes through it, and
+// /devhub help is generated from it.
+var DevHubSchema = &Schema{
+  Command: "/devhub",
+  Middleware: []Middleware{RespondToUser},
+  Subcommands: append(withGithub(
+    newIssue,
+    getIssue,
+    updateIssue,
+    closeIssue,
+    listIssues,
+    searchIssues,
+    commentIssue,
+    listComments,
+    Subcommand{
+      Name: "issue",
+      Summary: "work with issues",
+      Subcommands: []Subcommand{newIssue, getIssue, updateIssue, closeIssue, listIssues, searchIssues,
+        commentIssue, listComments, issueLabel},
+    },
+    pullRequest,
+  ),
+    Subcommand{
+      Name: "login",
+      Summary: "link your GitHub account, so DevHub acts as you",
+      Handler: IssueHandler(HandleLogin),
+    },
+    Subcommand{
+      Name: "logout",
+      Summary: "unlink your GitHub account",
+      Handler: IssueHandler(HandleLogout),
+    },
+    channelConfig,
+  ),
+}
+
+// withGithub adds the middleware of subcommands that call GitHub to each
+// of subs.  ChannelDefaults fills in the channel's repo, and ActAsUser
+// makes the calls as the Slack user's linked account.
+func withGithub(subs ...Subcommand) []Subcommand {
+  for i := range subs {
+    subs[i].Middleware = append([]Middleware{ChannelDefaults, ActAsUser}, subs[i].Middleware...)
+  }
+  return subs
+}
+
+// required returns a required copy of p.
+func required(p Param) Param {
+  p.Required = true
+  return p
+}
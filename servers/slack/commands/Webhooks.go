@@ -0,0 +1,216 @@
+package commands
+
+import (
+  "fmt"
+  "strings"
+  "encoding/json"
+  "github.com/google/go-github/github"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack"
+)
+
+// Config keys for routing GitHub webhook notifications to Slack.
+const (
+  // GithubChannels is a map of "owner/repo" to Slack channel id.
+  GithubChannels = "GITHUB_CHANNELS"
+  // GithubDefaultChannel is used for repos that are not in GITHUB_CHANNELS.
+  // If it is not set, events for unmapped repos are dropped.
+  GithubDefaultChannel = "GITHUB_DEFAULT_CHANNEL"
+)
+
+// WebhookHandlerFunc turns a GitHub webhook payload into a Slack message.
+// It returns the "owner/repo" the event came from, so the message can be
+// routed to a channel.  A nil Response means the event is not worth posting.
+type WebhookHandlerFunc func(payload []byte) (string, *slack.Response, error)
+
+// This is the map of GitHub event types (X-GitHub-Event) to handlers that
+// is given to GithubRouter.  The webhook must be subscribed to the same
+// events in GitHub.
+var WebhookHandlers = map[string]WebhookHandlerFunc {
+  "issues": FormatIssuesEvent,
+  "issue_comment": FormatIssueCommentEvent,
+  "pull_request": FormatPullRequestEvent,
+  "push": FormatPushEvent,
+}
+
+// WebhookRepository is the "repository" object in a webhook payload.
+type WebhookRepository struct {
+  FullName string `json:"full_name"`
+  HTMLURL string `json:"html_url"`
+}
+
+type IssuesEvent struct {
+  Action string `json:"action"`
+  Issue *github.Issue `json:"issue"`
+  Repository WebhookRepository `json:"repository"`
+  Sender *github.User `json:"sender"`
+}
+
+type IssueCommentEvent struct {
+  Action string `json:"action"`
+  Issue *github.Issue `json:"issue"`
+  Comment *github.IssueComment `json:"comment"`
+  Repository WebhookRepository `json:"repository"`
+  Sender *github.User `json:"sender"`
+}
+
+type PullRequestEvent struct {
+  Action string `json:"action"`
+  Number int `json:"number"`
+  PullRequest *github.PullRequest `json:"pull_request"`
+  Repository WebhookRepository `json:"repository"`
+  Sender *github.User `json:"sender"`
+}
+
+type PushCommit struct {
+  Id string `json:"id"`
+  Message string `json:"message"`
+  Url string `json:"url"`
+  Author struct {
+    Name string `json:"name"`
+  } `json:"author"`
+}
+
+type PushEvent struct {
+  Ref string `json:"ref"`
+  Compare string `json:"compare"`
+  Forced bool `json:"forced"`
+  Commits []PushCommit `json:"commits"`
+  Pusher struct {
+    Name string `json:"name"`
+  } `json:"pusher"`
+  Repository WebhookRepository `json:"repository"`
+}
+
+// ChannelForRepo looks up the Slack channel for "owner/repo".
+func ChannelForRepo(config *config.Config, repo string) (string, bool) {
+  // Viper lower cases map keys.
+  githubConfig := config.App().Github
+  if channel, ok := githubConfig.Channels[strings.ToLower(repo)]; ok && channel != "" {
+    return channel, true
+  }
+  if channel := githubConfig.DefaultChannel; channel != "" {
+    return channel, true
+  }
+  return "", false
+}
+
+// FormatIssuesEvent reports issues being opened, closed, reopened or
+// assigned.
+func FormatIssuesEvent(payload []byte) (string, *slack.Response, error) {
+  var event IssuesEvent
+  if err := json.Unmarshal(payload, &event); err != nil {
+    return "", nil, fmt.Errorf("Bad issues payload: %s", err.Error())
+  }
+  switch event.Action {
+  case "opened", "closed", "reopened", "assigned":
+  default:
+    return event.Repository.FullName, nil, nil
+  }
+  if event.Issue == nil {
+    return "", nil, fmt.Errorf("issues payload has no issue")
+  }
+
+  title := fmt.Sprintf("[%s] Issue %s by %s", event.Repository.FullName, event.Action, MakeUserLink(event.Sender))
+  atts := slack.Attachments {
+    FormatBasicIssue(event.Issue),
+  }
+  if event.Action != "opened" {
+    atts = append(atts, FormatIssueDetails(event.Issue))
+  }
+  return event.Repository.FullName, &slack.Response{Type: slack.InChannel.String(), Text: &title, Attachments: atts}, nil
+}
+
+// FormatIssueCommentEvent reports new comments on issues and PRs.
+func FormatIssueCommentEvent(payload []byte) (string, *slack.Response, error) {
+  var event IssueCommentEvent
+  if err := json.Unmarshal(payload, &event); err != nil {
+    return "", nil, fmt.Errorf("Bad issue_comment payload: %s", err.Error())
+  }
+  if event.Action != "created" {
+    return event.Repository.FullName, nil, nil
+  }
+  if event.Issue == nil || event.Comment == nil {
+    return "", nil, fmt.Errorf("issue_comment payload has no issue or comment")
+  }
+
+  title := fmt.Sprintf("[%s] New comment by %s on <%s|#%d>: %s", event.Repository.FullName,
+    MakeUserLink(event.Comment.User), GetSafeString(event.Comment.HTMLURL),
+    GetSafeInt(event.Issue.Number), GetSafeString(event.Issue.Title))
+  atts := slack.Attachments {
+    slack.Attachment {
+      Fallback: GetSafeString(event.Comment.Body),
+      Text: slack.Truncate(GetSafeString(event.Comment.Body), slack.MaxSectionTextLength),
+      MarkdownIn: []string{"text"},
+    },
+  }
+  return event.Repository.FullName, &slack.Response{Type: slack.InChannel.String(), Text: &title, Attachments: atts}, nil
+}
+
+// FormatPullRequestEvent reports PRs being opened, closed, merged or
+// reopened.
+func FormatPullRequestEvent(payload []byte) (string, *slack.Response, error) {
+  var event PullRequestEvent
+  if err := json.Unmarshal(payload, &event); err != nil {
+    return "", nil, fmt.Errorf("Bad pull_request payload: %s", err.Error())
+  }
+  action := event.Action
+  switch action {
+  case "opened", "reopened", "ready_for_review":
+  case "closed":
+    if event.PullRequest != nil && event.PullRequest.Merged != nil && *event.PullRequest.Merged {
+      action = "merged"
+    }
+  default:
+    return event.Repository.FullName, nil, nil
+  }
+  if event.PullRequest == nil {
+    return "", nil, fmt.Errorf("pull_request payload has no pull request")
+  }
+
+  title := fmt.Sprintf("[%s] Pull request %s by %s", event.Repository.FullName, action, MakeUserLink(event.Sender))
+  atts := slack.Attachments {
+    FormatBasicPullRequest(event.PullRequest),
+  }
+  return event.Repository.FullName, &slack.Response{Type: slack.InChannel.String(), Text: &title, Attachments: atts}, nil
+}
+
+// FormatPushEvent lists the commits in a push.
+func FormatPushEvent(payload []byte) (string, *slack.Response, error) {
+  var event PushEvent
+  if err := json.Unmarshal(payload, &event); err != nil {
+    return "", nil, fmt.Errorf("Bad push payload: %s", err.Error())
+  }
+  if len(event.Commits) == 0 {
+    // Branch creation or deletion.
+    return event.Repository.FullName, nil, nil
+  }
+
+  branch := strings.TrimPrefix(event.Ref, "refs/heads/")
+  verb := "pushed"
+  if event.Forced {
+    verb = "force pushed"
+  }
+  title := fmt.Sprintf("[%s:%s] %s %s <%s|%d commit(s)>", event.Repository.FullName, branch,
+    event.Pusher.Name, verb, event.Compare, len(event.Commits))
+
+  lines := make([]string, 0, len(event.Commits))
+  for _, commit := range event.Commits {
+    id := commit.Id
+    if len(id) > 7 {
+      id = id[:7]
+    }
+    message := strings.SplitN(commit.Message, "\n", 2)[0]
+    lines = append(lines, fmt.Sprintf("<%s|`%s`> %s - %s", commit.Url, id, message, commit.Author.Name))
+  }
+  text := slack.Truncate(strings.Join(lines, "\n"), slack.MaxSectionTextLength)
+  atts := slack.Attachments {
+    slack.Attachment {
+      Fallback: text,
+      Text: text,
+      Color: slack.GOOD,
+      MarkdownIn: []string{"text"},
+    },
+  }
+  return event.Repository.FullName, &slack.Response{Type: slack.InChannel.String(), Text: &title, Attachments: atts}, nil
+}
@@ -0,0 +1,159 @@
+package routes
+
+import (
+  "fmt"
+  "log"
+  "sync"
+  "time"
+  "context"
+  "net/http"
+  "io/ioutil"
+  "encoding/json"
+
+  "github.com/confyrm/gorest/jobs"
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/slack/command"
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/router/handler"
+  . "github.com/confyrm/gorest/servers/slack/commands"
+)
+
+var eventRouter = EventHandlers.New()
+
+// Slack gives up retrying after about an hour.
+var seenEvents = newEventCache(time.Hour)
+
+// EventsRouter receives Slack Events API requests.  It answers the
+// url_verification challenge, drops retried deliveries of events it has
+// already taken, and routes event_callbacks by event type.  It expects the
+// request to have already been authenticated by handler.VerifySlack.
+func EventsRouter(ctx context.Context, config *config.Config, rw http.ResponseWriter, req *http.Request) error {
+  body, err := ioutil.ReadAll(req.Body)
+  if err != nil {
+    return StatusError{http.StatusBadRequest, err}
+  }
+  envelope := &slack.EventEnvelope{}
+  if err := envelope.Decode(body); err != nil {
+    return StatusError{http.StatusBadRequest, err}
+  }
+
+  switch envelope.Type {
+  case slack.URLVerification:
+    rw.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    return json.NewEncoder(rw).Encode(map[string]string{"challenge": envelope.Challenge})
+  case slack.EventCallback:
+  default:
+    return StatusError{http.StatusBadRequest, fmt.Errorf("Unsupported event envelope [%s]", envelope.Type)}
+  }
+
+  retry := req.Header.Get(slack.RetryNumHeader)
+  if envelope.EventId != "" && seenEvents.Seen(envelope.EventId, time.Now()) {
+    log.Printf("[%s] Dropping retry %s of event %s (%s)", handler.RequestID(ctx), retry,
+      envelope.EventId, req.Header.Get(slack.RetryReasonHeader))
+    rw.WriteHeader(http.StatusOK)
+    return nil
+  }
+  log.Printf("[%s] Received Slack %s event %s", handler.RequestID(ctx), envelope.Event.Type, envelope.EventId)
+
+  route := eventRouter.Route(envelope.Event.Type)
+  if route == nil {
+    // Answer anyway, or Slack will keep retrying an event we will never
+    // handle.
+    log.Printf("[%s] No handler for %s events", handler.RequestID(ctx), envelope.Event.Type)
+    rw.WriteHeader(http.StatusOK)
+    return nil
+  }
+  if route.Accept != nil && !route.Accept(config, envelope) {
+    rw.WriteHeader(http.StatusOK)
+    return nil
+  }
+
+  if route.IsLong {
+    if jobs.Default == nil {
+      seenEvents.Forget(envelope.EventId)
+      return StatusError{http.StatusServiceUnavailable, fmt.Errorf("Job pool is not running")}
+    }
+    id, err := jobs.Default.Submit(ctx, EventJob(config, route, envelope))
+    if err != nil {
+      // Let Slack retry later.
+      seenEvents.Forget(envelope.EventId)
+      return StatusError{http.StatusServiceUnavailable, err}
+    }
+    log.Printf("[%s] Queued job %s", handler.RequestID(ctx), id)
+  } else {
+    if statusErr := route.Handler(ctx, config, envelope); statusErr != nil {
+      seenEvents.Forget(envelope.EventId)
+      return *statusErr
+    }
+  }
+
+  rw.WriteHeader(http.StatusOK)
+  return nil
+}
+
+// EventJob wraps a long running event handler as a jobs.Job.
+func EventJob(config *config.Config, route *command.Event, envelope *slack.EventEnvelope) jobs.Job {
+  return jobs.Job{
+    Name: fmt.Sprintf("event %s", envelope.Event.Type),
+    User: envelope.Event.User,
+    Run: func(ctx context.Context) error {
+      if statusErr := route.Handler(ctx, config, envelope); statusErr != nil {
+        return statusErr
+      }
+      return nil
+    },
+  }
+}
+
+// eventCache remembers recently seen event_ids.  The ids are also kept in
+// the order they were seen, so the expired ones can be dropped from the
+// front without looking at the rest.
+type eventCache struct {
+  mu sync.Mutex
+  ttl time.Duration
+  seen map[string]time.Time
+  order []seenEvent
+}
+
+type seenEvent struct {
+  id string
+  at time.Time
+}
+
+func newEventCache(ttl time.Duration) *eventCache {
+  return &eventCache{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+// Seen records id, and returns true if it was already recorded.
+func (c *eventCache) Seen(id string, now time.Time) bool {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  c.expire(now)
+  if _, ok := c.seen[id]; ok {
+    return true
+  }
+  c.seen[id] = now
+  c.order = append(c.order, seenEvent{id, now})
+  return false
+}
+
+// expire drops the ids older than the ttl.  An id that was forgotten and
+// seen again is still in order under its old time, so it is only dropped if
+// the times match.  c.mu must be held.
+func (c *eventCache) expire(now time.Time) {
+  for len(c.order) > 0 && now.Sub(c.order[0].at) > c.ttl {
+    e := c.order[0]
+    c.order = c.order[1:]
+    if at, ok := c.seen[e.id]; ok && at.Equal(e.at) {
+      delete(c.seen, e.id)
+    }
+  }
+}
+
+// Forget removes id, so a retry of it will be handled.
+func (c *eventCache) Forget(id string) {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  delete(c.seen, id)
+}
@@ -0,0 +1,82 @@
+package routes
+
+import (
+  "fmt"
+  "log"
+  "context"
+  "net/http"
+  "io/ioutil"
+
+  "github.com/confyrm/gorest/jobs"
+  "github.com/confyrm/gorest/slack"
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/router/handler"
+  . "github.com/confyrm/gorest/servers/slack/commands"
+)
+
+// GithubWebhook receives GitHub webhook deliveries and posts a summary of
+// them to the Slack channel mapped to the repo.  Posting is done on the job
+// pool, so GitHub gets its answer well inside its 10 second timeout.  It
+// expects the request to have already been authenticated by
+// handler.VerifyGithub.
+func GithubWebhook(ctx context.Context, config *config.Config, rw http.ResponseWriter, req *http.Request) error {
+  event := req.Header.Get(handler.GithubEventHeader)
+  delivery := req.Header.Get(handler.GithubDeliveryHeader)
+  if event == "ping" {
+    log.Printf("[%s] GitHub ping, delivery %s", handler.RequestID(ctx), delivery)
+    rw.WriteHeader(http.StatusOK)
+    return nil
+  }
+
+  format, ok := WebhookHandlers[event]
+  if !ok {
+    // Answer anyway, or GitHub marks the delivery as failed.
+    log.Printf("[%s] No handler for GitHub %s events", handler.RequestID(ctx), event)
+    rw.WriteHeader(http.StatusOK)
+    return nil
+  }
+
+  body, err := ioutil.ReadAll(req.Body)
+  if err != nil {
+    return StatusError{http.StatusBadRequest, err}
+  }
+  repo, response, err := format(body)
+  if err != nil {
+    return StatusError{http.StatusBadRequest, err}
+  }
+  if response == nil {
+    rw.WriteHeader(http.StatusOK)
+    return nil
+  }
+  channel, ok := ChannelForRepo(config, repo)
+  if !ok {
+    log.Printf("[%s] No Slack channel for %s, dropping %s event", handler.RequestID(ctx), repo, event)
+    rw.WriteHeader(http.StatusOK)
+    return nil
+  }
+
+  if jobs.Default == nil {
+    return StatusError{http.StatusServiceUnavailable, fmt.Errorf("Job pool is not running")}
+  }
+  id, err := jobs.Default.Submit(ctx, WebhookJob(config, event, slack.NewMessage(channel, response)))
+  if err != nil {
+    return StatusError{http.StatusServiceUnavailable, err}
+  }
+  log.Printf("[%s] Queued job %s for GitHub delivery %s", handler.RequestID(ctx), id, delivery)
+
+  rw.WriteHeader(http.StatusOK)
+  return nil
+}
+
+// WebhookJob posts msg as a jobs.Job.
+func WebhookJob(config *config.Config, event string, msg *slack.Message) jobs.Job {
+  return jobs.Job{
+    Name: fmt.Sprintf("github %s", event),
+    User: "github",
+    Run: func(ctx context.Context) error {
+      _, err := NewSlackClient(config).ChatPostMessage(ctx, msg)
+      return err
+    },
+  }
+}
@@ -0,0 +1,125 @@
+package routes
+
+import (
+  "fmt"
+  "log"
+  "context"
+  "net/http"
+  "encoding/json"
+
+  "github.com/confyrm/gorest/jobs"
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/slack/command"
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/router/handler"
+  . "github.com/confyrm/gorest/servers/slack/commands"
+)
+
+var actionRouter = InteractiveActions.New()
+
+// InteractiveRouter handles Slack interactivity requests: button clicks and
+// menu selections (block_actions and legacy interactive_message), modal
+// submissions (view_submission) and shortcuts.  It expects the request to
+// have already been authenticated by handler.VerifySlack.
+func InteractiveRouter(ctx context.Context, config *config.Config, rw http.ResponseWriter, req *http.Request) error {
+  interaction := &slack.Interaction{}
+  if err := interaction.DecodeHttp(req); err != nil {
+    return StatusError{http.StatusBadRequest, err}
+  }
+  log.Printf("[%s] Received Slack %s interaction from %s", handler.RequestID(ctx), interaction.Type, interaction.UserName())
+
+  var action *slack.Action
+  var key string
+  switch interaction.Type {
+  case slack.BlockActions, slack.InteractiveMessage:
+    // Slack only ever sends one action at a time.
+    if len(interaction.Actions) == 0 {
+      return StatusError{http.StatusBadRequest, fmt.Errorf("No actions in %s payload", interaction.Type)}
+    }
+    action = &interaction.Actions[0]
+    key = action.Key()
+  case slack.ViewSubmission, slack.ViewClosed:
+    if interaction.View == nil {
+      return StatusError{http.StatusBadRequest, fmt.Errorf("No view in %s payload", interaction.Type)}
+    }
+    key = interaction.View.CallbackId
+  case slack.Shortcut, slack.MessageAction:
+    key = interaction.CallbackId
+  default:
+    return StatusError{http.StatusBadRequest, fmt.Errorf("Unsupported interaction type [%s]", interaction.Type)}
+  }
+
+  route := actionRouter.Route(key)
+  if route == nil {
+    return StatusError{http.StatusBadRequest, fmt.Errorf("Action not found [%s]", key)}
+  }
+
+  var response *slack.Response
+  if route.IsLong {
+    // Slack wants an answer within 3 seconds.  Queue a job, and let it post
+    // the outcome to the response_url.
+    if jobs.Default == nil {
+      return StatusError{http.StatusServiceUnavailable, fmt.Errorf("Job pool is not running")}
+    }
+    id, err := jobs.Default.Submit(ctx, ActionJob(config, route, interaction, action, key))
+    switch err {
+    case nil:
+      log.Printf("[%s] Queued job %s", handler.RequestID(ctx), id)
+    case jobs.ErrBusy, jobs.ErrStopped:
+      text := "Whoa, I'm a little busy right now!\r\nPlease try again in a minute."
+      response = &slack.Response{Type: slack.Ephemeral.String(), Text: &text}
+    default:
+      return StatusError{http.StatusInternalServerError, err}
+    }
+  } else {
+    var statusErr *StatusError
+    response, statusErr = route.Handler(ctx, config, interaction, action)
+    if statusErr != nil {
+      return *statusErr
+    }
+  }
+
+  // An empty 200 tells Slack the interaction was handled.  For a view, it
+  // also closes the modal.
+  if response == nil {
+    rw.WriteHeader(http.StatusOK)
+    return nil
+  }
+  rw.Header().Set("Content-Type", "application/json; charset=UTF-8")
+  if err := json.NewEncoder(rw).Encode(response); err != nil {
+    return StatusError{http.StatusInternalServerError,
+      fmt.Errorf("Failure while encoding response data: %s", err.Error())}
+  }
+  return nil
+}
+
+// ActionJob wraps a long running action handler as a jobs.Job.  The
+// response, or the error, is posted to the interaction's response_url.  Views
+// have no response_url, so their handlers have to tell the user themselves.
+func ActionJob(config *config.Config, route *command.Action, interaction *slack.Interaction, action *slack.Action, key string) jobs.Job {
+  sReq := interaction.Request()
+  return jobs.Job{
+    Name: fmt.Sprintf("%s %s", interaction.Type, key),
+    User: interaction.UserName(),
+    Run: func(ctx context.Context) error {
+      response, statusErr := route.Handler(ctx, config, interaction, action)
+      if statusErr != nil {
+        if sReq.ResponseUrl != "" {
+          RespondWithError(ctx, sReq, statusErr)
+        }
+        return statusErr
+      }
+      if response != nil && sReq.ResponseUrl != "" {
+        return sReq.RespondWithContext(ctx, response)
+      }
+      return nil
+    },
+    Recover: func(ctx context.Context, err error) {
+      if sReq.ResponseUrl == "" {
+        return
+      }
+      RespondWithError(ctx, sReq, fmt.Errorf("Your %s request crashed: %s", key, err.Error()))
+    },
+  }
+}
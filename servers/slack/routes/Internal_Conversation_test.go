@@ -0,0 +1,229 @@
+package routes
+
+import (
+  "testing"
+  "context"
+  "net/http"
+  "net/http/httptest"
+
+  "github.com/confyrm/gorest/jobs"
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/store"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/githubclient"
+  "github.com/confyrm/gorest/slack/slacktest"
+  "github.com/confyrm/gorest/router/handler"
+  "github.com/confyrm/gorest/githubclient/githubtest"
+  "github.com/google/go-github/github"
+  . "github.com/confyrm/gorest/servers/slack/commands"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+const testSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+// harness is the app, wired up to a fake Slack and a fake GitHub.
+type harness struct {
+  Slack *slacktest.Server
+  Github *githubtest.Server
+  Repo *githubtest.Repo
+  Config *config.Config
+  Store store.Store
+  app *httptest.Server
+}
+
+func newHarness() *harness {
+  gh := githubtest.NewServer()
+  r := gh.Repo("o", "r")
+  bug := r.AddLabel("bug")
+  r.AddMilestone("v1")
+  r.AddAssignee("alice")
+  login := r.AddIssue("Login is broken")
+  login.Labels = []github.Label{*bug}
+  r.AddIssue("Add dark mode")
+
+  s := slacktest.NewServer(testSecret)
+  c := config.New(nil, &map[string]interface{} {
+    handler.SlackSigningSecret: testSecret,
+    SlackBotToken: "xoxb-test",
+    SlackApiUrl: s.ApiUrl(),
+    githubclient.ApiUrl: gh.URL,
+    "GITHUB_TOKEN": "test-token",
+    githubclient.DefaultOwner: "o",
+    githubclient.DefaultRepo: "r",
+    GithubUsers: map[string]string{"U1": "alice"},
+  })
+
+  jobs.Default = jobs.New(2, 8, 10, jobs.DefaultTimeout)
+  jobs.Default.Start()
+  st := store.NewMemory()
+  app := httptest.NewServer(RouteSet.New(c, st))
+  s.App = app.URL
+  return &harness{s, gh, r, c, st, app}
+}
+
+func (h *harness) Close() {
+  h.app.Close()
+  jobs.Default.Shutdown(context.Background())
+  jobs.Default = nil
+  h.Slack.Close()
+  h.Github.Close()
+}
+
+func TestSlashConversation(t *testing.T) {
+  Convey("Given the app and a fake Slack", t, func() {
+    h := newHarness()
+    defer h.Close()
+
+    Convey("When a long command is sent", func() {
+      c, err := h.Slack.Slash("/devhub", "issue get 1")
+      So(err, ShouldBeNil)
+
+      Convey("It should be answered right away, and then at the response_url", func() {
+        So(c.Status, ShouldEqual, http.StatusOK)
+        So(slacktest.Contains(c.Reply, "Roger that!"), ShouldBeTrue)
+        responses, err := c.Wait(1)
+        So(err, ShouldBeNil)
+        So(slacktest.Contains(responses[0], "Login is broken"), ShouldBeTrue)
+      })
+    })
+
+    Convey("When a bare /devhub list is sent", func() {
+      c, err := h.Slack.Slash("/devhub", "list")
+      So(err, ShouldBeNil)
+
+      Convey("The open issues should be listed, not the help", func() {
+        So(c.Status, ShouldEqual, http.StatusOK)
+        So(slacktest.Contains(c.Reply, "Roger that!"), ShouldBeTrue)
+        responses, err := c.Wait(1)
+        So(err, ShouldBeNil)
+        So(slacktest.Contains(responses[0], "Login is broken"), ShouldBeTrue)
+        So(slacktest.Contains(responses[0], "Add dark mode"), ShouldBeTrue)
+      })
+    })
+
+    Convey("When a command does not fit the schema", func() {
+      c, err := h.Slack.Slash("/devhub", "issue get 1 limit=lots")
+      So(err, ShouldBeNil)
+
+      Convey("It should be told so right away, with nothing queued", func() {
+        So(c.Status, ShouldEqual, http.StatusOK)
+        So(c.Reply, ShouldNotBeNil)
+        So(slacktest.Contains(c.Reply, "Roger that!"), ShouldBeFalse)
+        So(c.Responses(), ShouldBeEmpty)
+      })
+    })
+
+    Convey("When the request is signed with the wrong secret", func() {
+      h.Slack.SigningSecret = "not the secret"
+      c, err := h.Slack.Slash("/devhub", "issue get 1")
+      So(err, ShouldBeNil)
+
+      Convey("It should be rejected", func() {
+        So(c.Status, ShouldEqual, http.StatusUnauthorized)
+        So(c.Responses(), ShouldBeEmpty)
+      })
+    })
+
+    Convey("When a Close button is clicked", func() {
+      c, err := h.Slack.Click(CloseIssue, "o/r#1")
+      So(err, ShouldBeNil)
+
+      Convey("The issue should be closed, and the channel told", func() {
+        So(c.Status, ShouldEqual, http.StatusOK)
+        responses, err := c.Wait(1)
+        So(err, ShouldBeNil)
+        So(slacktest.Contains(responses[0], "closed an issue"), ShouldBeTrue)
+        So(*h.Repo.Issues[1].State, ShouldEqual, "closed")
+      })
+    })
+
+    Convey("When a bare new is sent, and the form submitted", func() {
+      c, err := h.Slack.Slash("/devhub", "new")
+      So(err, ShouldBeNil)
+      So(c.Status, ShouldEqual, http.StatusOK)
+      _, err = h.Slack.WaitForCalls("views.update", 1)
+      So(err, ShouldBeNil)
+      opened := h.Slack.Calls("views.open")
+      So(len(opened), ShouldEqual, 1)
+      var args struct {
+        TriggerId string `json:"trigger_id"`
+        View slack.View `json:"view"`
+      }
+      So(opened[0].Decode(&args), ShouldBeNil)
+      So(args.TriggerId, ShouldEqual, c.TriggerId)
+
+      view := args.View
+      view.State.Values = map[string]map[string]slack.ViewStateValue {
+        "title": {"title": {Type: "plain_text_input", Value: "Crash on save"}},
+        "labels": {"labels": {Type: "multi_static_select", SelectedOptions: []slack.OptionValue{{Value: "bug"}}}},
+      }
+      submitted, err := h.Slack.Submit(&view)
+      So(err, ShouldBeNil)
+
+      Convey("The issue should be created and posted to the channel", func() {
+        So(submitted.Status, ShouldEqual, http.StatusOK)
+        posted, err := h.Slack.WaitForCalls("chat.postMessage", 1)
+        So(err, ShouldBeNil)
+        msg, err := posted[0].Message()
+        So(err, ShouldBeNil)
+        So(msg.Channel, ShouldEqual, h.Slack.ChannelId)
+        issue := h.Repo.Issues[3]
+        So(issue, ShouldNotBeNil)
+        So(*issue.Title, ShouldEqual, "Crash on save")
+        So(*issue.Labels[0].Name, ShouldEqual, "bug")
+      })
+    })
+
+    Convey("When an issue link is shared", func() {
+      c, err := h.Slack.Event(&slack.EventEnvelope{Event: slack.Event{
+        Type: "link_shared",
+        Channel: "C1",
+        MessageTs: "1.000100",
+        Links: []slack.SharedLink{{Domain: "github.com", Url: "https://github.com/o/r/issues/2"}},
+      }})
+      So(err, ShouldBeNil)
+
+      Convey("It should be unfurled", func() {
+        So(c.Status, ShouldEqual, http.StatusOK)
+        calls, err := h.Slack.WaitForCalls("chat.unfurl", 1)
+        So(err, ShouldBeNil)
+        var args struct {
+          Ts string `json:"ts"`
+          Unfurls slack.Unfurls `json:"unfurls"`
+        }
+        So(calls[0].Decode(&args), ShouldBeNil)
+        So(args.Ts, ShouldEqual, "1.000100")
+        So(args.Unfurls, ShouldContainKey, "https://github.com/o/r/issues/2")
+      })
+    })
+  })
+}
+
+func TestLoginConversation(t *testing.T) {
+  Convey("Given the app with account linking, and a fake Slack", t, func() {
+    h := newHarness()
+    defer h.Close()
+    h.Config.Set(githubclient.ClientId, "gh-client")
+    h.Config.Set(githubclient.ClientSecret, "gh-secret")
+    h.Config.Set(githubclient.RedirectUrl, "https://devhub.example.com/github/oauth")
+    h.Config.Set(githubclient.TokenKey, "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+    h.Config.Set("SLACK_CLIENT_ID", "slack-client")
+    h.Config.Set("SLACK_CLIENT_SECRET", "slack-secret")
+    h.Config.Set("SLACK_OAUTH_REDIRECT_URL", h.app.URL + "/slack/oauth")
+    So(SetupAccountLinking(h.Config, store.Bucket{h.Store, TokensBucket}), ShouldBeNil)
+    defer SetupAccountLinking(config.New(nil, &map[string]interface{}{}), nil)
+
+    Convey("When /devhub login is sent", func() {
+      c, err := h.Slack.Slash("/devhub", "login")
+      So(err, ShouldBeNil)
+      So(c.Status, ShouldEqual, http.StatusOK)
+      responses, err := c.Wait(1)
+      So(err, ShouldBeNil)
+
+      Convey("The login link should come back", func() {
+        So(slacktest.Contains(responses[0], "Link your GitHub account"), ShouldBeTrue)
+        So(slacktest.Contains(responses[0], slack.SignInAuthorizeUrl), ShouldBeTrue)
+      })
+    })
+  })
+}
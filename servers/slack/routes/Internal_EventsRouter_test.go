@@ -0,0 +1,148 @@
+package routes
+
+import (
+  "time"
+  "bytes"
+  "testing"
+  "context"
+  "net/http"
+  "encoding/json"
+  "net/http/httptest"
+
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/slack/command"
+  . "github.com/confyrm/gorest/errors"
+  . "github.com/confyrm/gorest/servers/slack/commands"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+// postEvent sends envelope straight to EventsRouter.
+func postEvent(c *config.Config, envelope *slack.EventEnvelope) (*httptest.ResponseRecorder, error) {
+  body, err := json.Marshal(envelope)
+  if err != nil {
+    return nil, err
+  }
+  req := httptest.NewRequest("POST", "/events", bytes.NewReader(body))
+  req.Header.Set("Content-Type", "application/json")
+  rw := httptest.NewRecorder()
+  return rw, EventsRouter(context.Background(), c, rw, req)
+}
+
+func TestEventsRouter(t *testing.T) {
+  Convey("Given the events router with a recording handler", t, func() {
+    handled := []string{}
+    defer func(router command.EventRouter, seen *eventCache) {
+      eventRouter = router
+      seenEvents = seen
+    }(eventRouter, seenEvents)
+    eventRouter = command.Events{
+      command.Event{
+        Name: "reaction_added",
+        Handler: func(ctx context.Context, config *config.Config, envelope *slack.EventEnvelope) *StatusError {
+          handled = append(handled, envelope.EventId)
+          return nil
+        },
+      },
+    }.New()
+    seenEvents = newEventCache(time.Hour)
+    c := config.New(nil, &map[string]interface{}{})
+
+    Convey("A url_verification should be answered with its challenge", func() {
+      rw, err := postEvent(c, &slack.EventEnvelope{Type: slack.URLVerification, Challenge: "abc123"})
+      So(err, ShouldBeNil)
+      var answer map[string]string
+      So(json.Unmarshal(rw.Body.Bytes(), &answer), ShouldBeNil)
+      So(answer["challenge"], ShouldEqual, "abc123")
+      So(handled, ShouldBeEmpty)
+    })
+
+    Convey("An event should be routed to the handler for its type", func() {
+      rw, err := postEvent(c, &slack.EventEnvelope{Type: slack.EventCallback, EventId: "Ev1",
+        Event: slack.Event{Type: "reaction_added"}})
+      So(err, ShouldBeNil)
+      So(rw.Code, ShouldEqual, http.StatusOK)
+      So(handled, ShouldResemble, []string{"Ev1"})
+    })
+
+    Convey("An event with no handler should still be answered", func() {
+      rw, err := postEvent(c, &slack.EventEnvelope{Type: slack.EventCallback, EventId: "Ev2",
+        Event: slack.Event{Type: "team_join"}})
+      So(err, ShouldBeNil)
+      So(rw.Code, ShouldEqual, http.StatusOK)
+      So(handled, ShouldBeEmpty)
+    })
+
+    Convey("A retried event_id should only be handled once", func() {
+      envelope := &slack.EventEnvelope{Type: slack.EventCallback, EventId: "Ev3",
+        Event: slack.Event{Type: "reaction_added"}}
+      for i := 0; i < 3; i++ {
+        rw, err := postEvent(c, envelope)
+        So(err, ShouldBeNil)
+        So(rw.Code, ShouldEqual, http.StatusOK)
+      }
+      So(handled, ShouldResemble, []string{"Ev3"})
+    })
+  })
+}
+
+func TestEventsRouterAccept(t *testing.T) {
+  Convey("Given the app's events, and no job pool", t, func() {
+    defer func(router command.EventRouter, seen *eventCache) {
+      eventRouter = router
+      seenEvents = seen
+    }(eventRouter, seenEvents)
+    eventRouter = EventHandlers.New()
+    seenEvents = newEventCache(time.Hour)
+    reply := slack.Event{Type: "message", Channel: "C1", User: "U1", Ts: "2.000100", ThreadTs: "1.000100"}
+
+    Convey("With thread sync off, a thread reply should be dropped without a job", func() {
+      c := config.New(nil, &map[string]interface{}{})
+      rw, err := postEvent(c, &slack.EventEnvelope{Type: slack.EventCallback, EventId: "Ev1", Event: reply})
+      So(err, ShouldBeNil)
+      So(rw.Code, ShouldEqual, http.StatusOK)
+    })
+
+    Convey("With thread sync on, a message that is not a reply should be dropped without a job", func() {
+      c := config.New(nil, &map[string]interface{} {SlackThreadSync: true})
+      message := reply
+      message.ThreadTs = ""
+      rw, err := postEvent(c, &slack.EventEnvelope{Type: slack.EventCallback, EventId: "Ev2", Event: message})
+      So(err, ShouldBeNil)
+      So(rw.Code, ShouldEqual, http.StatusOK)
+    })
+
+    Convey("With thread sync on, a thread reply should need a job", func() {
+      c := config.New(nil, &map[string]interface{} {SlackThreadSync: true})
+      _, err := postEvent(c, &slack.EventEnvelope{Type: slack.EventCallback, EventId: "Ev3", Event: reply})
+      So(err, ShouldNotBeNil)
+      So(err.Error(), ShouldContainSubstring, "Job pool is not running")
+    })
+  })
+}
+
+func TestEventCache(t *testing.T) {
+  Convey("Given an event cache", t, func() {
+    cache := newEventCache(time.Minute)
+    start := time.Now()
+    So(cache.Seen("a", start), ShouldBeFalse)
+    So(cache.Seen("b", start.Add(30 * time.Second)), ShouldBeFalse)
+
+    Convey("An id should be seen until the ttl passes", func() {
+      So(cache.Seen("a", start.Add(time.Minute)), ShouldBeTrue)
+      So(cache.Seen("a", start.Add(61 * time.Second)), ShouldBeFalse)
+      So(cache.Seen("b", start.Add(61 * time.Second)), ShouldBeTrue)
+      So(len(cache.order), ShouldEqual, 2)
+    })
+
+    Convey("A forgotten id should not be seen", func() {
+      cache.Forget("a")
+      So(cache.Seen("a", start.Add(40 * time.Second)), ShouldBeFalse)
+
+      Convey("And it should expire from when it was seen again", func() {
+        So(cache.Seen("a", start.Add(61 * time.Second)), ShouldBeTrue)
+        So(cache.Seen("a", start.Add(101 * time.Second)), ShouldBeFalse)
+      })
+    })
+  })
+}
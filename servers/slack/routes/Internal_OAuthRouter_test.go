@@ -0,0 +1,48 @@
+package routes
+
+import (
+  "testing"
+  "context"
+  "net/http"
+  "net/http/httptest"
+
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/githubclient"
+  . "github.com/confyrm/gorest/errors"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGithubOAuth(t *testing.T) {
+  Convey("Given the GitHub OAuth callback", t, func() {
+    c := config.New(nil, &map[string]interface{}{})
+    callback := func(query string, cookie *http.Cookie) (*httptest.ResponseRecorder, error) {
+      req := httptest.NewRequest("GET", "/github/oauth" + query, nil)
+      if cookie != nil {
+        req.AddCookie(cookie)
+      }
+      rw := httptest.NewRecorder()
+      return rw, GithubOAuth(context.Background(), c, rw, req)
+    }
+
+    Convey("A callback without the session cookie should be refused", func() {
+      _, err := callback("?code=abc&state=xyz", nil)
+      So(err, ShouldHaveSameTypeAs, StatusError{})
+      So(err.(StatusError).Code, ShouldEqual, http.StatusBadRequest)
+      So(err.Error(), ShouldContainSubstring, "in this browser")
+    })
+
+    Convey("The session cookie should be cleared, even if the link fails", func() {
+      rw, err := callback("?code=abc&state=xyz", &http.Cookie{Name: githubclient.SessionCookie, Value: "session"})
+      So(err, ShouldNotBeNil)
+      cookies := rw.Result().Cookies()
+      So(len(cookies), ShouldEqual, 1)
+      So(cookies[0].Name, ShouldEqual, githubclient.SessionCookie)
+      So(cookies[0].MaxAge, ShouldBeLessThan, 0)
+    })
+
+    Convey("A denied login should say so", func() {
+      _, err := callback("?error=access_denied", nil)
+      So(err.Error(), ShouldContainSubstring, "GitHub login was not completed: access_denied")
+    })
+  })
+}
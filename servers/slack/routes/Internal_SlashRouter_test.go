@@ -0,0 +1,100 @@
+package routes
+
+import (
+  "fmt"
+  "time"
+  "strings"
+  "testing"
+  "context"
+  "net/url"
+  "net/http"
+  "net/http/httptest"
+
+  "github.com/confyrm/gorest/jobs"
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/slack/command"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/githubclient"
+  . "github.com/confyrm/gorest/servers/slack/commands"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSlashRouterBare(t *testing.T) {
+  Convey("Given a Slack Web API that records its calls", t, func() {
+    called := make(chan string, 4)
+    api := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+      called <- strings.TrimPrefix(req.URL.Path, "/api/")
+      // Failing views.open ends the job before it talks to GitHub.
+      rw.Header().Set("Content-Type", "application/json")
+      rw.Write([]byte(`{"ok": false, "error": "expired_trigger_id"}`))
+    }))
+    defer api.Close()
+    c := config.New(nil, &map[string]interface{} {
+      SlackBotToken: "xoxb-test",
+      SlackApiUrl: api.URL + "/api/",
+      githubclient.DefaultOwner: "o",
+      githubclient.DefaultRepo: "r",
+    })
+    jobs.Default = jobs.New(1, 1, 10, jobs.DefaultTimeout)
+    jobs.Default.Start()
+    defer func() {
+      jobs.Default.Shutdown(context.Background())
+      jobs.Default = nil
+    }()
+
+    Convey("When a bare /devhub new is sent", func() {
+      form := url.Values{
+        "command": {"/devhub"},
+        "text": {"new"},
+        "channel_id": {"C1"},
+        "user_id": {"U1"},
+        "trigger_id": {"1.trigger"},
+        "response_url": {api.URL + "/response/1"},
+      }
+      req := httptest.NewRequest("POST", "/slack", strings.NewReader(form.Encode()))
+      req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+      rw := httptest.NewRecorder()
+      err := SlashRouter(context.Background(), c, rw, req)
+
+      Convey("It should open the new issue form, not show help", func() {
+        So(err, ShouldBeNil)
+        So(rw.Body.String(), ShouldContainSubstring, "Roger that!")
+        select {
+        case method := <-called:
+          So(method, ShouldEqual, "views.open")
+        case <-time.After(5 * time.Second):
+          So("no Web API call", ShouldBeNil)
+        }
+      })
+    })
+  })
+}
+
+func TestRunsBare(t *testing.T) {
+  route := &command.Command{Name: "/devhub", Schema: DevHubSchema}
+  tests := []struct {
+    Text string
+    Runs bool
+  } {
+    {"new", true},
+    {"list", true},
+    {"login", true},
+    {"logout", true},
+    {"ls", true},
+    {"issue", false},
+    {"pr", false},
+    {"config", false},
+    {"get", false},
+    {"comment", false},
+    {"help", false},
+    {"nope", false},
+    {"list state=closed", false},
+  }
+  for _, test := range tests {
+    Convey(fmt.Sprintf("Given /devhub %s", test.Text), t, func() {
+      cmd, err := (&slack.Request{Text: test.Text}).TextToCommand()
+      So(err, ShouldBeNil)
+      So(runsBare(route, cmd), ShouldEqual, test.Runs)
+    })
+  }
+}
@@ -0,0 +1,83 @@
+package routes
+
+import (
+  "fmt"
+  "log"
+  "context"
+  "strings"
+  "net/http"
+
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/githubclient"
+  "github.com/confyrm/gorest/router/handler"
+  . "github.com/confyrm/gorest/servers/slack/commands"
+)
+
+// SlackSignIn is the callback of Sign in with Slack, the first stop of
+// the link from /devhub login.  Once the Slack user is known to be the one
+// that ran the command, it sets the session cookie and sends them on to
+// GitHub.
+func SlackSignIn(ctx context.Context, config *config.Config, rw http.ResponseWriter, req *http.Request) error {
+  code, state, err := callbackParams(req, "Slack")
+  if err != nil {
+    return err
+  }
+  session, next, err := SignInWithSlack(ctx, config, code, state)
+  if err != nil {
+    log.Printf("[%s] Sign in with Slack failed: %s", handler.RequestID(ctx), err.Error())
+    return StatusError{http.StatusBadRequest, err}
+  }
+  http.SetCookie(rw, &http.Cookie{
+    Name: githubclient.SessionCookie,
+    Value: session,
+    Path: "/",
+    MaxAge: int(githubclient.StateTTL.Seconds()),
+    Secure: strings.HasPrefix(config.App().Github.RedirectUrl, "https:"),
+    HttpOnly: true,
+    SameSite: http.SameSiteLaxMode,
+  })
+  http.Redirect(rw, req, next, http.StatusFound)
+  return nil
+}
+
+// GithubOAuth is the callback of the GitHub OAuth app.  GitHub sends the
+// user here after they authorize the app.  It is not wrapped by a Verify
+// handler: the signed state, and the session cookie set by SlackSignIn, are
+// what tie the request to a Slack user.
+func GithubOAuth(ctx context.Context, config *config.Config, rw http.ResponseWriter, req *http.Request) error {
+  code, state, err := callbackParams(req, "GitHub")
+  if err != nil {
+    return err
+  }
+  cookie, err := req.Cookie(githubclient.SessionCookie)
+  if err != nil {
+    return StatusError{http.StatusBadRequest, fmt.Errorf("No login in progress.  Start again from /devhub login, in this browser.")}
+  }
+  // The cookie is only good for one try.
+  http.SetCookie(rw, &http.Cookie{Name: githubclient.SessionCookie, Path: "/", MaxAge: -1})
+
+  login, err := LinkAccount(ctx, config, code, state, cookie.Value)
+  if err != nil {
+    log.Printf("[%s] GitHub account link failed: %s", handler.RequestID(ctx), err.Error())
+    return StatusError{http.StatusBadRequest, err}
+  }
+  log.Printf("[%s] Linked GitHub account %s", handler.RequestID(ctx), login)
+
+  rw.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+  fmt.Fprintf(rw, "Your Slack account is now linked to GitHub as %s.  You can close this window.\n", login)
+  return nil
+}
+
+// callbackParams returns the code and state of an OAuth callback from site.
+func callbackParams(req *http.Request, site string) (string, string, error) {
+  query := req.URL.Query()
+  if reason := query.Get("error"); reason != "" {
+    return "", "", StatusError{http.StatusBadRequest, fmt.Errorf("%s login was not completed: %s", site, reason)}
+  }
+  code, state := query.Get("code"), query.Get("state")
+  if code == "" || state == "" {
+    return "", "", StatusError{http.StatusBadRequest, fmt.Errorf("Missing code or state")}
+  }
+  return code, state, nil
+}
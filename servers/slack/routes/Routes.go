@@ -5,11 +5,14 @@ package routes
 
 import (
   "github.com/confyrm/gorest/router"
+  "github.com/confyrm/gorest/router/handler"
 )
 
 // RouteSet is the static set of http routes.  To add a new route:
 // 1. Create a new route handler.  See Index.go in this package for Example.
 // 2. Add a router.Route to RouteSet.
+// Routes that Slack calls must be wrapped with handler.VerifySlack, and
+// routes that GitHub calls with handler.VerifyGithub.
 var RouteSet = router.Routes{
   router.Route {
     "Index",
@@ -21,12 +24,42 @@ var RouteSet = router.Routes{
     "SlashCommand",
     "POST",
     "/cmd",
-    SlashRouter,
+    handler.VerifySlack(handler.Contextual(SlashRouter)),
   },
   router.Route {
     "SlashCommand",
     "POST",
     "/slack",
-    SlashRouter,
+    handler.VerifySlack(handler.Contextual(SlashRouter)),
+  },
+  router.Route {
+    "Interactive",
+    "POST",
+    "/interactive",
+    handler.VerifySlack(handler.Contextual(InteractiveRouter)),
+  },
+  router.Route {
+    "Events",
+    "POST",
+    "/events",
+    handler.VerifySlack(handler.Contextual(EventsRouter)),
+  },
+  router.Route {
+    "GithubWebhook",
+    "POST",
+    "/github",
+    handler.VerifyGithub(handler.Contextual(GithubWebhook)),
+  },
+  router.Route {
+    "SlackSignIn",
+    "GET",
+    "/slack/oauth",
+    handler.Contextual(SlackSignIn),
+  },
+  router.Route {
+    "GithubOAuth",
+    "GET",
+    "/github/oauth",
+    handler.Contextual(GithubOAuth),
   },
 }
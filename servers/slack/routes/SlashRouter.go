@@ -7,11 +7,16 @@ import (
   "fmt"
   "log"
 	"strings"
+  "sync"
+  "context"
   "encoding/json"
 
   "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/slack/command"
   . "github.com/confyrm/gorest/errors"
   "github.com/confyrm/gorest/config"
+  "github.com/confyrm/gorest/jobs"
+  "github.com/confyrm/gorest/router/handler"
     "github.com/confyrm/gorest/help"
   . "github.com/confyrm/gorest/servers/slack/commands"
 )
@@ -22,12 +27,16 @@ import (
 
 var commandRouter = SlashCommands.New()
 
-// This is set the first time HelpResponse is called.  It is set by Loading
-// The help text file located in ../../../help/help.hcl
-var helpResponses help.Help
+// helpResponses is read the first time HelpResponse is called, from
+// APP_ROOT/help.hcl, and again by ReloadHelp.
+var (
+  helpMu sync.RWMutex
+  helpResponses help.Help
+)
 
-// SlashRouter is the top level slash command router.
-func SlashRouter(config *config.Config, rw http.ResponseWriter, req *http.Request) error {
+// SlashRouter is the top level slash command router.  It expects the request
+// to have already been authenticated by handler.VerifySlack.
+func SlashRouter(ctx context.Context, config *config.Config, rw http.ResponseWriter, req *http.Request) error {
 
   sReq := &slack.Request{}
 
@@ -38,20 +47,21 @@ func SlashRouter(config *config.Config, rw http.ResponseWriter, req *http.Reques
   sReq.Log()
 
   command, err := sReq.TextToCommand()
-  if err != nil {
+  if perr, ok := err.(*slack.ParseError); ok {
+    // Show the user where their command went wrong.
+    log.Printf("[%s] Could not parse command text: %s", handler.RequestID(ctx), perr.Error())
+    rw.Header().Set("Content-Type", "application/json; charset=UTF-8")
+    return json.NewEncoder(rw).Encode(ErrorResponse(perr))
+  } else if err != nil {
     return StatusError{http.StatusInternalServerError, err}
   }
-  log.Printf("Received Slack slash command: %#v", command)
+  log.Printf("[%s] Received Slack slash command: %#v", handler.RequestID(ctx), command)
 
-  // First authZ test.  Ensure the token provided in the slack request
-  // matches the SLACK_TOKEN
-  if sReq.Token != config.GetString("SLACK_TOKEN") {
-    return StatusError{http.StatusUnauthorized,
-      errors.New("Not authorized. Wrong Slack Token.")}
-  }
+  // Get the route handler for this slash command, such as '/devhub'
+  route := commandRouter.Route(sReq.Command)
 
 	// Look to see if we just need to return some help
-	yes, err := HadHelp(config, command, rw)
+	yes, err := HadHelp(config, route, command, rw)
 	if err != nil {
 		return StatusError{http.StatusInternalServerError, err}
 	}
@@ -60,8 +70,6 @@ func SlashRouter(config *config.Config, rw http.ResponseWriter, req *http.Reques
 		return nil
 	}
 
-  // Get the route handler for this slash command, such as '/devhub'
-  route := commandRouter.Route(sReq.Command)
   if route == nil {
     // Oops!  No route found.  Must be an unknown command
     return StatusError {
@@ -70,20 +78,41 @@ func SlashRouter(config *config.Config, rw http.ResponseWriter, req *http.Reques
     }
   }
 
+  if route.Schema != nil {
+    if _, err := route.Schema.Validate(command); err != nil {
+      // Tell the user everything that was wrong with the command.
+      log.Printf("[%s] Invalid command: %s", handler.RequestID(ctx), err.Error())
+      rw.Header().Set("Content-Type", "application/json; charset=UTF-8")
+      return json.NewEncoder(rw).Encode(ErrorResponse(err))
+    }
+  }
+
   // At this point, we can either process the command and return a
   // slack.Response, or, we can kick off a goroutine, and return a
   // quick, happy response.  The long running command can send its
   //  response as a POST request to the response_url listed in the sReq.
   var response *slack.Response
   if route.IsLong {
-    // Long running command. Kick off a goroutine and return a happy response.
-    go route.Handler(config, sReq, command)
-    // Create a quick response to let the user know the comand is running
-    response = HappyResponse(command)
+    // Long running command. Queue a job and return a happy response.
+    // If the pool is full, let the user know to try again later.
+    if jobs.Default == nil {
+      return StatusError{http.StatusServiceUnavailable, errors.New("Job pool is not running")}
+    }
+    id, err := jobs.Default.Submit(ctx, CommandJob(config, route, sReq, command))
+    switch err {
+    case nil:
+      log.Printf("[%s] Queued job %s", handler.RequestID(ctx), id)
+      // Create a quick response to let the user know the comand is running
+      response = HappyResponse(command)
+    case jobs.ErrBusy, jobs.ErrStopped:
+      response = BusyResponse(command)
+    default:
+      return StatusError{http.StatusInternalServerError, err}
+    }
   } else {
     // Short short command.  Just run and return the response.
     var statusErr error
-    response, statusErr = route.Handler(config, sReq, command)
+    response, statusErr = route.Handler(ctx, config, sReq, command)
     if statusErr != nil {
       return statusErr
     }
@@ -100,11 +129,49 @@ func SlashRouter(config *config.Config, rw http.ResponseWriter, req *http.Reques
 
 }
 
-func HadHelp(config *config.Config, command *slack.DevHubCommand, rw http.ResponseWriter) (bool, error) {
+// CommandJob wraps a long running command handler as a jobs.Job.  Any
+// response the handler returns is posted to the response_url.  If the
+// handler panics, the user is told via the response_url.  The handler gets
+// a copy of cmd, since dispatching it pops subcommands off while the caller
+// may still be reading it.
+func CommandJob(config *config.Config, route *command.Command, sReq *slack.Request, cmd *slack.DevHubCommand) jobs.Job {
+  name := sReq.Command
+  if len(cmd.Commands) > 0 {
+    name = fmt.Sprintf("%s %s", sReq.Command, cmd.Commands[0])
+  }
+  cmd = cmd.Copy()
+  return jobs.Job{
+    Name: name,
+    User: sReq.UserName,
+    Run: func(ctx context.Context) error {
+      response, statusErr := route.Handler(ctx, config, sReq, cmd)
+      if statusErr != nil {
+        return statusErr
+      }
+      if response != nil {
+        return sReq.RespondWithContext(ctx, response)
+      }
+      return nil
+    },
+    Recover: func(ctx context.Context, err error) {
+      RespondWithError(ctx, sReq, fmt.Errorf("Your %s request crashed: %s", name, err.Error()))
+    },
+  }
+}
 
-	if helpPath, ok := command.HelpPath(); ok {
+// HadHelp sends a help response if the command asked for help.  route may
+// be nil.
+func HadHelp(config *config.Config, route *command.Command, command *slack.DevHubCommand, rw http.ResponseWriter) (bool, error) {
+
+	if helpPath, ok := command.HelpPath(); ok && !runsBare(route, command) {
     rw.Header().Set("Content-Type", "application/json; charset=UTF-8")
-    response := HelpResponse(config, helpPath)
+    var response *slack.Response
+    if route != nil && route.Schema != nil {
+      text := route.Schema.Help(helpPath)
+      response = &slack.Response{Type: slack.Ephemeral.String(), Text: &text}
+    } else {
+      response = HelpResponse(config, helpPath)
+    }
     if err := json.NewEncoder(rw).Encode(response); err != nil {
       return true, StatusError{http.StatusInternalServerError, err}
     }
@@ -115,6 +182,26 @@ func HadHelp(config *config.Config, command *slack.DevHubCommand, rw http.Respon
 	return false, nil
 }
 
+// runsBare is true if cmd is a single subcommand with no params that can
+// run as it is, such as /devhub new or /devhub login: it has a Handler, and
+// none of its params are required.  Groups, such as /devhub issue, and
+// subcommands that need a param, such as /devhub get, show their help.
+func runsBare(route *command.Command, cmd *slack.DevHubCommand) bool {
+  if route == nil || route.Schema == nil || len(cmd.Commands) != 1 || len(cmd.Params) > 0 {
+    return false
+  }
+  sub := route.Schema.Subcommand(cmd.Commands[0])
+  if sub == nil || sub.Handler == nil {
+    return false
+  }
+  for _, p := range sub.Params {
+    if p.Required {
+      return false
+    }
+  }
+  return true
+}
+
 // HappyResponse just sends back a "message received" response.  This is
 // Sent when the command is long running, to let the user know that it's running
 func HappyResponse(command *slack.DevHubCommand) *slack.Response {
@@ -125,7 +212,20 @@ func HappyResponse(command *slack.DevHubCommand) *slack.Response {
     cmdText = ""
   }
   text := fmt.Sprintf("Roger that!  Message received!\r\nYour %s request is in process!", cmdText)
-  response := slack.Response{slack.Ephemeral.String(), &text, nil}
+  response := slack.Response{Type: slack.Ephemeral.String(), Text: &text}
+  return &response
+}
+
+// BusyResponse is sent instead of HappyResponse when the job queue is full.
+func BusyResponse(command *slack.DevHubCommand) *slack.Response {
+  var cmdText string
+  if len(command.Commands) > 0 {
+    cmdText = command.Commands[0]
+  } else {
+    cmdText = ""
+  }
+  text := fmt.Sprintf("Whoa, I'm a little busy right now!\r\nPlease try your %s request again in a minute.", cmdText)
+  response := slack.Response{Type: slack.Ephemeral.String(), Text: &text}
   return &response
 }
 
@@ -135,35 +235,52 @@ func HelpResponse(config *config.Config, commands slack.Commands) *slack.Respons
 
   // Although called every time, this will only attempted to read the help
   // text the first time its called.
-  ImportHelpText(config)
+  responses := ImportHelpText(config)
 
 	cLen := len(commands)
   var text string
   // If the only command is help, then return the top level help
   if (cLen == 0) {
-    text = helpResponses.Base()
+    text = responses.Base()
   } else {
     // Create the help lookup key, by joining all the commands
     key := strings.Join(commands, help.Sep)
-    text = helpResponses.Get(key)
+    text = responses.Get(key)
   }
 
-  response := slack.Response{slack.Ephemeral.String(), &text, nil}
+  response := slack.Response{Type: slack.Ephemeral.String(), Text: &text}
   return &response
 }
 
 // ImportHelpText attempts to read an HCL formated file located in
-// APP_ROOT/help/help.hcl.
-func ImportHelpText(config *config.Config) {
-  if helpResponses != nil {
-    return
+// APP_ROOT/help/help.hcl, if it has not been read yet.
+func ImportHelpText(config *config.Config) help.Help {
+  helpMu.RLock()
+  loaded := helpResponses
+  helpMu.RUnlock()
+  if loaded != nil {
+    return loaded
   }
+  ReloadHelp(config)
+  helpMu.RLock()
+  defer helpMu.RUnlock()
+  return helpResponses
+}
 
-  helpPath := filepath.Join(config.GetString("APP_ROOT"), "help.hcl" )
+// ReloadHelp reads the help text again, from the APP_ROOT in config.  It is
+// subscribed to config reloads by servers/slack, so a new APP_ROOT, or an
+// edited help file, is picked up.  If the file can not be parsed, the help
+// text already loaded is kept.
+func ReloadHelp(config *config.Config) error {
+  helpPath := filepath.Join(config.App().Root, "help.hcl")
   log.Printf("Reading help from %s", helpPath)
-  var err error
-  helpResponses, err = help.ParseHelpFile(helpPath)
+  parsed, err := help.ParseHelpFile(helpPath)
   if err != nil {
-    log.Printf("Error parsing help file: ", err.Error())
+    log.Printf("Error parsing help file: %s", err.Error())
+    return nil
   }
+  helpMu.Lock()
+  defer helpMu.Unlock()
+  helpResponses = parsed
+  return nil
 }
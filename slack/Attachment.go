@@ -20,6 +20,33 @@ type AttachmentField struct {
 // Helper type for a slice of AttachmentFields.
 type AttachmentFields []AttachmentField
 
+// Slack button styles for attachment actions.
+const PRIMARY = "primary"
+
+// Confirmation is a dialog shown before an action is sent.
+type Confirmation struct {
+  Title string `json:"title,omitempty"`
+  Text string `json:"text"`
+  OkText string `json:"ok_text,omitempty"`
+  DismissText string `json:"dismiss_text,omitempty"`
+}
+
+// AttachmentAction is a button on an attachment.  When clicked, Slack posts
+// an interactive_message payload to the /interactive route, and the Name
+// is used as the action key.
+type AttachmentAction struct {
+  Name string `json:"name"`
+  Text string `json:"text"`
+  // Always "button" for now.
+  Type string `json:"type"`
+  Value string `json:"value,omitempty"`
+  // GOOD, PRIMARY or DANGER
+  Style string `json:"style,omitempty"`
+  Confirm *Confirmation `json:"confirm,omitempty"`
+}
+// Helper type for a slice of AttachmentActions.
+type AttachmentActions []AttachmentAction
+
 // Attachment is the primary tool for creating rich responses.
 type Attachment struct {
   Title string `json:"title"`
@@ -47,6 +74,10 @@ type Attachment struct {
   // Table fields
   Fields AttachmentFields `json:"fields, omitempty"`
   MarkdownIn []string `json:"mrkdwn_in, omitempty"`
+
+  // Action fields.  CallbackId is required if there are any Actions.
+  CallbackId string `json:"callback_id,omitempty"`
+  Actions AttachmentActions `json:"actions,omitempty"`
 }
 
 // Helper type for a slick of Attachments.
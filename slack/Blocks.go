@@ -0,0 +1,520 @@
+package slack
+
+import (
+  "fmt"
+  "reflect"
+  "unicode/utf8"
+)
+
+// Text object types.
+const (
+  PlainText = "plain_text"
+  Markdown = "mrkdwn"
+)
+
+// Slack Block Kit size limits.  See https://api.slack.com/reference/block-kit
+const (
+  MaxBlocks = 50
+  MaxBlockIdLength = 255
+  MaxActionIdLength = 255
+  MaxSectionTextLength = 3000
+  MaxSectionFields = 10
+  MaxSectionFieldLength = 2000
+  MaxHeaderTextLength = 150
+  MaxContextElements = 10
+  MaxActionsElements = 25
+  MaxButtonTextLength = 75
+  MaxButtonValueLength = 2000
+  MaxUrlLength = 3000
+  MaxAltTextLength = 2000
+  MaxImageTitleLength = 2000
+)
+
+// TextObject is a plain_text or mrkdwn text object.
+type TextObject struct {
+  Type string `json:"type"`
+  Text string `json:"text"`
+  // Only for plain_text.
+  Emoji bool `json:"emoji,omitempty"`
+  // Only for mrkdwn.
+  Verbatim bool `json:"verbatim,omitempty"`
+}
+
+// NewPlainText returns a plain_text object.
+func NewPlainText(text string) *TextObject {
+  return &TextObject{Type: PlainText, Text: text, Emoji: true}
+}
+
+// NewMarkdown returns a mrkdwn text object.
+func NewMarkdown(text string) *TextObject {
+  return &TextObject{Type: Markdown, Text: text}
+}
+
+func (t *TextObject) validate(what string, max int) error {
+  if t == nil {
+    return nil
+  }
+  if t.Type != PlainText && t.Type != Markdown {
+    return fmt.Errorf("%s has bad text type [%s]", what, t.Type)
+  }
+  return checkLength(what, t.Text, max)
+}
+
+// Element is anything that can be placed in a context or actions block, or
+// used as a section accessory.
+type Element interface {
+  ElementType() string
+  Validate() error
+}
+
+// ButtonElement is an interactive button.  Clicks are sent to the
+// /interactive route as block_actions, keyed by ActionId.
+type ButtonElement struct {
+  Type string `json:"type"`
+  Text *TextObject `json:"text"`
+  ActionId string `json:"action_id"`
+  Value string `json:"value,omitempty"`
+  Url string `json:"url,omitempty"`
+  // PRIMARY or DANGER
+  Style string `json:"style,omitempty"`
+  Confirm *ConfirmationDialog `json:"confirm,omitempty"`
+}
+
+// NewButton returns a button element.
+func NewButton(actionId string, text string, value string) *ButtonElement {
+  return &ButtonElement{Type: "button", Text: NewPlainText(text), ActionId: actionId, Value: value}
+}
+
+func (e *ButtonElement) ElementType() string {
+  return e.Type
+}
+
+func (e *ButtonElement) Validate() error {
+  if e.Text == nil {
+    return fmt.Errorf("button %s has no text", e.ActionId)
+  }
+  if e.Text.Type != PlainText {
+    return fmt.Errorf("button %s text must be plain_text", e.ActionId)
+  }
+  if e.Style != "" && e.Style != PRIMARY && e.Style != DANGER {
+    return fmt.Errorf("button %s has bad style [%s]", e.ActionId, e.Style)
+  }
+  return firstError(
+    e.Text.validate("button text", MaxButtonTextLength),
+    checkLength("button action_id", e.ActionId, MaxActionIdLength),
+    checkLength("button value", e.Value, MaxButtonValueLength),
+    checkLength("button url", e.Url, MaxUrlLength),
+    e.Confirm.validate(),
+  )
+}
+
+// ConfirmationDialog is shown before a button action is sent.
+type ConfirmationDialog struct {
+  Title *TextObject `json:"title"`
+  Text *TextObject `json:"text"`
+  Confirm *TextObject `json:"confirm"`
+  Deny *TextObject `json:"deny"`
+  // PRIMARY or DANGER
+  Style string `json:"style,omitempty"`
+}
+
+// NewConfirmationDialog returns a confirmation dialog.
+func NewConfirmationDialog(title string, text string, confirm string, deny string) *ConfirmationDialog {
+  return &ConfirmationDialog{
+    Title: NewPlainText(title),
+    Text: NewMarkdown(text),
+    Confirm: NewPlainText(confirm),
+    Deny: NewPlainText(deny),
+  }
+}
+
+func (c *ConfirmationDialog) validate() error {
+  if c == nil {
+    return nil
+  }
+  return firstError(
+    c.Title.validate("confirm title", 100),
+    c.Text.validate("confirm text", 300),
+    c.Confirm.validate("confirm button", 30),
+    c.Deny.validate("deny button", 30),
+  )
+}
+
+// ImageElement is an image in a context block or section accessory.
+type ImageElement struct {
+  Type string `json:"type"`
+  ImageUrl string `json:"image_url"`
+  AltText string `json:"alt_text"`
+}
+
+// NewImageElement returns an image element.
+func NewImageElement(imageUrl string, altText string) *ImageElement {
+  return &ImageElement{Type: "image", ImageUrl: imageUrl, AltText: altText}
+}
+
+func (e *ImageElement) ElementType() string {
+  return e.Type
+}
+
+func (e *ImageElement) Validate() error {
+  return firstError(
+    required("image image_url", e.ImageUrl),
+    required("image alt_text", e.AltText),
+    checkLength("image image_url", e.ImageUrl, MaxUrlLength),
+    checkLength("image alt_text", e.AltText, MaxAltTextLength),
+  )
+}
+
+// TextObjects can be used directly as context block elements.
+func (t *TextObject) ElementType() string {
+  return t.Type
+}
+
+func (t *TextObject) Validate() error {
+  return t.validate("text", MaxSectionTextLength)
+}
+
+// Block is a single Block Kit layout block.
+type Block interface {
+  BlockType() string
+  Validate() error
+}
+
+// Helper type for a slice of Blocks.
+type Blocks []Block
+
+// Validate checks every block against Slack's limits.  Slack rejects the
+// whole message if any block is invalid, so it is better to find out first.
+// A nil block would be sent as null, so it is an error too.
+func (blocks Blocks) Validate() error {
+  if len(blocks) > MaxBlocks {
+    return fmt.Errorf("Too many blocks: %d.  Slack allows %d", len(blocks), MaxBlocks)
+  }
+  for i, b := range blocks {
+    if isNil(b) {
+      return fmt.Errorf("Block %d is nil", i)
+    }
+    if err := b.Validate(); err != nil {
+      return fmt.Errorf("Block %d (%s): %s", i, b.BlockType(), err.Error())
+    }
+  }
+  return nil
+}
+
+// isNil is true for a nil Block, or a nil pointer to one.
+func isNil(b Block) bool {
+  if b == nil {
+    return true
+  }
+  v := reflect.ValueOf(b)
+  return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// SectionBlock is text, with optional fields and an accessory.
+type SectionBlock struct {
+  Type string `json:"type"`
+  BlockId string `json:"block_id,omitempty"`
+  Text *TextObject `json:"text,omitempty"`
+  Fields []*TextObject `json:"fields,omitempty"`
+  Accessory Element `json:"accessory,omitempty"`
+}
+
+// NewSection returns a section with mrkdwn text.
+func NewSection(text string) *SectionBlock {
+  return &SectionBlock{Type: "section", Text: NewMarkdown(text)}
+}
+
+// AddField adds a mrkdwn field.  Fields are shown two to a row.
+func (b *SectionBlock) AddField(text string) *SectionBlock {
+  b.Fields = append(b.Fields, NewMarkdown(text))
+  return b
+}
+
+func (b *SectionBlock) BlockType() string {
+  return b.Type
+}
+
+func (b *SectionBlock) Validate() error {
+  if b.Text == nil && len(b.Fields) == 0 {
+    return fmt.Errorf("section needs text or fields")
+  }
+  if len(b.Fields) > MaxSectionFields {
+    return fmt.Errorf("section has %d fields.  Slack allows %d", len(b.Fields), MaxSectionFields)
+  }
+  for _, f := range b.Fields {
+    if err := f.validate("section field", MaxSectionFieldLength); err != nil {
+      return err
+    }
+  }
+  if b.Accessory != nil {
+    if err := b.Accessory.Validate(); err != nil {
+      return err
+    }
+  }
+  return firstError(
+    checkLength("block_id", b.BlockId, MaxBlockIdLength),
+    b.Text.validate("section text", MaxSectionTextLength),
+  )
+}
+
+// DividerBlock is a horizontal rule.
+type DividerBlock struct {
+  Type string `json:"type"`
+  BlockId string `json:"block_id,omitempty"`
+}
+
+// NewDivider returns a divider.
+func NewDivider() *DividerBlock {
+  return &DividerBlock{Type: "divider"}
+}
+
+func (b *DividerBlock) BlockType() string {
+  return b.Type
+}
+
+func (b *DividerBlock) Validate() error {
+  return checkLength("block_id", b.BlockId, MaxBlockIdLength)
+}
+
+// HeaderBlock is large, bold plain text.
+type HeaderBlock struct {
+  Type string `json:"type"`
+  BlockId string `json:"block_id,omitempty"`
+  Text *TextObject `json:"text"`
+}
+
+// NewHeader returns a header.
+func NewHeader(text string) *HeaderBlock {
+  return &HeaderBlock{Type: "header", Text: NewPlainText(text)}
+}
+
+func (b *HeaderBlock) BlockType() string {
+  return b.Type
+}
+
+func (b *HeaderBlock) Validate() error {
+  if b.Text == nil || b.Text.Type != PlainText {
+    return fmt.Errorf("header text must be plain_text")
+  }
+  return firstError(
+    checkLength("block_id", b.BlockId, MaxBlockIdLength),
+    b.Text.validate("header text", MaxHeaderTextLength),
+  )
+}
+
+// ContextBlock is small text and images.
+type ContextBlock struct {
+  Type string `json:"type"`
+  BlockId string `json:"block_id,omitempty"`
+  Elements []Element `json:"elements"`
+}
+
+// NewContext returns a context with the given elements.  Only text objects
+// and image elements are allowed.
+func NewContext(elements ...Element) *ContextBlock {
+  return &ContextBlock{Type: "context", Elements: elements}
+}
+
+func (b *ContextBlock) BlockType() string {
+  return b.Type
+}
+
+func (b *ContextBlock) Validate() error {
+  if len(b.Elements) == 0 {
+    return fmt.Errorf("context needs at least one element")
+  }
+  if len(b.Elements) > MaxContextElements {
+    return fmt.Errorf("context has %d elements.  Slack allows %d", len(b.Elements), MaxContextElements)
+  }
+  for _, e := range b.Elements {
+    switch e.(type) {
+    case *TextObject, *ImageElement:
+    default:
+      return fmt.Errorf("context cannot contain a %s element", e.ElementType())
+    }
+    if err := e.Validate(); err != nil {
+      return err
+    }
+  }
+  return checkLength("block_id", b.BlockId, MaxBlockIdLength)
+}
+
+// ActionsBlock holds interactive elements.
+type ActionsBlock struct {
+  Type string `json:"type"`
+  BlockId string `json:"block_id,omitempty"`
+  Elements []Element `json:"elements"`
+}
+
+// NewActions returns an actions block with the given elements.
+func NewActions(blockId string, elements ...Element) *ActionsBlock {
+  return &ActionsBlock{Type: "actions", BlockId: blockId, Elements: elements}
+}
+
+func (b *ActionsBlock) BlockType() string {
+  return b.Type
+}
+
+func (b *ActionsBlock) Validate() error {
+  if len(b.Elements) == 0 {
+    return fmt.Errorf("actions needs at least one element")
+  }
+  if len(b.Elements) > MaxActionsElements {
+    return fmt.Errorf("actions has %d elements.  Slack allows %d", len(b.Elements), MaxActionsElements)
+  }
+  for _, e := range b.Elements {
+    if err := e.Validate(); err != nil {
+      return err
+    }
+  }
+  return checkLength("block_id", b.BlockId, MaxBlockIdLength)
+}
+
+// ImageBlock is a full width image.
+type ImageBlock struct {
+  Type string `json:"type"`
+  BlockId string `json:"block_id,omitempty"`
+  ImageUrl string `json:"image_url"`
+  AltText string `json:"alt_text"`
+  Title *TextObject `json:"title,omitempty"`
+}
+
+// NewImage returns an image block.
+func NewImage(imageUrl string, altText string) *ImageBlock {
+  return &ImageBlock{Type: "image", ImageUrl: imageUrl, AltText: altText}
+}
+
+func (b *ImageBlock) BlockType() string {
+  return b.Type
+}
+
+func (b *ImageBlock) Validate() error {
+  if b.Title != nil && b.Title.Type != PlainText {
+    return fmt.Errorf("image title must be plain_text")
+  }
+  return firstError(
+    checkLength("block_id", b.BlockId, MaxBlockIdLength),
+    required("image image_url", b.ImageUrl),
+    required("image alt_text", b.AltText),
+    checkLength("image image_url", b.ImageUrl, MaxUrlLength),
+    checkLength("image alt_text", b.AltText, MaxAltTextLength),
+    b.Title.validate("image title", MaxImageTitleLength),
+  )
+}
+
+// RichTextElement is a leaf of a rich text section: text, link, user,
+// channel or emoji.
+type RichTextElement struct {
+  Type string `json:"type"`
+  Text string `json:"text,omitempty"`
+  Url string `json:"url,omitempty"`
+  UserId string `json:"user_id,omitempty"`
+  ChannelId string `json:"channel_id,omitempty"`
+  Name string `json:"name,omitempty"`
+  Style *RichTextStyle `json:"style,omitempty"`
+}
+
+// RichTextStyle is the styling of a rich text leaf.
+type RichTextStyle struct {
+  Bold bool `json:"bold,omitempty"`
+  Italic bool `json:"italic,omitempty"`
+  Strike bool `json:"strike,omitempty"`
+  Code bool `json:"code,omitempty"`
+}
+
+// RichTextSection is a run of rich text elements.  Type is one of
+// rich_text_section, rich_text_preformatted or rich_text_quote.
+type RichTextSection struct {
+  Type string `json:"type"`
+  Elements []RichTextElement `json:"elements"`
+}
+
+// RichTextBlock is formatted text.
+type RichTextBlock struct {
+  Type string `json:"type"`
+  BlockId string `json:"block_id,omitempty"`
+  Elements []RichTextSection `json:"elements"`
+}
+
+// NewRichText returns a rich text block with the given sections.
+func NewRichText(sections ...RichTextSection) *RichTextBlock {
+  return &RichTextBlock{Type: "rich_text", Elements: sections}
+}
+
+// NewRichTextSection returns a rich_text_section.
+func NewRichTextSection(elements ...RichTextElement) RichTextSection {
+  return RichTextSection{Type: "rich_text_section", Elements: elements}
+}
+
+// RichText returns a text leaf.
+func RichText(text string, style *RichTextStyle) RichTextElement {
+  return RichTextElement{Type: "text", Text: text, Style: style}
+}
+
+// RichTextLink returns a link leaf.
+func RichTextLink(url string, text string) RichTextElement {
+  return RichTextElement{Type: "link", Url: url, Text: text}
+}
+
+// RichTextUser returns a user mention leaf.
+func RichTextUser(userId string) RichTextElement {
+  return RichTextElement{Type: "user", UserId: userId}
+}
+
+func (b *RichTextBlock) BlockType() string {
+  return b.Type
+}
+
+func (b *RichTextBlock) Validate() error {
+  if len(b.Elements) == 0 {
+    return fmt.Errorf("rich_text needs at least one element")
+  }
+  for _, section := range b.Elements {
+    switch section.Type {
+    case "rich_text_section", "rich_text_preformatted", "rich_text_quote":
+    default:
+      return fmt.Errorf("rich_text has bad element type [%s]", section.Type)
+    }
+    for _, e := range section.Elements {
+      switch e.Type {
+      case "text", "link", "user", "channel", "emoji":
+      default:
+        return fmt.Errorf("rich_text has bad leaf type [%s]", e.Type)
+      }
+    }
+  }
+  return checkLength("block_id", b.BlockId, MaxBlockIdLength)
+}
+
+// Truncate shortens s to at most max characters, ending with an ellipsis
+// if anything was cut.  Use it to keep user text within the limits above.
+func Truncate(s string, max int) string {
+  if utf8.RuneCountInString(s) <= max {
+    return s
+  }
+  runes := []rune(s)
+  return string(runes[:max-1]) + "…"
+}
+
+func checkLength(what string, s string, max int) error {
+  if n := utf8.RuneCountInString(s); n > max {
+    return fmt.Errorf("%s is %d characters.  Slack allows %d", what, n, max)
+  }
+  return nil
+}
+
+func required(what string, s string) error {
+  if s == "" {
+    return fmt.Errorf("%s is required", what)
+  }
+  return nil
+}
+
+func firstError(errs ...error) error {
+  for _, err := range errs {
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
@@ -1,9 +1,8 @@
 package slack
 
 import (
-  "fmt"
+  "sort"
   "strings"
-  "errors"
 
   "github.com/spf13/cast"
 )
@@ -27,7 +26,7 @@ func (cPtr *Commands) Pop() (string, bool) {
     return "", false
   }
   res:=(*cPtr)[0]
-  *cPtr=(*cPtr)[1:len(*cPtr)-1]
+  *cPtr=(*cPtr)[1:]
   return res, true
 }
 
@@ -134,6 +133,16 @@ func (command *DevHubCommand) HelpPath() (Commands, bool) {
   return Commands{}, false
 }
 
+// Copy returns a copy of the command that can be changed, such as by Pop,
+// without changing the original.
+func (command *DevHubCommand) Copy() *DevHubCommand {
+  params := make(KVPairs, len(command.Params))
+  for key, value := range command.Params {
+    params[key] = value
+  }
+  return &DevHubCommand{append(Commands{}, command.Commands...), params}
+}
+
 func (command *DevHubCommand) Pop() (string, bool) {
   return command.Commands.Pop()
 }
@@ -203,141 +212,68 @@ func (command *DevHubCommand) HasValue(key string) bool {
 }
 
 
+// Text turns the command back into command text that TextToCommand will
+// parse into the same command.  Keys are sorted, and values are quoted.
+func (command *DevHubCommand) Text() string {
+  words := make([]string, 0, len(command.Commands) + len(command.Params))
+  for _, cmd := range command.Commands {
+    words = append(words, quote(cmd))
+  }
+  keys := make([]string, 0, len(command.Params))
+  for key := range command.Params {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  for _, key := range keys {
+    words = append(words, key + "=" + quote(command.Params[key]))
+  }
+  return strings.Join(words, " ")
+}
+
+// quote double quotes s, if it has anything in it the lexer would treat
+// specially.
+func quote(s string) string {
+  if s != "" && !strings.ContainsAny(s, " \t\r\n\"'“\\=:-") {
+    return s
+  }
+  s = strings.Replace(s, `\`, `\\`, -1)
+  s = strings.Replace(s, `"`, `\"`, -1)
+  return `"` + s + `"`
+}
 
 const TRIM_CUTSET = " \r\n"
-const KV_DELIM = "="
-
 
+// TextToCommand parses the slash command text into commands and key value
+// pairs.  See Lex for the syntax.  Errors are *ParseError, which carry the
+// position of the problem, so they can be shown to the user.
 func (sReq *Request) TextToCommand() (*DevHubCommand, error) {
-  // Trim the string first, to remove any unwanted spaces and new lines
-  t := strings.Trim(sReq.Text, TRIM_CUTSET)
-
-  // Get the set of commands, and whatever text may be remaining after the commands
-  commands, kvText := ParseCommands(t)
-  kv, err := ParseKeyValuePairs(kvText)
+  parsed, err := parseText(sReq.Text)
   if err != nil {
     return nil, err
   }
-
-  return &DevHubCommand{commands, kv}, nil
+  return &DevHubCommand{parsed.Commands, parsed.Params}, nil
 }
 
 // ParseCommands is a helper function that parses out any commands that are
-// placed before the Key/Value pairs in the provided text.
+// placed before the Key/Value pairs in the provided text, and returns the
+// text starting at the first key.
 // Note, if there are no commands AND no KV pairs, ParseCommands will return
-// an empty string for kvText.
+// an empty string for kvText.  Parse errors are ignored.  Use TextToCommand
+// to get them.
 func ParseCommands(text string) (Commands, string) {
-  // Grab everything up to the first key
-  var cmdText string
-  var kvText string
-  commands := make([]string, 0, 10)
-  firstKey := FindStartOfNextKey(text)
-
-  if firstKey == 0 {
-    // There are KV's but no commands
-    return commands, text
-  } else if firstKey > 0 {
-    cmdText = text[:firstKey-1]
-    kvText = text[firstKey:]
-  } else {
-    // There doesn't appear to be any kv pairs.
-    cmdText = text
-    kvText = ""
-  }
-  // Read the list of commands first
-  tmp := strings.Fields(cmdText)
-  for i := 0; i < len(tmp); i++ {
-    tmp[i] = strings.Trim(tmp[i], TRIM_CUTSET)
+  parsed, _ := parseText(text)
+  switch {
+  case parsed.KeyStart < 0:
+    return parsed.Commands, ""
+  case len(parsed.Commands) == 0:
+    return parsed.Commands, text
   }
-  for _, x := range tmp {
-    if len(x) > 0 {
-      commands = append(commands, x)
-    }
-  }
-  return commands, kvText
+  return parsed.Commands, string([]rune(text)[parsed.KeyStart:])
 }
 
+// ParseKeyValuePairs returns just the key value pairs in text.  Any leading
+// commands are skipped.
 func ParseKeyValuePairs(kvText string) (KVPairs, error) {
-  //Split on KV_DELIM.  This will yield an even number of values in s, where
-  // s[i] = key, s[i+1] = value
-  kv := make(KVPairs)
-
-  for x := 0; x < 10; x++ { // Just to prevent infinite loop!
-    i := strings.Index(kvText, KV_DELIM)
-
-    if i == -1 {
-      break
-    }
-
-    k := strings.ToLower(strings.Trim(kvText[:i], TRIM_CUTSET))
-    //fmt.Printf(" - k: %s\n", k)
-    kvText = strings.Trim(kvText[i + 1:], TRIM_CUTSET)
-
-    if len(kvText) == 0 {
-      err := errors.New(fmt.Sprintf("Error parsing Key Value pairs: No remainder for key: %s ", k ))
-      return kv, err
-    }
-
-    j := FindStartOfNextKey(kvText)
-    if j == -1 {
-      // Last value in k/v pairs
-      v := strings.Trim(kvText, TRIM_CUTSET)
-      kv[k] = v
-      //fmt.Printf(" - v: [%s]\n", v)
-      break
-    }
-
-
-    // j now sits at letter of the next key.
-    v := strings.ToLower(strings.Trim(kvText[:j - 1], TRIM_CUTSET))
-    //fmt.Printf(" - v: [%s]\n", v)
-    kv[k] = v
-    kvText = strings.Trim(kvText[j:], TRIM_CUTSET)
-  }
-  return kv, nil
-}
-
-
-func FindStartOfNextKey(text string) int {
-  t := strings.TrimLeft(text, TRIM_CUTSET)
-
-  if len(t) == 0 {
-    // No text to test
-    return -1
-  }
-  i := strings.Index(t, KV_DELIM)
-
-  if i == -1 {
-    // No keys in the text
-    return i
-  }
-
-  if i == 0 {
-    // No keys, because text starts with '='
-    return -1
-  }
-
-  if i == len(t) - 1 {
-    // No keys, because text ends with '='
-    return -1
-  }
-
-  // skip over any white space
-  for i--; i > 0; i-- {
-    if t[i] != ' ' && t[i] != '\n' && t[i] != '\r'  && t[i] != '\f' {
-      break
-    }
-  }
-
-  // Now skip the key
-  for {
-    if i == 0 {
-      break
-    }
-    if t[i - 1] == ' ' {
-      break
-    }
-    i--
-  }
-  return i
+  parsed, err := parseText(kvText)
+  return parsed.Params, err
 }
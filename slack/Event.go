@@ -0,0 +1,85 @@
+package slack
+
+import (
+  "fmt"
+  "errors"
+  "encoding/json"
+)
+
+// Events API envelope types.
+const (
+  // Sent once, when the request URL is saved in the Slack app config.
+  URLVerification = "url_verification"
+  // Every subscribed event arrives wrapped in an event_callback.
+  EventCallback = "event_callback"
+)
+
+// RetryNumHeader is set when Slack redelivers an event it thinks failed.
+const RetryNumHeader = "X-Slack-Retry-Num"
+// RetryReasonHeader says why Slack is redelivering.
+const RetryReasonHeader = "X-Slack-Retry-Reason"
+
+// EventEnvelope is the outer JSON body of an Events API request.
+type EventEnvelope struct {
+  // The legacy verification token.  See handler.VerifySlack.
+  Token string `json:"token"`
+  Type string `json:"type"`
+  // Only for url_verification.
+  Challenge string `json:"challenge"`
+
+  TeamId string `json:"team_id"`
+  ApiAppId string `json:"api_app_id"`
+  // Unique across retries.  Used to drop duplicate deliveries.
+  EventId string `json:"event_id"`
+  EventTime int64 `json:"event_time"`
+  Event Event `json:"event"`
+}
+
+// SharedLink is a link in a link_shared event.
+type SharedLink struct {
+  Domain string `json:"domain"`
+  Url string `json:"url"`
+}
+
+// Event is the inner event.  Only the common fields are decoded.  Handlers
+// that need more can decode Raw themselves.
+type Event struct {
+  Type string `json:"type"`
+  SubType string `json:"subtype"`
+  User string `json:"user"`
+  BotId string `json:"bot_id"`
+  Channel string `json:"channel"`
+  Text string `json:"text"`
+  Ts string `json:"ts"`
+  ThreadTs string `json:"thread_ts"`
+  EventTs string `json:"event_ts"`
+
+  // link_shared
+  MessageTs string `json:"message_ts"`
+  Links []SharedLink `json:"links"`
+
+  Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps a copy of the raw event.
+func (e *Event) UnmarshalJSON(data []byte) error {
+  type event Event
+  var ev event
+  if err := json.Unmarshal(data, &ev); err != nil {
+    return err
+  }
+  *e = Event(ev)
+  e.Raw = append(json.RawMessage{}, data...)
+  return nil
+}
+
+// Decode unmarshals an Events API body.
+func (env *EventEnvelope) Decode(body []byte) error {
+  if err := json.Unmarshal(body, env); err != nil {
+    return fmt.Errorf("Unrecognized event payload: %s", err.Error())
+  }
+  if env.Type == "" {
+    return errors.New("Event payload has no type")
+  }
+  return nil
+}
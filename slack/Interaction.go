@@ -0,0 +1,190 @@
+package slack
+
+import (
+  "fmt"
+  "errors"
+  "net/http"
+  "encoding/json"
+)
+
+// Interaction payload types.  Slack posts these to the interactivity
+// request URL as a form with a single "payload" field holding JSON.
+const (
+  // Buttons and menus in Block Kit messages.
+  BlockActions = "block_actions"
+  // Buttons and menus in legacy attachments.
+  InteractiveMessage = "interactive_message"
+  // A modal was submitted.
+  ViewSubmission = "view_submission"
+  // A modal was closed.
+  ViewClosed = "view_closed"
+  // A global shortcut was used.
+  Shortcut = "shortcut"
+  // A message shortcut was used.
+  MessageAction = "message_action"
+)
+
+// InteractionTeam is the Slack team the interaction came from.
+type InteractionTeam struct {
+  Id string `json:"id"`
+  Domain string `json:"domain"`
+}
+
+// InteractionUser is the Slack user that interacted.
+type InteractionUser struct {
+  Id string `json:"id"`
+  // Sent for block_actions and views.
+  UserName string `json:"username"`
+  // Sent for legacy interactive_message.
+  Name string `json:"name"`
+  TeamId string `json:"team_id"`
+}
+
+// InteractionChannel is where the interaction happened, if in a channel.
+type InteractionChannel struct {
+  Id string `json:"id"`
+  Name string `json:"name"`
+}
+
+// OptionValue is a selected menu option.
+type OptionValue struct {
+  Value string `json:"value"`
+}
+
+// Action is a single button click or menu selection.
+type Action struct {
+  // Block Kit
+  ActionId string `json:"action_id"`
+  BlockId string `json:"block_id"`
+  // Legacy attachments
+  Name string `json:"name"`
+
+  Type string `json:"type"`
+  Value string `json:"value"`
+  SelectedOption *OptionValue `json:"selected_option,omitempty"`
+  // Legacy attachment menus
+  SelectedOptions []OptionValue `json:"selected_options,omitempty"`
+  ActionTs string `json:"action_ts"`
+}
+
+// Key is what the action is routed on.  Block Kit actions use action_id.
+// Legacy attachment actions only have a name.
+func (a *Action) Key() string {
+  if a.ActionId != "" {
+    return a.ActionId
+  }
+  return a.Name
+}
+
+// SelectedValue returns the value of a button, or the selected menu option.
+func (a *Action) SelectedValue() string {
+  if a.SelectedOption != nil {
+    return a.SelectedOption.Value
+  }
+  if len(a.SelectedOptions) > 0 {
+    return a.SelectedOptions[0].Value
+  }
+  return a.Value
+}
+
+// Helper type for a slice of Actions
+type Actions []Action
+
+// ViewStateValue is the value of a single input in a submitted view.
+type ViewStateValue struct {
+  Type string `json:"type"`
+  Value string `json:"value"`
+  SelectedOption *OptionValue `json:"selected_option,omitempty"`
+  SelectedOptions []OptionValue `json:"selected_options,omitempty"`
+  SelectedUser string `json:"selected_user,omitempty"`
+}
+
+// View is the part of a modal that is sent back on view_submission.  The
+// state values are keyed by block_id, then action_id.
+type View struct {
+  Id string `json:"id"`
+  Type string `json:"type"`
+  CallbackId string `json:"callback_id"`
+  PrivateMetadata string `json:"private_metadata"`
+  State struct {
+    Values map[string]map[string]ViewStateValue `json:"values"`
+  } `json:"state"`
+}
+
+// Interaction is the decoded "payload" of an interactive request.
+type Interaction struct {
+  Type string `json:"type"`
+  // The legacy verification token.  See handler.VerifySlack.
+  Token string `json:"token"`
+  Team InteractionTeam `json:"team"`
+  User InteractionUser `json:"user"`
+  Channel *InteractionChannel `json:"channel,omitempty"`
+  // Shortcuts, views and legacy attachments have a callback_id.
+  CallbackId string `json:"callback_id"`
+  TriggerId string `json:"trigger_id"`
+  ResponseUrl string `json:"response_url"`
+  ActionTs string `json:"action_ts"`
+  MessageTs string `json:"message_ts"`
+  Actions Actions `json:"actions"`
+  View *View `json:"view,omitempty"`
+}
+
+// DecodeHttp reads the "payload" form field of req into the Interaction.
+func (i *Interaction) DecodeHttp(req *http.Request) error {
+  if req == nil {
+    return errors.New("req is nil")
+  }
+  if err := req.ParseForm(); err != nil {
+    return fmt.Errorf("Could not parse form data: %s", err.Error())
+  }
+  payload := req.PostForm.Get("payload")
+  if payload == "" {
+    return errors.New("No interaction payload found")
+  }
+  return i.Decode([]byte(payload))
+}
+
+// Decode unmarshals the JSON payload.
+func (i *Interaction) Decode(payload []byte) error {
+  if err := json.Unmarshal(payload, i); err != nil {
+    return fmt.Errorf("Unrecognized interaction payload: %s", err.Error())
+  }
+  if i.Type == "" {
+    return errors.New("Interaction payload has no type")
+  }
+  return nil
+}
+
+// UserName returns whichever user name Slack sent.
+func (i *Interaction) UserName() string {
+  if i.User.UserName != "" {
+    return i.User.UserName
+  }
+  return i.User.Name
+}
+
+// ChannelId returns the channel id, or "" if not in a channel.
+func (i *Interaction) ChannelId() string {
+  if i.Channel == nil {
+    return ""
+  }
+  return i.Channel.Id
+}
+
+// Request returns a Request that has enough of the interaction in it to use
+// Request.Respond and the command helpers.
+func (i *Interaction) Request() *Request {
+  r := &Request{
+    Token: i.Token,
+    TeamId: i.Team.Id,
+    TeamDomain: i.Team.Domain,
+    UserId: i.User.Id,
+    UserName: i.UserName(),
+    ResponseUrl: i.ResponseUrl,
+  }
+  if i.Channel != nil {
+    r.ChannelId = i.Channel.Id
+    r.ChannelName = i.Channel.Name
+  }
+  return r
+}
@@ -0,0 +1,167 @@
+package slack
+
+import (
+  "fmt"
+  "strings"
+  "testing"
+  "encoding/json"
+
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+var blockData = []struct {
+  Name string
+  Blocks Blocks
+  Valid bool
+} {
+  {
+    "a section",
+    Blocks{NewSection("Hello")},
+    true,
+  },
+  {
+    "a section with no text or fields",
+    Blocks{&SectionBlock{Type: "section"}},
+    false,
+  },
+  {
+    "a section that is too long",
+    Blocks{NewSection(strings.Repeat("x", MaxSectionTextLength + 1))},
+    false,
+  },
+  {
+    "a truncated section",
+    Blocks{NewSection(Truncate(strings.Repeat("x", MaxSectionTextLength + 1), MaxSectionTextLength))},
+    true,
+  },
+  {
+    "a header that is too long",
+    Blocks{NewHeader(strings.Repeat("x", MaxHeaderTextLength + 1))},
+    false,
+  },
+  {
+    "a header with mrkdwn text",
+    Blocks{&HeaderBlock{Type: "header", Text: NewMarkdown("*bold*")}},
+    false,
+  },
+  {
+    "a divider, context, actions, image and rich text",
+    Blocks{
+      NewDivider(),
+      NewContext(NewMarkdown("small"), NewImageElement("http://example.com/x.png", "x")),
+      NewActions("issue", NewButton("issue_close", "Close", "a/b#1")),
+      NewImage("http://example.com/x.png", "x"),
+      NewRichText(NewRichTextSection(RichText("hi ", nil), RichTextUser("U123"))),
+    },
+    true,
+  },
+  {
+    "a context with a button",
+    Blocks{NewContext(NewButton("a", "b", "c"))},
+    false,
+  },
+  {
+    "a button with a long label",
+    Blocks{NewActions("", NewButton("a", strings.Repeat("x", MaxButtonTextLength + 1), "c"))},
+    false,
+  },
+  {
+    "an image with no alt text",
+    Blocks{NewImage("http://example.com/x.png", "")},
+    false,
+  },
+  {
+    "too many blocks",
+    make(Blocks, MaxBlocks + 1),
+    false,
+  },
+  {
+    "a nil block",
+    Blocks{NewSection("Hello"), nil},
+    false,
+  },
+  {
+    "a nil section",
+    Blocks{(*SectionBlock)(nil)},
+    false,
+  },
+  {
+    "text and select inputs",
+    Blocks{
+      NewInput("title", "Title", NewTextInput("title", false)),
+      NewInput("labels", "Labels", NewSelect("labels", "Choose", true, NewOption("bug", "bug"))),
+    },
+    true,
+  },
+  {
+    "an input with a button",
+    Blocks{NewInput("a", "b", NewButton("a", "b", "c"))},
+    false,
+  },
+  {
+    "a select with no options",
+    Blocks{NewInput("m", "Milestone", NewSelect("m", "Choose", false))},
+    false,
+  },
+}
+
+func TestBlocksValidate(t *testing.T) {
+  for _, d := range blockData {
+    err := d.Blocks.Validate()
+    Convey(fmt.Sprintf("Given %s", d.Name), t, func() {
+      Convey(fmt.Sprintf("Valid should be %v", d.Valid), func() {
+        So(err == nil, ShouldEqual, d.Valid)
+      })
+    })
+  }
+}
+
+func TestBlocksJSON(t *testing.T) {
+  Convey("Given a response with a section and a button", t, func() {
+    text := "fallback"
+    response := Response{
+      Type: Ephemeral.String(),
+      Text: &text,
+      Blocks: Blocks{
+        NewSection("Hello"),
+        NewActions("issue", NewButton("issue_close", "Close", "a/b#1")),
+      },
+    }
+    out, err := json.Marshal(response)
+    So(err, ShouldBeNil)
+    Convey("The JSON should be in Slack's shape", func() {
+      So(string(out), ShouldEqual, `{"response_type":"ephemeral","text":"fallback","blocks":[`+
+        `{"type":"section","text":{"type":"mrkdwn","text":"Hello"}},`+
+        `{"type":"actions","block_id":"issue","elements":[{"type":"button","text":{"type":"plain_text","text":"Close","emoji":true},"action_id":"issue_close","value":"a/b#1"}]}]}`)
+    })
+  })
+}
+
+func TestModal(t *testing.T) {
+  Convey("Given a modal with a title that is too long", t, func() {
+    modal := NewModal("new_issue", strings.Repeat("x", MaxModalTitleLength + 1), "Create",
+      NewInput("title", "Title", NewTextInput("title", false)))
+    Convey("The title should be truncated, and the modal valid", func() {
+      So(len([]rune(modal.Title.Text)), ShouldEqual, MaxModalTitleLength)
+      So(modal.Validate(), ShouldBeNil)
+    })
+  })
+
+  Convey("Given a submitted view", t, func() {
+    interaction := &Interaction{}
+    err := interaction.Decode([]byte(`{"type":"view_submission","view":{"callback_id":"new_issue",
+      "private_metadata":"meta","state":{"values":{
+      "title":{"title":{"type":"plain_text_input","value":"Broken"}},
+      "labels":{"labels":{"type":"multi_static_select","selected_options":[{"value":"bug"},{"value":"ui"}]}},
+      "milestone":{"milestone":{"type":"static_select","selected_option":{"value":"3"}}}}}}}`))
+    So(err, ShouldBeNil)
+    view := interaction.View
+    Convey("The values should be found by block and action id", func() {
+      So(view.Text("title", "title"), ShouldEqual, "Broken")
+      So(view.Selected("labels", "labels"), ShouldResemble, []string{"bug", "ui"})
+      So(view.Selected("milestone", "milestone"), ShouldResemble, []string{"3"})
+      So(view.Selected("assignee", "assignee"), ShouldBeEmpty)
+      So(view.Text("body", "body"), ShouldEqual, "")
+    })
+  })
+}
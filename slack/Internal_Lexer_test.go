@@ -0,0 +1,100 @@
+package slack
+
+import (
+  "fmt"
+  "testing"
+
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+var parseData = []struct {
+  Text string
+  Command DevHubCommand
+} {
+  {
+    `new title="a = b" labels=bug`,
+    DevHubCommand{Commands{"new"}, KVPairs{"title": "a = b", "labels": "bug"}},
+  },
+  {
+    `new title="say \"hi\"" body=“smart quotes” repo='a\b'`,
+    DevHubCommand{Commands{"new"}, KVPairs{"title": `say "hi"`, "body": "smart quotes", "repo": `a\b`}},
+  },
+  {
+    `new title=don't panic`,
+    DevHubCommand{Commands{"new"}, KVPairs{"title": "don't panic"}},
+  },
+  {
+    `new title=a\=b`,
+    DevHubCommand{Commands{"new"}, KVPairs{"title": "a=b"}},
+  },
+  {
+    `get 12 repo:gorest owner:confyrm`,
+    DevHubCommand{Commands{"get", "12"}, KVPairs{"repo": "gorest", "owner": "confyrm"}},
+  },
+  {
+    `new --title my title --assignee=steve --draft`,
+    DevHubCommand{Commands{"new"}, KVPairs{"title": "my title", "assignee": "steve", "draft": "true"}},
+  },
+  {
+    `new labels=bug labels=ui`,
+    DevHubCommand{Commands{"new"}, KVPairs{"labels": "bug,ui"}},
+  },
+  {
+    `new title=See https://example.com/a:b note: this`,
+    DevHubCommand{Commands{"new"}, KVPairs{"title": "See https://example.com/a:b note: this"}},
+  },
+  {
+    "new title=one body=line 1\nline 2",
+    DevHubCommand{Commands{"new"}, KVPairs{"title": "one", "body": "line 1\nline 2"}},
+  },
+  {
+    `new a=1 b=2 c=3 d=4 e=5 f=6 g=7 h=8 i=9 j=10 k=11 l=12`,
+    DevHubCommand{Commands{"new"}, KVPairs{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5", "f": "6",
+      "g": "7", "h": "8", "i": "9", "j": "10", "k": "11", "l": "12"}},
+  },
+}
+
+func TestTextToCommand(t *testing.T) {
+  for _, d := range parseData {
+    command, err := (&Request{Text: d.Text}).TextToCommand()
+    Convey(fmt.Sprintf("Given the input [%v]", d.Text), t, func() {
+      Convey("Err should be nil", func() {
+        So(err, ShouldBeNil)
+      })
+      Convey(fmt.Sprintf("The command should be [%v]", d.Command), func() {
+        So(command, ShouldResemble, &d.Command)
+      })
+    })
+  }
+}
+
+var parseErrorData = []struct {
+  Text string
+  Line int
+  Column int
+} {
+  {`new title="no end`, 1, 11},
+  {`new title=`, 1, 5},
+  {`new title=a \`, 1, 13},
+  {`=value`, 1, 1},
+  {`new title==x`, 1, 11},
+  {`new -- x`, 1, 5},
+  {`new "quoted"=x`, 1, 5},
+  {"new title=one\nbody=\"two", 2, 6},
+}
+
+func TestTextToCommandErrors(t *testing.T) {
+  for _, d := range parseErrorData {
+    _, err := (&Request{Text: d.Text}).TextToCommand()
+    Convey(fmt.Sprintf("Given the input [%v]", d.Text), t, func() {
+      Convey("Err should be a ParseError", func() {
+        So(err, ShouldHaveSameTypeAs, &ParseError{})
+      })
+      Convey(fmt.Sprintf("The error should be at line %d, column %d", d.Line, d.Column), func() {
+        line, col := err.(*ParseError).LineAndColumn()
+        So(line, ShouldEqual, d.Line)
+        So(col, ShouldEqual, d.Column)
+      })
+    })
+  }
+}
@@ -242,6 +242,56 @@ func TestParseKeyValuePairs(t *testing.T) {
   }
 }
 
+func TestCommandsPop(t *testing.T) {
+  Convey("Given a stack of commands", t, func() {
+    commands := Commands{"issue", "label", "add"}
+    Convey("Pop should return each command in turn, and leave the rest", func() {
+      first, _ := commands.Pop()
+      So(first, ShouldEqual, "issue")
+      So(commands, ShouldResemble, Commands{"label", "add"})
+      commands.Pop()
+      last, ok := commands.Pop()
+      So(last, ShouldEqual, "add")
+      So(ok, ShouldBeTrue)
+      _, ok = commands.Pop()
+      So(ok, ShouldBeFalse)
+    })
+  })
+}
+
+func TestCommandCopy(t *testing.T) {
+  Convey("Given a copy of a command", t, func() {
+    command := &DevHubCommand{Commands{"issue", "get"}, KVPairs{"number": "1"}}
+    copied := command.Copy()
+    Convey("Changing the copy should not change the original", func() {
+      copied.Pop()
+      copied.Params["number"] = "2"
+      So(command.Commands, ShouldResemble, Commands{"issue", "get"})
+      So(command.Params["number"], ShouldEqual, "1")
+      So(copied.Commands, ShouldResemble, Commands{"get"})
+    })
+  })
+}
+
+func TestCommandText(t *testing.T) {
+  commands := []DevHubCommand {
+    DevHubCommand{Commands{"list"}, KVPairs{}},
+    DevHubCommand{Commands{"issue", "search"}, KVPairs{"text": "login page", "page": "2"}},
+    DevHubCommand{Commands{"search"}, KVPairs{"text": `say "a = b:c" \ -x`, "labels": "bug,ui"}},
+    DevHubCommand{Commands{"list"}, KVPairs{"milestone": "", "assignee": "--steve"}},
+  }
+  for _, command := range commands {
+    text := command.Text()
+    Convey(fmt.Sprintf("Given the command %v, and its text [%s]", command, text), t, func() {
+      parsed, err := (&Request{Text: text}).TextToCommand()
+      Convey("The text should parse back into the same command", func() {
+        So(err, ShouldBeNil)
+        So(*parsed, ShouldResemble, command)
+      })
+    })
+  }
+}
+
 /*
 func TestParseKeyValuePairs(t *testing.T) {
   for name, rq := range requestCommands {
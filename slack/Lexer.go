@@ -0,0 +1,363 @@
+package slack
+
+import (
+  "fmt"
+  "strings"
+  "unicode"
+)
+
+// The command text lexer.  Slash command text looks like:
+//     new title="a = b" labels:bug,ui --assignee steve
+// Leading words are commands.  After the first key, every word up to the
+// next key is part of that key's value.  Keys can be given as key=value,
+// key:value or --key value.  A --key with no value is set to "true".
+//
+// Quotes (", ' or the “ ” pair Slack clients like to substitute) only
+// start a quoted string at the beginning of a word, so an apostrophe in
+// "don't" is just an apostrophe.  A backslash escapes the next character,
+// except inside single quotes.
+
+// TokenType identifies the kind of Token.
+type TokenType int
+
+const (
+  // A word, possibly quoted.
+  WordToken TokenType = iota
+  // '=' or ':' between a key and its value.
+  AssignToken
+  // --name
+  FlagToken
+)
+
+func (t TokenType) String() string {
+  switch t {
+  case WordToken:
+    return "word"
+  case AssignToken:
+    return "assignment"
+  case FlagToken:
+    return "flag"
+  }
+  return "unknown"
+}
+
+// Token is a lexed piece of command text.
+type Token struct {
+  Type TokenType
+  // The unquoted, unescaped text.  For a FlagToken, the name without "--".
+  Text string
+  // True if any part of the word was quoted or escaped.  Quoted words are
+  // never treated as keys.
+  Quoted bool
+  // Start and End are rune offsets into the lexed text.
+  Start int
+  End int
+}
+
+// ParseError is a command text error, with the position it was found at.
+type ParseError struct {
+  // The full command text.
+  Text string
+  // Rune offset into Text.
+  Pos int
+  Msg string
+}
+
+// LineAndColumn returns the 1 based line and column of the error.
+func (e *ParseError) LineAndColumn() (int, int) {
+  line, col := 1, 1
+  for i, r := range []rune(e.Text) {
+    if i == e.Pos {
+      break
+    }
+    if r == '\n' {
+      line++
+      col = 1
+    } else {
+      col++
+    }
+  }
+  return line, col
+}
+
+func (e *ParseError) Error() string {
+  line, col := e.LineAndColumn()
+  if strings.ContainsRune(e.Text, '\n') {
+    return fmt.Sprintf("%s at line %d, column %d", e.Msg, line, col)
+  }
+  return fmt.Sprintf("%s at column %d", e.Msg, col)
+}
+
+// Pointer returns the offending line with a caret under the error, for
+// showing in a Slack code block.
+func (e *ParseError) Pointer() string {
+  line, col := e.LineAndColumn()
+  text := strings.Split(e.Text, "\n")[line - 1]
+  return fmt.Sprintf("%s\n%s^", text, strings.Repeat(" ", col - 1))
+}
+
+type lexer struct {
+  text string
+  runes []rune
+  pos int
+  tokens []Token
+}
+
+// Lex splits text into Tokens.  On error, the tokens found before the error
+// are returned along with a *ParseError.
+func Lex(text string) ([]Token, error) {
+  l := &lexer{text: text, runes: []rune(text)}
+  for {
+    l.skipSpace()
+    if l.pos >= len(l.runes) {
+      return l.tokens, nil
+    }
+    var err error
+    switch r := l.runes[l.pos]; {
+    case r == '=':
+      l.emit(AssignToken, "=", false, l.pos, l.pos + 1)
+      l.pos++
+    case r == '-' && l.peek(1) == '-':
+      err = l.lexFlag()
+    default:
+      err = l.lexWord()
+    }
+    if err != nil {
+      return l.tokens, err
+    }
+  }
+}
+
+func (l *lexer) errorf(pos int, format string, args ...interface{}) *ParseError {
+  return &ParseError{Text: l.text, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) emit(t TokenType, text string, quoted bool, start int, end int) {
+  l.tokens = append(l.tokens, Token{Type: t, Text: text, Quoted: quoted, Start: start, End: end})
+}
+
+func (l *lexer) peek(n int) rune {
+  if l.pos + n >= len(l.runes) {
+    return 0
+  }
+  return l.runes[l.pos + n]
+}
+
+func (l *lexer) skipSpace() {
+  for l.pos < len(l.runes) && unicode.IsSpace(l.runes[l.pos]) {
+    l.pos++
+  }
+}
+
+// lexFlag reads --name.  A following '=' is left for the parser.
+func (l *lexer) lexFlag() error {
+  start := l.pos
+  l.pos += 2
+  nameStart := l.pos
+  for l.pos < len(l.runes) && isKeyRune(l.runes[l.pos]) {
+    l.pos++
+  }
+  if l.pos == nameStart {
+    return l.errorf(start, "Missing flag name after '--'")
+  }
+  if l.pos < len(l.runes) && !unicode.IsSpace(l.runes[l.pos]) && l.runes[l.pos] != '=' {
+    return l.errorf(l.pos, "Unexpected %q in flag name", l.runes[l.pos])
+  }
+  l.emit(FlagToken, strings.ToLower(string(l.runes[nameStart:l.pos])), false, start, l.pos)
+  return nil
+}
+
+// lexWord reads a word, which ends at whitespace or an unquoted '='.  A
+// word that looks like key:value is split into the key, an AssignToken,
+// and the value.
+func (l *lexer) lexWord() error {
+  start := l.pos
+  var b strings.Builder
+  quoted := false
+  for l.pos < len(l.runes) {
+    r := l.runes[l.pos]
+    switch {
+    case unicode.IsSpace(r) || r == '=':
+      l.emit(WordToken, b.String(), quoted, start, l.pos)
+      return nil
+    case r == '\\':
+      if l.pos + 1 >= len(l.runes) {
+        return l.errorf(l.pos, "Nothing to escape after '\\'")
+      }
+      b.WriteRune(l.runes[l.pos + 1])
+      quoted = true
+      l.pos += 2
+    case l.pos == start && isOpenQuote(r):
+      if err := l.lexQuoted(&b); err != nil {
+        return err
+      }
+      quoted = true
+    case r == ':' && !quoted && isKey(b.String()) && l.isValueStart(l.pos + 1):
+      l.emit(WordToken, b.String(), false, start, l.pos)
+      l.emit(AssignToken, ":", false, l.pos, l.pos + 1)
+      l.pos++
+      return nil
+    default:
+      b.WriteRune(r)
+      l.pos++
+    }
+  }
+  l.emit(WordToken, b.String(), quoted, start, l.pos)
+  return nil
+}
+
+// lexQuoted reads a quoted string into b, starting at the open quote.
+func (l *lexer) lexQuoted(b *strings.Builder) error {
+  start := l.pos
+  open := l.runes[l.pos]
+  end := closeQuote(open)
+  l.pos++
+  for l.pos < len(l.runes) {
+    r := l.runes[l.pos]
+    switch {
+    case r == end:
+      l.pos++
+      return nil
+    case r == '\\' && open != '\'' && l.pos + 1 < len(l.runes):
+      b.WriteRune(l.runes[l.pos + 1])
+      l.pos += 2
+    default:
+      b.WriteRune(r)
+      l.pos++
+    }
+  }
+  return l.errorf(start, "Unterminated quote")
+}
+
+// isValueStart is true if the rune at i can start a key:value value.  This
+// keeps urls, "note: text" and trailing colons from being read as keys.
+func (l *lexer) isValueStart(i int) bool {
+  if i >= len(l.runes) {
+    return false
+  }
+  r := l.runes[i]
+  return !unicode.IsSpace(r) && r != '/' && r != ':'
+}
+
+func isOpenQuote(r rune) bool {
+  return r == '"' || r == '\'' || r == '“'
+}
+
+func closeQuote(open rune) rune {
+  if open == '“' {
+    return '”'
+  }
+  return open
+}
+
+func isKeyRune(r rune) bool {
+  return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+// isKey is true if s can be used as a key.  Keys start with a letter.
+func isKey(s string) bool {
+  for i, r := range s {
+    if i == 0 && !unicode.IsLetter(r) {
+      return false
+    }
+    if !isKeyRune(r) {
+      return false
+    }
+  }
+  return s != ""
+}
+
+// parsedText is the result of parsing command text.
+type parsedText struct {
+  Commands Commands
+  Params KVPairs
+  // Rune offset of the first key, or -1 if there are no keys.
+  KeyStart int
+}
+
+// parseText parses command text into commands and key value pairs.
+// Repeated keys are joined with a comma, so labels=bug labels=ui is the
+// same as labels=bug,ui.  Unquoted values that span several words keep
+// the whitespace that was between them.
+func parseText(text string) (*parsedText, error) {
+  p := &parsedText{Commands: Commands{}, Params: KVPairs{}, KeyStart: -1}
+  tokens, err := Lex(text)
+  if err != nil {
+    // Parse what we can, so callers that ignore the error still get
+    // something sensible, but report the lexing error.
+    p.parse(text, tokens)
+    return p, err
+  }
+  return p, p.parse(text, tokens)
+}
+
+func (p *parsedText) parse(text string, tokens []Token) error {
+  runes := []rune(text)
+  errorf := func(pos int, format string, args ...interface{}) error {
+    return &ParseError{Text: text, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+  }
+  // isKeyAt is true if tokens[i] starts a key.
+  isKeyAt := func(i int) bool {
+    if tokens[i].Type == FlagToken {
+      return true
+    }
+    return tokens[i].Type == WordToken && i + 1 < len(tokens) && tokens[i + 1].Type == AssignToken
+  }
+
+  i := 0
+  // Commands
+  for ; i < len(tokens) && !isKeyAt(i); i++ {
+    if tokens[i].Type == AssignToken {
+      return errorf(tokens[i].Start, "Missing key before '%s'", tokens[i].Text)
+    }
+    p.Commands = append(p.Commands, tokens[i].Text)
+  }
+  if i < len(tokens) {
+    p.KeyStart = tokens[i].Start
+  }
+
+  // Key value pairs
+  for i < len(tokens) {
+    keyToken := tokens[i]
+    key := strings.ToLower(keyToken.Text)
+    i++
+    assigned := true
+    if keyToken.Type == WordToken {
+      if keyToken.Quoted || !isKey(keyToken.Text) {
+        return errorf(keyToken.Start, "Bad key %q", keyToken.Text)
+      }
+      // Skip the AssignToken.
+      i++
+    } else if i < len(tokens) && tokens[i].Type == AssignToken && tokens[i].Text == "=" {
+      // --key=value
+      i++
+    } else {
+      assigned = false
+    }
+
+    var value strings.Builder
+    first := i
+    for ; i < len(tokens) && !isKeyAt(i); i++ {
+      if tokens[i].Type == AssignToken {
+        return errorf(tokens[i].Start, "Unexpected '%s'.  Quote values that contain it", tokens[i].Text)
+      }
+      if i > first {
+        value.WriteString(string(runes[tokens[i - 1].End:tokens[i].Start]))
+      }
+      value.WriteString(tokens[i].Text)
+    }
+    if i == first {
+      if assigned {
+        return errorf(keyToken.Start, "No value for key %q", key)
+      }
+      value.WriteString("true")
+    }
+
+    if prev, ok := p.Params[key]; ok {
+      p.Params[key] = prev + "," + value.String()
+    } else {
+      p.Params[key] = value.String()
+    }
+  }
+  return nil
+}
@@ -7,6 +7,7 @@ import (
   "net/http"
 
   "github.com/gorilla/schema"
+  "github.com/confyrm/gorest/config"
 )
 /*
 2016/07/07 23:08:41
@@ -46,9 +47,19 @@ type Request struct {
   // If the time to respond to the command is long, the response can be
   // sent to this url, instead ofo in the initial response.
   ResponseUrl string `schema:"response_url"`
+  // Lets the command open a modal with views.open.  It expires after 3
+  // seconds.
+  TriggerId string `schema:"trigger_id"`
 }
 
-var decoder = schema.NewDecoder()
+// Slack adds fields, such as api_app_id, that Request does not have.
+var decoder = newDecoder()
+
+func newDecoder() *schema.Decoder {
+  d := schema.NewDecoder()
+  d.IgnoreUnknownKeys(true)
+  return d
+}
 
 // Decode does a JSON decode of the provided map.  This is
 // Generally passed the url.Values from a http.Request.
@@ -79,7 +90,13 @@ func (r *Request) DecodeMap(src map[string][]string) error {
 }
 
 
+// Log logs the request.  The token is a secret, so config.Redacted is logged
+// in its place.
 func (r *Request) Log() {
-  log.Printf("token: [%s] team_id: [%s] team_domain: [%s] channel_id: [%s] channel_name: [%s] user_id: [%s] user_name: [%s] command: [%s] text: [%s] response_url: [%s]",
-    r.Token, r.TeamId, r.TeamDomain, r.ChannelId, r.ChannelName, r.UserId, r.UserName, r.Command, r.Text, r.ResponseUrl)
+  token := "[]"
+  if r.Token != "" {
+    token = config.Redacted
+  }
+  log.Printf("token: %s team_id: [%s] team_domain: [%s] channel_id: [%s] channel_name: [%s] user_id: [%s] user_name: [%s] command: [%s] text: [%s] response_url: [%s]",
+    token, r.TeamId, r.TeamDomain, r.ChannelId, r.ChannelName, r.UserId, r.UserName, r.Command, r.Text, r.ResponseUrl)
 }
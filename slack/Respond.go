@@ -2,6 +2,7 @@ package slack
 
 import (
   "log"
+  "context"
   "bytes"
   "io/ioutil"
   "net/http"
@@ -14,10 +15,19 @@ import (
 // URL provided in the slack.Request It's just a wrapper around
 // http.Request
 func (sReq *Request) Respond(sResp *Response) error {
+  return sReq.RespondWithContext(context.Background(), sResp)
+}
+
+// RespondWithContext is Respond, but the POST is abandoned if ctx is
+// cancelled or its deadline passes.
+func (sReq *Request) RespondWithContext(ctx context.Context, sResp *Response) error {
 
   if sReq.ResponseUrl == "" {
     return errors.New("No ResponseUrl in Request")
   }
+  if err := sResp.Validate(); err != nil {
+    return fmt.Errorf("Invalid response: %s", err.Error())
+  }
 
   buffer := new(bytes.Buffer)
   json.NewEncoder(buffer).Encode(*sResp)
@@ -26,8 +36,12 @@ func (sReq *Request) Respond(sResp *Response) error {
       DisableKeepAlives: true,
   }
   client := &http.Client{Transport: tr}
-  resp, err := client.Post(sReq.ResponseUrl,
-    "application/json; charset=utf-8", buffer)
+  req, err := http.NewRequest("POST", sReq.ResponseUrl, buffer)
+  if err != nil {
+    return fmt.Errorf("Error creating response: %s", err.Error())
+  }
+  req.Header.Set("Content-Type", "application/json; charset=utf-8")
+  resp, err := client.Do(req.WithContext(ctx))
 
   if err != nil {
     // Error sending the post
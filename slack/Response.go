@@ -13,15 +13,34 @@ const (
 )
 var ResponseTypes = []string {
   "ephemeral",
-  "in_hannel",
+  "in_channel",
 }
 func (r ResponseType) String() string {
   return ResponseTypes[r - 1]
 }
 
+// MaxTextLength is the longest message text Slack accepts.
+const MaxTextLength = 40000
+
 // The Response must be json encoded.  Response data must be URL encoded, also.
+// If Blocks are set, Slack displays them, and uses Text for notifications.
+// Attachments are shown below the blocks, so use one or the other.
 type Response struct {
   Type string `json:"response_type"`
   Text *string `json:"text"`
   Attachments Attachments `json:"attachments,omitempty"`
+  Blocks Blocks `json:"blocks,omitempty"`
+  // Only for responses to interactions.  Replaces the message that holds
+  // the button, instead of posting a new one.
+  ReplaceOriginal bool `json:"replace_original,omitempty"`
+}
+
+// Validate checks the Response against Slack's size limits.
+func (r *Response) Validate() error {
+  if r.Text != nil {
+    if err := checkLength("text", *r.Text, MaxTextLength); err != nil {
+      return err
+    }
+  }
+  return r.Blocks.Validate()
 }
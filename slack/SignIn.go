@@ -0,0 +1,51 @@
+package slack
+
+import (
+  "errors"
+  "context"
+  "net/url"
+  "golang.org/x/oauth2"
+)
+
+// SignInAuthorizeUrl is where Sign in with Slack starts.  It is on
+// slack.com, not the Web API, so it is not moved by a fake BaseUrl.
+const SignInAuthorizeUrl = "https://slack.com/openid/connect/authorize"
+
+// SignInConfig returns the config for Sign in with Slack, which is Slack's
+// OpenID Connect flow.  The code is traded for a token at apiUrl, which may
+// be empty for the real Slack.
+func SignInConfig(clientId string, clientSecret string, redirectUrl string, apiUrl string) *oauth2.Config {
+  if apiUrl == "" {
+    apiUrl = DefaultApiUrl
+  }
+  return &oauth2.Config{
+    ClientID: clientId,
+    ClientSecret: clientSecret,
+    RedirectURL: redirectUrl,
+    Scopes: []string{"openid"},
+    Endpoint: oauth2.Endpoint{
+      AuthURL: SignInAuthorizeUrl,
+      TokenURL: apiUrl + "openid.connect.token",
+      AuthStyle: oauth2.AuthStyleInParams,
+    },
+  }
+}
+
+// SignedInUser is the part of openid.connect.userInfo we use.
+type SignedInUser struct {
+  UserId string `json:"https://slack.com/user_id"`
+  TeamId string `json:"https://slack.com/team_id"`
+}
+
+// OpenIDUserInfo returns the user that signed in.  wc's Token must be the
+// user token from SignInConfig, not the bot token.
+func (wc *WebClient) OpenIDUserInfo(ctx context.Context) (*SignedInUser, error) {
+  var user SignedInUser
+  if err := wc.CallForm(ctx, "openid.connect.userInfo", url.Values{}, &user); err != nil {
+    return nil, err
+  }
+  if user.UserId == "" {
+    return nil, errors.New("openid.connect.userInfo did not return a user id")
+  }
+  return &user, nil
+}
@@ -0,0 +1,224 @@
+package slack
+
+import (
+  "fmt"
+)
+
+// Slack limits for modals and their inputs.
+const (
+  MaxModalTitleLength = 24
+  MaxModalBlocks = 100
+  MaxPrivateMetadataLength = 3000
+  MaxInputLabelLength = 2000
+  MaxPlaceholderLength = 150
+  MaxSelectOptions = 100
+  MaxOptionTextLength = 75
+  MaxOptionValueLength = 150
+)
+
+// Select element types.
+const (
+  StaticSelect = "static_select"
+  MultiStaticSelect = "multi_static_select"
+)
+
+// InputBlock collects one value in a modal.  The value comes back in the
+// view_submission state, keyed by BlockId, then the element's action_id.
+type InputBlock struct {
+  Type string `json:"type"`
+  BlockId string `json:"block_id,omitempty"`
+  Label *TextObject `json:"label"`
+  Element Element `json:"element"`
+  Hint *TextObject `json:"hint,omitempty"`
+  // Slack requires a value unless Optional is set.
+  Optional bool `json:"optional,omitempty"`
+}
+
+// NewInput returns an input block.
+func NewInput(blockId string, label string, element Element) *InputBlock {
+  return &InputBlock{Type: "input", BlockId: blockId, Label: NewPlainText(label), Element: element}
+}
+
+func (b *InputBlock) BlockType() string {
+  return b.Type
+}
+
+func (b *InputBlock) Validate() error {
+  if b.Label == nil || b.Label.Type != PlainText {
+    return fmt.Errorf("input %s needs a plain_text label", b.BlockId)
+  }
+  if b.Element == nil {
+    return fmt.Errorf("input %s has no element", b.BlockId)
+  }
+  switch b.Element.(type) {
+  case *PlainTextInputElement, *SelectElement:
+  default:
+    return fmt.Errorf("input cannot contain a %s element", b.Element.ElementType())
+  }
+  return firstError(
+    b.Label.validate("input label", MaxInputLabelLength),
+    b.Hint.validate("input hint", MaxInputLabelLength),
+    b.Element.Validate(),
+    checkLength("block_id", b.BlockId, MaxBlockIdLength),
+  )
+}
+
+// PlainTextInputElement is a text box.
+type PlainTextInputElement struct {
+  Type string `json:"type"`
+  ActionId string `json:"action_id"`
+  Placeholder *TextObject `json:"placeholder,omitempty"`
+  InitialValue string `json:"initial_value,omitempty"`
+  Multiline bool `json:"multiline,omitempty"`
+  MaxLength int `json:"max_length,omitempty"`
+}
+
+// NewTextInput returns a text box.
+func NewTextInput(actionId string, multiline bool) *PlainTextInputElement {
+  return &PlainTextInputElement{Type: "plain_text_input", ActionId: actionId, Multiline: multiline}
+}
+
+func (e *PlainTextInputElement) ElementType() string {
+  return e.Type
+}
+
+func (e *PlainTextInputElement) Validate() error {
+  return firstError(
+    required("text input action_id", e.ActionId),
+    checkLength("text input action_id", e.ActionId, MaxActionIdLength),
+    e.Placeholder.validate("text input placeholder", MaxPlaceholderLength),
+  )
+}
+
+// Option is one choice in a select.
+type Option struct {
+  Text *TextObject `json:"text"`
+  Value string `json:"value"`
+}
+
+// NewOption returns an option.  Text that is too long is truncated.
+func NewOption(text string, value string) *Option {
+  return &Option{Text: NewPlainText(Truncate(text, MaxOptionTextLength)), Value: value}
+}
+
+func (o *Option) validate() error {
+  if o.Text == nil || o.Text.Type != PlainText {
+    return fmt.Errorf("option %s needs plain_text text", o.Value)
+  }
+  return firstError(
+    o.Text.validate("option text", MaxOptionTextLength),
+    required("option value", o.Value),
+    checkLength("option value", o.Value, MaxOptionValueLength),
+  )
+}
+
+// SelectElement is a static_select or multi_static_select menu.
+type SelectElement struct {
+  Type string `json:"type"`
+  ActionId string `json:"action_id"`
+  Placeholder *TextObject `json:"placeholder,omitempty"`
+  Options []*Option `json:"options"`
+  // Only for static_select.
+  InitialOption *Option `json:"initial_option,omitempty"`
+  // Only for multi_static_select.
+  InitialOptions []*Option `json:"initial_options,omitempty"`
+}
+
+// NewSelect returns a static_select, or a multi_static_select if multi.
+func NewSelect(actionId string, placeholder string, multi bool, options ...*Option) *SelectElement {
+  t := StaticSelect
+  if multi {
+    t = MultiStaticSelect
+  }
+  return &SelectElement{Type: t, ActionId: actionId, Placeholder: NewPlainText(placeholder), Options: options}
+}
+
+func (e *SelectElement) ElementType() string {
+  return e.Type
+}
+
+func (e *SelectElement) Validate() error {
+  if e.Type != StaticSelect && e.Type != MultiStaticSelect {
+    return fmt.Errorf("select %s has bad type [%s]", e.ActionId, e.Type)
+  }
+  if len(e.Options) == 0 {
+    return fmt.Errorf("select %s has no options", e.ActionId)
+  }
+  if len(e.Options) > MaxSelectOptions {
+    return fmt.Errorf("select %s has %d options.  Slack allows %d", e.ActionId, len(e.Options), MaxSelectOptions)
+  }
+  for _, o := range e.Options {
+    if err := o.validate(); err != nil {
+      return err
+    }
+  }
+  return firstError(
+    required("select action_id", e.ActionId),
+    checkLength("select action_id", e.ActionId, MaxActionIdLength),
+    e.Placeholder.validate("select placeholder", MaxPlaceholderLength),
+  )
+}
+
+// Modal is a view for views.open and views.update.  When it is submitted,
+// the interaction is routed on CallbackId.  See View for what comes back.
+type Modal struct {
+  Type string `json:"type"`
+  CallbackId string `json:"callback_id,omitempty"`
+  Title *TextObject `json:"title"`
+  Submit *TextObject `json:"submit,omitempty"`
+  Close *TextObject `json:"close,omitempty"`
+  Blocks Blocks `json:"blocks"`
+  // Comes back, untouched, in the View.
+  PrivateMetadata string `json:"private_metadata,omitempty"`
+}
+
+// NewModal returns a modal with a submit button.
+func NewModal(callbackId string, title string, submit string, blocks ...Block) *Modal {
+  return &Modal{
+    Type: "modal",
+    CallbackId: callbackId,
+    Title: NewPlainText(Truncate(title, MaxModalTitleLength)),
+    Submit: NewPlainText(submit),
+    Close: NewPlainText("Cancel"),
+    Blocks: blocks,
+  }
+}
+
+// Validate checks the modal against Slack's limits.
+func (m *Modal) Validate() error {
+  if m.Title == nil || m.Title.Type != PlainText {
+    return fmt.Errorf("modal needs a plain_text title")
+  }
+  if len(m.Blocks) > MaxModalBlocks {
+    return fmt.Errorf("Too many blocks: %d.  Slack allows %d in a modal", len(m.Blocks), MaxModalBlocks)
+  }
+  for i, b := range m.Blocks {
+    if err := b.Validate(); err != nil {
+      return fmt.Errorf("Block %d (%s): %s", i, b.BlockType(), err.Error())
+    }
+  }
+  return firstError(
+    m.Title.validate("modal title", MaxModalTitleLength),
+    m.Submit.validate("modal submit", MaxModalTitleLength),
+    m.Close.validate("modal close", MaxModalTitleLength),
+    checkLength("private_metadata", m.PrivateMetadata, MaxPrivateMetadataLength),
+  )
+}
+
+// Text returns the value of a text input in a submitted view.
+func (v *View) Text(blockId string, actionId string) string {
+  return v.State.Values[blockId][actionId].Value
+}
+
+// Selected returns the selected values of a select in a submitted view.
+func (v *View) Selected(blockId string, actionId string) []string {
+  value := v.State.Values[blockId][actionId]
+  values := []string{}
+  if value.SelectedOption != nil {
+    values = append(values, value.SelectedOption.Value)
+  }
+  for _, o := range value.SelectedOptions {
+    values = append(values, o.Value)
+  }
+  return values
+}
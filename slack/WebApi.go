@@ -0,0 +1,222 @@
+package slack
+
+import (
+  "fmt"
+  "io"
+  "bytes"
+  "errors"
+  "context"
+  "net/url"
+  "strings"
+  "net/http"
+  "encoding/json"
+)
+
+// DefaultApiUrl is the base of the Slack Web API.
+const DefaultApiUrl = "https://slack.com/api/"
+
+// WebClient calls Slack Web API methods, such as chat.unfurl, with a bot
+// token.  BaseUrl can be pointed at a fake Slack for testing.
+type WebClient struct {
+  Token string
+  BaseUrl string
+  Client *http.Client
+}
+
+// NewWebClient returns a WebClient for the real Slack API.
+func NewWebClient(token string, baseUrl string) *WebClient {
+  if baseUrl == "" {
+    baseUrl = DefaultApiUrl
+  }
+  return &WebClient{token, baseUrl, http.DefaultClient}
+}
+
+// WebResponse is the part of every Web API response we care about.
+type WebResponse struct {
+  Ok bool `json:"ok"`
+  Error string `json:"error,omitempty"`
+  Warning string `json:"warning,omitempty"`
+}
+
+// Call posts args as JSON to the named method, and decodes the reply into
+// out, if out is not nil.  A reply with "ok": false is returned as an error.
+func (wc *WebClient) Call(ctx context.Context, method string, args interface{}, out interface{}) error {
+  body, err := json.Marshal(args)
+  if err != nil {
+    return fmt.Errorf("Could not encode %s: %s", method, err.Error())
+  }
+  return wc.do(ctx, method, "application/json; charset=utf-8", bytes.NewReader(body), out)
+}
+
+// CallForm is Call for the read methods, such as conversations.replies,
+// which do not accept JSON arguments.
+func (wc *WebClient) CallForm(ctx context.Context, method string, args url.Values, out interface{}) error {
+  return wc.do(ctx, method, "application/x-www-form-urlencoded", strings.NewReader(args.Encode()), out)
+}
+
+func (wc *WebClient) do(ctx context.Context, method string, contentType string, body io.Reader, out interface{}) error {
+  if wc.Token == "" {
+    return errors.New("No Slack bot token configured")
+  }
+  req, err := http.NewRequest("POST", wc.BaseUrl + method, body)
+  if err != nil {
+    return err
+  }
+  req.Header.Set("Content-Type", contentType)
+  req.Header.Set("Authorization", "Bearer " + wc.Token)
+
+  resp, err := wc.Client.Do(req.WithContext(ctx))
+  if err != nil {
+    return fmt.Errorf("Error calling %s: %s", method, err.Error())
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    return fmt.Errorf("%s returned HTTP %d", method, resp.StatusCode)
+  }
+
+  var raw json.RawMessage
+  if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+    return fmt.Errorf("Could not decode %s response: %s", method, err.Error())
+  }
+  var result WebResponse
+  if err := json.Unmarshal(raw, &result); err != nil {
+    return fmt.Errorf("Could not decode %s response: %s", method, err.Error())
+  }
+  if !result.Ok {
+    return fmt.Errorf("%s failed: %s", method, result.Error)
+  }
+  if out != nil {
+    return json.Unmarshal(raw, out)
+  }
+  return nil
+}
+
+// Unfurls maps each shared url to the attachment to show for it.
+type Unfurls map[string]Attachment
+
+// ChatUnfurl attaches previews to the links in a message.
+func (wc *WebClient) ChatUnfurl(ctx context.Context, channel string, ts string, unfurls Unfurls) error {
+  args := struct {
+    Channel string `json:"channel"`
+    Ts string `json:"ts"`
+    Unfurls Unfurls `json:"unfurls"`
+  }{channel, ts, unfurls}
+  return wc.Call(ctx, "chat.unfurl", args, nil)
+}
+
+// Message is a chat.postMessage request.
+type Message struct {
+  Channel string `json:"channel"`
+  Text *string `json:"text,omitempty"`
+  Attachments Attachments `json:"attachments,omitempty"`
+  Blocks Blocks `json:"blocks,omitempty"`
+  // Set to reply in a thread.
+  ThreadTs string `json:"thread_ts,omitempty"`
+}
+
+// NewMessage turns a Response into a Message for channel.
+func NewMessage(channel string, r *Response) *Message {
+  return &Message{
+    Channel: channel,
+    Text: r.Text,
+    Attachments: r.Attachments,
+    Blocks: r.Blocks,
+  }
+}
+
+// PostedMessage identifies a message that was posted.  The Ts can be used
+// as a ThreadTs, to reply in its thread.
+type PostedMessage struct {
+  Channel string `json:"channel"`
+  Ts string `json:"ts"`
+}
+
+// ChatPostMessage posts msg to its channel as the bot user.
+func (wc *WebClient) ChatPostMessage(ctx context.Context, msg *Message) (*PostedMessage, error) {
+  if msg.Blocks != nil {
+    if err := msg.Blocks.Validate(); err != nil {
+      return nil, fmt.Errorf("Invalid message: %s", err.Error())
+    }
+  }
+  var posted PostedMessage
+  if err := wc.Call(ctx, "chat.postMessage", msg, &posted); err != nil {
+    return nil, err
+  }
+  return &posted, nil
+}
+
+// ConversationsReplies returns up to limit messages of the thread started
+// by ts.  The first message is the parent.  Messages are decoded as Events,
+// which have the same common fields, and Raw.
+func (wc *WebClient) ConversationsReplies(ctx context.Context, channel string, ts string, limit int) ([]Event, error) {
+  args := url.Values{}
+  args.Set("channel", channel)
+  args.Set("ts", ts)
+  args.Set("limit", fmt.Sprintf("%d", limit))
+  var replies struct {
+    Messages []Event `json:"messages"`
+  }
+  if err := wc.CallForm(ctx, "conversations.replies", args, &replies); err != nil {
+    return nil, err
+  }
+  return replies.Messages, nil
+}
+
+// User is the part of a Slack user we use.
+type User struct {
+  Id string `json:"id"`
+  Name string `json:"name"`
+  RealName string `json:"real_name"`
+}
+
+// UsersInfo looks up a Slack user by id.
+func (wc *WebClient) UsersInfo(ctx context.Context, userId string) (*User, error) {
+  args := url.Values{}
+  args.Set("user", userId)
+  var info struct {
+    User User `json:"user"`
+  }
+  if err := wc.CallForm(ctx, "users.info", args, &info); err != nil {
+    return nil, err
+  }
+  return &info.User, nil
+}
+
+// OpenedView identifies a modal opened by ViewsOpen, so it can be updated.
+type OpenedView struct {
+  Id string `json:"id"`
+  Hash string `json:"hash"`
+}
+
+// ViewsOpen opens modal for the user whose interaction gave triggerId.
+// Trigger ids expire 3 seconds after Slack sends them.
+func (wc *WebClient) ViewsOpen(ctx context.Context, triggerId string, modal *Modal) (*OpenedView, error) {
+  if err := modal.Validate(); err != nil {
+    return nil, fmt.Errorf("Invalid modal: %s", err.Error())
+  }
+  args := struct {
+    TriggerId string `json:"trigger_id"`
+    View *Modal `json:"view"`
+  }{triggerId, modal}
+  var opened struct {
+    View OpenedView `json:"view"`
+  }
+  if err := wc.Call(ctx, "views.open", args, &opened); err != nil {
+    return nil, err
+  }
+  return &opened.View, nil
+}
+
+// ViewsUpdate replaces an open modal.  If hash is given, the update fails
+// if the view was changed since it was read.
+func (wc *WebClient) ViewsUpdate(ctx context.Context, view *OpenedView, modal *Modal) error {
+  if err := modal.Validate(); err != nil {
+    return fmt.Errorf("Invalid modal: %s", err.Error())
+  }
+  args := struct {
+    ViewId string `json:"view_id"`
+    Hash string `json:"hash,omitempty"`
+    View *Modal `json:"view"`
+  }{view.Id, view.Hash, modal}
+  return wc.Call(ctx, "views.update", args, nil)
+}
@@ -0,0 +1,50 @@
+package command
+
+import (
+  "context"
+
+  "github.com/confyrm/gorest/slack"
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+)
+
+// ActionHandlerFunc is mapped to an interactive action.  For block_actions
+// and interactive_message payloads, action is the action that was clicked.
+// For view_submission and shortcut payloads, action is nil.
+type ActionHandlerFunc func(ctx context.Context, config *config.Config, interaction *slack.Interaction, action *slack.Action) (*slack.Response, *StatusError)
+
+// Action, like Command, maps interactions to handlers.
+type Action struct {
+
+  // The action_id (or the attachment action name) of a button or menu,
+  // or the callback_id of a view or shortcut.
+  Name string
+
+  // If this is a long action, it will be run as a job on the jobs pool.
+  // The interaction MUST contain a response_url to send the outcome to.
+  IsLong bool
+
+  // The handler.
+  Handler ActionHandlerFunc
+}
+// Helper type.
+type Actions []Action
+
+// Router to map action keys and handler funcs.
+type ActionRouter map[string]Action
+
+// New turns the set of Actions into a map for lookup
+func (acts Actions) New() ActionRouter {
+  m := make(map[string]Action)
+  for _, act := range acts {
+    m[act.Name] = act
+  }
+  return m
+}
+
+func (rtr ActionRouter) Route(key string) *Action {
+  if handler, ok := rtr[key]; ok {
+    return &handler
+  }
+  return nil
+}
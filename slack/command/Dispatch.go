@@ -0,0 +1,52 @@
+package command
+
+import (
+  "fmt"
+  "strings"
+  "context"
+  "net/http"
+
+  "github.com/confyrm/gorest/slack"
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+)
+
+// Middleware wraps a ContextHandlerFunc, to do something before or after
+// it, or instead of it.
+type Middleware func(next ContextHandlerFunc) ContextHandlerFunc
+
+// Chain wraps h with middleware.  The first middleware is the outermost.
+func Chain(h ContextHandlerFunc, middleware ...Middleware) ContextHandlerFunc {
+  for i := len(middleware) - 1; i >= 0; i-- {
+    h = middleware[i](h)
+  }
+  return h
+}
+
+// Dispatch is a ContextHandlerFunc that routes a command down the
+// subcommand tree.  The command is validated, then the Handler of the last
+// subcommand in the path is called, wrapped by the Middleware of each
+// subcommand on the way down.  The Schema Middleware wraps all of that, so
+// it also sees validation errors, which are returned as a 400.
+//     Command{Name: "/devhub", Handler: schema.Dispatch, Schema: schema}
+func (s *Schema) Dispatch(ctx context.Context, config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError) {
+  return Chain(s.route, s.Middleware...)(ctx, config, sReq, command)
+}
+
+// route validates the command, then calls the Handler of the last
+// subcommand in the path.
+func (s *Schema) route(ctx context.Context, config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError) {
+  path, err := s.validate(command)
+  if err != nil {
+    return nil, &StatusError{http.StatusBadRequest, err}
+  }
+  h := path[len(path) - 1].Handler
+  if h == nil {
+    return nil, &StatusError{http.StatusNotImplemented,
+      fmt.Errorf("%s has no handler", strings.Join(command.Commands, " "))}
+  }
+  for i := len(path) - 1; i >= 0; i-- {
+    h = Chain(h, path[i].Middleware...)
+  }
+  return h(ctx, config, sReq, command)
+}
@@ -0,0 +1,56 @@
+package command
+
+import (
+  "context"
+
+  "github.com/confyrm/gorest/slack"
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+)
+
+// EventHandlerFunc is mapped to an Events API event type.  There is no one
+// to respond to, so only an error is returned.
+type EventHandlerFunc func(ctx context.Context, config *config.Config, envelope *slack.EventEnvelope) *StatusError
+
+// EventFilterFunc says whether an event is worth handling.  It is called
+// before the event is queued, so it must be quick, and must not call out.
+type EventFilterFunc func(config *config.Config, envelope *slack.EventEnvelope) bool
+
+// Event, like Command, maps event types with event handlers
+type Event struct {
+
+  // The event type.  Such as link_shared
+  Name string
+
+  // If this is a long event, it will be run as a job on the jobs pool.
+  // Slack expects an answer within 3 seconds.
+  IsLong bool
+
+  // The handler.
+  Handler EventHandlerFunc
+
+  // Accept, if set, drops events the Handler would ignore before they take
+  // a place in the jobs pool.  Busy channels send a lot of message events.
+  Accept EventFilterFunc
+}
+// Helper type.
+type Events []Event
+
+// Router to map event types and handler funcs.
+type EventRouter map[string]Event
+
+// New turns the set of Events into a map for lookup
+func (evts Events) New() EventRouter {
+  m := make(map[string]Event)
+  for _, evt := range evts {
+    m[evt.Name] = evt
+  }
+  return m
+}
+
+func (rtr EventRouter) Route(eventType string) *Event {
+  if handler, ok := rtr[eventType]; ok {
+    return &handler
+  }
+  return nil
+}
@@ -0,0 +1,119 @@
+package command
+
+import (
+  "context"
+  "net/http"
+  "testing"
+
+  "github.com/confyrm/gorest/slack"
+  . "github.com/confyrm/gorest/errors"
+  "github.com/confyrm/gorest/config"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+// trace records the order middleware and handlers run in.
+var trace []string
+
+func traced(name string) Middleware {
+  return func(next ContextHandlerFunc) ContextHandlerFunc {
+    return func(ctx context.Context, config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError) {
+      trace = append(trace, name)
+      return next(ctx, config, sReq, command)
+    }
+  }
+}
+
+func tracedHandler(name string) ContextHandlerFunc {
+  return func(ctx context.Context, config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError) {
+    trace = append(trace, name)
+    return &slack.Response{Text: &name}, nil
+  }
+}
+
+var treeSchema = &Schema{
+  Command: "/test",
+  Middleware: []Middleware{traced("root")},
+  Subcommands: []Subcommand{
+    {
+      Name: "issue",
+      Middleware: []Middleware{traced("issue")},
+      Handler: tracedHandler("issue handler"),
+      Params: Params{{Name: "number", Type: IntParam, Position: 1}},
+      Subcommands: []Subcommand{
+        {
+          Name: "label",
+          Middleware: []Middleware{traced("label")},
+          Subcommands: []Subcommand{
+            {
+              Name: "add",
+              Handler: tracedHandler("add handler"),
+              Params: Params{{Name: "number", Type: IntParam, Required: true, Position: 1}},
+            },
+          },
+        },
+      },
+    },
+  },
+}
+
+func dispatch(commands slack.Commands, params slack.KVPairs) (*slack.DevHubCommand, *slack.Response, *StatusError) {
+  trace = nil
+  command := &slack.DevHubCommand{commands, params}
+  resp, statusErr := treeSchema.Dispatch(context.Background(), config.New(nil, nil), &slack.Request{}, command)
+  return command, resp, statusErr
+}
+
+func TestSchemaDispatch(t *testing.T) {
+  Convey("Given a nested subcommand", t, func() {
+    command, resp, statusErr := dispatch(slack.Commands{"issue", "label", "add", "12"}, slack.KVPairs{})
+    Convey("The leaf handler should be called", func() {
+      So(statusErr, ShouldBeNil)
+      So(*resp.Text, ShouldEqual, "add handler")
+    })
+    Convey("Middleware should run from the root down", func() {
+      So(trace, ShouldResemble, []string{"root", "issue", "label", "add handler"})
+    })
+    Convey("The command should hold the path and the positional value", func() {
+      So(command.Commands, ShouldResemble, slack.Commands{"issue", "label", "add"})
+      So(command.Params, ShouldResemble, slack.KVPairs{"number": "12"})
+    })
+  })
+
+  Convey("Given a subcommand that has both a handler and children", t, func() {
+    _, resp, statusErr := dispatch(slack.Commands{"issue", "12"}, slack.KVPairs{})
+    Convey("Its own handler should be called", func() {
+      So(statusErr, ShouldBeNil)
+      So(*resp.Text, ShouldEqual, "issue handler")
+      So(trace, ShouldResemble, []string{"root", "issue", "issue handler"})
+    })
+  })
+
+  Convey("Given a group without a subcommand", t, func() {
+    _, _, statusErr := dispatch(slack.Commands{"issue", "label"}, slack.KVPairs{})
+    Convey("It should be a bad request that lists the choices", func() {
+      So(statusErr.Code, ShouldEqual, http.StatusBadRequest)
+      So(statusErr.Error(), ShouldContainSubstring, "Needs one of: add")
+    })
+    Convey("The root middleware should still see it", func() {
+      So(trace, ShouldResemble, []string{"root"})
+    })
+  })
+
+  Convey("Given an unknown subcommand", t, func() {
+    _, _, statusErr := dispatch(slack.Commands{"pr", "list"}, slack.KVPairs{})
+    Convey("It should be a bad request", func() {
+      So(statusErr.Code, ShouldEqual, http.StatusBadRequest)
+      So(statusErr.Error(), ShouldContainSubstring, `Unknown subcommand "pr"`)
+    })
+  })
+}
+
+func TestSchemaHelpPath(t *testing.T) {
+  Convey("Given a nested help path", t, func() {
+    text := treeSchema.Help(slack.Commands{"issue", "label"})
+    Convey("The help should be for the nested subcommand", func() {
+      So(text, ShouldStartWith, "/test issue *label*")
+      So(text, ShouldContainSubstring, "- add")
+    })
+  })
+}
@@ -0,0 +1,115 @@
+package command
+
+import (
+  "fmt"
+  "strings"
+  "testing"
+
+  "github.com/confyrm/gorest/slack"
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+var testSchema = &Schema{
+  Command: "/test",
+  Subcommands: []Subcommand{
+    {
+      Name: "new",
+      Aliases: []string{"create"},
+      Summary: "create a thing",
+      Params: Params{
+        {Name: "title", Required: true, Help: "Thing title."},
+        {Name: "labels", Type: ListParam, Aliases: []string{"label"}},
+        {Name: "assignee", Type: UserParam},
+        {Name: "state", Type: EnumParam, Values: []string{"open", "closed"}, Default: "open"},
+        {Name: "draft", Type: BoolParam},
+      },
+    },
+    {
+      Name: "get",
+      Summary: "get a thing",
+      Params: Params{
+        {Name: "number", Type: IntParam, Required: true, Position: 1},
+      },
+    },
+  },
+}
+
+var validData = []struct {
+  Name string
+  Command slack.DevHubCommand
+  Expected slack.DevHubCommand
+} {
+  {
+    "an alias, list, user, bool and a default",
+    slack.DevHubCommand{slack.Commands{"create"}, slack.KVPairs{"title": "x", "label": " a, ,b ", "assignee": "@steve", "draft": "yes"}},
+    slack.DevHubCommand{slack.Commands{"new"}, slack.KVPairs{"title": "x", "labels": "a,b", "assignee": "steve", "state": "open", "draft": "true"}},
+  },
+  {
+    "an enum in the wrong case",
+    slack.DevHubCommand{slack.Commands{"new"}, slack.KVPairs{"title": "x", "state": "CLOSED"}},
+    slack.DevHubCommand{slack.Commands{"new"}, slack.KVPairs{"title": "x", "state": "closed"}},
+  },
+  {
+    "a positional number",
+    slack.DevHubCommand{slack.Commands{"get", "12"}, slack.KVPairs{}},
+    slack.DevHubCommand{slack.Commands{"get"}, slack.KVPairs{"number": "12"}},
+  },
+}
+
+var invalidData = []struct {
+  Name string
+  Command slack.DevHubCommand
+  Problems int
+} {
+  {"no subcommand", slack.DevHubCommand{slack.Commands{}, slack.KVPairs{}}, 1},
+  {"an unknown subcommand", slack.DevHubCommand{slack.Commands{"nope"}, slack.KVPairs{}}, 1},
+  {"a missing required key", slack.DevHubCommand{slack.Commands{"new"}, slack.KVPairs{"labels": "a"}}, 1},
+  {"an unknown key", slack.DevHubCommand{slack.Commands{"new"}, slack.KVPairs{"title": "x", "color": "red"}}, 1},
+  {"a key and its alias", slack.DevHubCommand{slack.Commands{"new"}, slack.KVPairs{"title": "x", "labels": "a", "label": "b"}}, 1},
+  {"a bad number", slack.DevHubCommand{slack.Commands{"get", "twelve"}, slack.KVPairs{}}, 1},
+  {"a number given twice", slack.DevHubCommand{slack.Commands{"get", "12"}, slack.KVPairs{"number": "12"}}, 1},
+  {"an extra word", slack.DevHubCommand{slack.Commands{"get", "12", "13"}, slack.KVPairs{}}, 1},
+  {"several problems", slack.DevHubCommand{slack.Commands{"new"}, slack.KVPairs{"state": "gone", "draft": "maybe", "assignee": "not a login"}}, 4},
+}
+
+func TestSchemaValidate(t *testing.T) {
+  for _, d := range validData {
+    command := d.Command
+    _, err := testSchema.Validate(&command)
+    Convey(fmt.Sprintf("Given %s", d.Name), t, func() {
+      Convey("Err should be nil", func() {
+        So(err, ShouldBeNil)
+      })
+      Convey(fmt.Sprintf("The command should be normalized to [%v]", d.Expected), func() {
+        So(command, ShouldResemble, d.Expected)
+      })
+    })
+  }
+
+  for _, d := range invalidData {
+    _, err := testSchema.Validate(&d.Command)
+    Convey(fmt.Sprintf("Given %s", d.Name), t, func() {
+      Convey(fmt.Sprintf("There should be %d problem(s)", d.Problems), func() {
+        So(err, ShouldHaveSameTypeAs, &ValidationError{})
+        So(err.(*ValidationError).Problems, ShouldHaveLength, d.Problems)
+      })
+    })
+  }
+}
+
+func TestSchemaHelp(t *testing.T) {
+  Convey("Given the test schema", t, func() {
+    Convey("The top level help should list every subcommand", func() {
+      text := testSchema.Help(slack.Commands{})
+      So(text, ShouldContainSubstring, "- new: create a thing")
+      So(text, ShouldContainSubstring, "- get: get a thing")
+    })
+    Convey("Subcommand help should describe every key", func() {
+      text := testSchema.Help(slack.Commands{"create"})
+      So(strings.Count(text, "\n* "), ShouldEqual, 5)
+      So(text, ShouldContainSubstring, "* title (text): Required. Thing title.")
+      So(text, ShouldContainSubstring, "Defaults to open.")
+      So(text, ShouldContainSubstring, "Also: label.")
+    })
+  })
+}
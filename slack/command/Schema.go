@@ -0,0 +1,456 @@
+package command
+
+import (
+  "fmt"
+  "sort"
+  "bytes"
+  "regexp"
+  "strconv"
+  "strings"
+
+  "github.com/confyrm/gorest/slack"
+)
+
+// ParamType is the type of a subcommand parameter.  Values are validated
+// and normalized according to their type, so handlers can read them with
+// the DevHubCommand helpers, such as ValueToInt and Values.
+type ParamType int
+
+const (
+  // Any text.
+  StringParam ParamType = iota
+  // A whole number.
+  IntParam
+  // A comma separated list.  Items are trimmed, and empty items dropped.
+  ListParam
+  // One of Param.Values.  Matching is case insensitive.
+  EnumParam
+  // A GitHub login.  A leading '@' is dropped.
+  UserParam
+  // true/false, yes/no, on/off or 1/0.  Normalized to "true" or "false".
+  BoolParam
+)
+
+func (t ParamType) String() string {
+  switch t {
+  case StringParam:
+    return "text"
+  case IntParam:
+    return "number"
+  case ListParam:
+    return "list"
+  case EnumParam:
+    return "one of"
+  case UserParam:
+    return "user"
+  case BoolParam:
+    return "true/false"
+  }
+  return "unknown"
+}
+
+// Param declares a single key of a subcommand.
+type Param struct {
+  // The canonical key.  Handlers look values up by this name.
+  Name string
+  Type ParamType
+  Required bool
+  // Used when the key is not given.  Empty means no default.
+  Default string
+  // Other keys that mean the same thing.
+  Aliases []string
+  // The allowed values of an EnumParam.
+  Values []string
+  // If > 0, the param can also be given as the nth word after the
+  // subcommand.  Like the 152 in: /devhub get 152
+  Position int
+  // One line description for the help text.
+  Help string
+}
+
+// Helper type for a slice of Params
+type Params []Param
+
+// Subcommand declares a subcommand, such as "new" in: /devhub new title=x
+// Subcommands nest, so "list" in: /devhub pr list is a Subcommand of "pr".
+type Subcommand struct {
+  Name string
+  Aliases []string
+  // One line description for the help text.
+  Summary string
+  Params Params
+  // Example command text, without the slash command.
+  Examples []string
+  // Called when this is the last subcommand in the command.  A Subcommand
+  // that only groups other Subcommands has no Handler.
+  Handler ContextHandlerFunc
+  // Wrap the Handler of this Subcommand, and of every Subcommand below it.
+  Middleware []Middleware
+  Subcommands []Subcommand
+}
+
+// Schema declares the subcommand tree of a slash command.
+type Schema struct {
+  // The slash command.  Such as /devhub
+  Command string
+  Subcommands []Subcommand
+  // Wrap every Handler in the tree.  These also see validation errors.
+  Middleware []Middleware
+}
+
+// ValidationError lists everything that was wrong with a command, so the
+// user can fix it all in one go.
+type ValidationError struct {
+  Subcommand string
+  Problems []string
+}
+
+func (e *ValidationError) Error() string {
+  if len(e.Problems) == 1 {
+    return fmt.Sprintf("%s: %s", e.Subcommand, e.Problems[0])
+  }
+  return fmt.Sprintf("%s:\n- %s", e.Subcommand, strings.Join(e.Problems, "\n- "))
+}
+
+// Subcommand finds a top level subcommand by name or alias.
+func (s *Schema) Subcommand(name string) *Subcommand {
+  return findSubcommand(s.Subcommands, name)
+}
+
+// Subcommand finds a child subcommand by name or alias.
+func (sub *Subcommand) Subcommand(name string) *Subcommand {
+  return findSubcommand(sub.Subcommands, name)
+}
+
+func findSubcommand(subs []Subcommand, name string) *Subcommand {
+  name = strings.ToLower(name)
+  for i := range subs {
+    sub := &subs[i]
+    if sub.Name == name || contains(sub.Aliases, name) {
+      return sub
+    }
+  }
+  return nil
+}
+
+// Resolve walks command.Commands down the subcommand tree, popping each
+// subcommand off the command as it goes.  The words left in the command
+// are the positional values of the last subcommand.  It returns the path
+// from the top level subcommand to the one that handles the command.
+func (s *Schema) Resolve(command *slack.DevHubCommand) ([]*Subcommand, error) {
+  var path []*Subcommand
+  children := s.Subcommands
+  for len(children) > 0 {
+    name, ok := command.Peek()
+    if !ok {
+      break
+    }
+    sub := findSubcommand(children, name)
+    if sub == nil {
+      break
+    }
+    command.Pop()
+    path = append(path, sub)
+    children = sub.Subcommands
+  }
+
+  if len(path) == 0 {
+    if name, ok := command.Peek(); ok {
+      return nil, &ValidationError{s.Command, []string{fmt.Sprintf("Unknown subcommand %q. Try: %s help", name, s.Command)}}
+    }
+    return nil, &ValidationError{s.Command, []string{"No subcommand given. Try: " + s.Command + " help"}}
+  }
+
+  leaf := path[len(path) - 1]
+  if leaf.Handler == nil && len(leaf.Subcommands) > 0 {
+    name := strings.Join(pathNames(path), " ")
+    problem := fmt.Sprintf("Needs one of: %s.", strings.Join(subcommandNames(leaf.Subcommands), ", "))
+    if next, ok := command.Peek(); ok {
+      problem = fmt.Sprintf("Unknown subcommand %q. %s", next, problem)
+    }
+    return nil, &ValidationError{name, []string{fmt.Sprintf("%s Try: %s help %s", problem, s.Command, name)}}
+  }
+  return path, nil
+}
+
+// Validate resolves command against the schema, and normalizes it in place:
+// the subcommand path is replaced by the canonical subcommand names,
+// positional values and aliased keys are moved to their canonical keys,
+// defaults are filled in, and values are normalized by type.  Every problem
+// found is returned in a single *ValidationError.  It returns the
+// Subcommand that handles the command.
+func (s *Schema) Validate(command *slack.DevHubCommand) (*Subcommand, error) {
+  path, err := s.validate(command)
+  if err != nil {
+    return nil, err
+  }
+  return path[len(path) - 1], nil
+}
+
+func (s *Schema) validate(command *slack.DevHubCommand) ([]*Subcommand, error) {
+  path, err := s.Resolve(command)
+  if err != nil {
+    return nil, err
+  }
+  if err := path[len(path) - 1].Validate(command); err != nil {
+    if verr, ok := err.(*ValidationError); ok {
+      verr.Subcommand = strings.Join(pathNames(path), " ")
+    }
+    return nil, err
+  }
+  command.Commands = pathNames(path)
+  return path, nil
+}
+
+func pathNames(path []*Subcommand) slack.Commands {
+  names := make(slack.Commands, len(path))
+  for i, sub := range path {
+    names[i] = sub.Name
+  }
+  return names
+}
+
+func subcommandNames(subs []Subcommand) []string {
+  names := make([]string, len(subs))
+  for i, sub := range subs {
+    names[i] = sub.Name
+  }
+  return names
+}
+
+// Validate checks the words left in the command, and the key value pairs,
+// against the subcommand's params.  It expects the subcommand path to have
+// already been popped off of the command.  See Schema.Validate.
+func (sub *Subcommand) Validate(command *slack.DevHubCommand) error {
+  var problems []string
+  params := make(slack.KVPairs, len(command.Params))
+
+  keys := make([]string, 0, len(command.Params))
+  for key := range command.Params {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  // Canonical keys first, so the duplicate is reported against the alias.
+  for _, canonical := range []bool{true, false} {
+    for _, key := range keys {
+      p := sub.Params.find(key)
+      if p == nil {
+        if !canonical {
+          problems = append(problems, fmt.Sprintf("Unknown key %q", key))
+        }
+        continue
+      }
+      if (p.Name == key) != canonical {
+        continue
+      }
+      if _, ok := params[p.Name]; ok {
+        problems = append(problems, fmt.Sprintf("%q and %q mean the same thing", p.Name, key))
+        continue
+      }
+      params[p.Name] = command.Params[key]
+    }
+  }
+
+  // Positional values
+  positional := command.CommandsFrom(0)
+  for i, value := range positional {
+    p := sub.Params.at(i + 1)
+    if p == nil {
+      problems = append(problems, fmt.Sprintf("Unexpected word %q", value))
+      continue
+    }
+    if _, ok := params[p.Name]; ok {
+      problems = append(problems, fmt.Sprintf("%q was given as both a word and a key", p.Name))
+      continue
+    }
+    params[p.Name] = value
+  }
+
+  for _, p := range sub.Params {
+    value, ok := params[p.Name]
+    if !ok {
+      if p.Default != "" {
+        params[p.Name] = p.Default
+      } else if p.Required {
+        problems = append(problems, fmt.Sprintf("%q is required", p.Name))
+      }
+      continue
+    }
+    normalized, err := p.Normalize(value)
+    if err != nil {
+      problems = append(problems, err.Error())
+      continue
+    }
+    params[p.Name] = normalized
+  }
+
+  if len(problems) > 0 {
+    return &ValidationError{sub.Name, problems}
+  }
+  command.Commands = slack.Commands{}
+  command.Params = params
+  return nil
+}
+
+var githubLogin = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9]|-[A-Za-z0-9]){0,38}$`)
+
+// Normalize checks value against the param type, and returns it in its
+// canonical form.
+func (p *Param) Normalize(value string) (string, error) {
+  value = strings.TrimSpace(value)
+  switch p.Type {
+  case IntParam:
+    if _, err := strconv.Atoi(value); err != nil {
+      return "", fmt.Errorf("%q must be a number, not %q", p.Name, value)
+    }
+  case ListParam:
+    items := make([]string, 0)
+    for _, item := range strings.Split(value, ",") {
+      if item = strings.TrimSpace(item); item != "" {
+        items = append(items, item)
+      }
+    }
+    value = strings.Join(items, ",")
+  case EnumParam:
+    for _, allowed := range p.Values {
+      if strings.EqualFold(value, allowed) {
+        return allowed, nil
+      }
+    }
+    return "", fmt.Errorf("%q must be one of %s, not %q", p.Name, strings.Join(p.Values, ", "), value)
+  case UserParam:
+    value = strings.TrimPrefix(value, "@")
+    if !githubLogin.MatchString(value) {
+      return "", fmt.Errorf("%q must be a GitHub login, not %q", p.Name, value)
+    }
+  case BoolParam:
+    switch strings.ToLower(value) {
+    case "true", "yes", "on", "1":
+      value = "true"
+    case "false", "no", "off", "0":
+      value = "false"
+    default:
+      return "", fmt.Errorf("%q must be true or false, not %q", p.Name, value)
+    }
+  }
+  if value == "" && p.Required {
+    return "", fmt.Errorf("%q can not be empty", p.Name)
+  }
+  return value, nil
+}
+
+// find returns the param with the given name or alias.
+func (params Params) find(key string) *Param {
+  for i := range params {
+    if params[i].Name == key || contains(params[i].Aliases, key) {
+      return &params[i]
+    }
+  }
+  return nil
+}
+
+// at returns the param at the given position.
+func (params Params) at(position int) *Param {
+  for i := range params {
+    if params[i].Position == position {
+      return &params[i]
+    }
+  }
+  return nil
+}
+
+func contains(list []string, s string) bool {
+  for _, item := range list {
+    if item == s {
+      return true
+    }
+  }
+  return false
+}
+
+// Help returns the help text for the subcommands in path.  An empty path
+// gets the top level help.  An unknown subcommand gets the help of the
+// deepest subcommand that was found.
+func (s *Schema) Help(path slack.Commands) string {
+  var parents []string
+  children := s.Subcommands
+  var sub *Subcommand
+  for _, name := range path {
+    next := findSubcommand(children, name)
+    if next == nil {
+      break
+    }
+    if sub != nil {
+      parents = append(parents, sub.Name)
+    }
+    sub = next
+    children = sub.Subcommands
+  }
+  if sub != nil {
+    return sub.Help(strings.Join(append([]string{s.Command}, parents...), " "))
+  }
+
+  var b bytes.Buffer
+  fmt.Fprintf(&b, "%s accepts the following commands:\n", s.Command)
+  for _, sub := range s.Subcommands {
+    fmt.Fprintf(&b, "- %s: %s\n", sub.Name, sub.Summary)
+  }
+  fmt.Fprintf(&b, "- help: this text.  Use `%s help <command>` for more.\n", s.Command)
+  return b.String()
+}
+
+// Help returns the help text for the subcommand.  prefix is everything
+// that comes before it, such as "/devhub pr".
+func (sub *Subcommand) Help(prefix string) string {
+  var b bytes.Buffer
+  fmt.Fprintf(&b, "%s *%s*: %s\n", prefix, sub.Name, sub.Summary)
+  if len(sub.Aliases) > 0 {
+    fmt.Fprintf(&b, "Also: %s\n", strings.Join(sub.Aliases, ", "))
+  }
+
+  if len(sub.Subcommands) > 0 {
+    fmt.Fprintf(&b, "\n%s %s accepts the following commands:\n", prefix, sub.Name)
+    for _, child := range sub.Subcommands {
+      fmt.Fprintf(&b, "- %s: %s\n", child.Name, child.Summary)
+    }
+  }
+
+  if len(sub.Params) > 0 {
+    b.WriteString("\nHere are the supported keys:\n")
+    for _, p := range sub.Params {
+      fmt.Fprintf(&b, "* %s (%s", p.Name, p.Type)
+      if p.Type == EnumParam {
+        fmt.Fprintf(&b, " %s", strings.Join(p.Values, ", "))
+      }
+      b.WriteString("): ")
+      if p.Required {
+        b.WriteString("Required. ")
+      } else {
+        b.WriteString("Optional. ")
+      }
+      b.WriteString(p.Help)
+      if p.Position > 0 {
+        fmt.Fprintf(&b, " Can be given as word %d after %s.", p.Position, sub.Name)
+      }
+      if p.Default != "" {
+        fmt.Fprintf(&b, " Defaults to %s.", p.Default)
+      }
+      if len(p.Aliases) > 0 {
+        fmt.Fprintf(&b, " Also: %s.", strings.Join(p.Aliases, ", "))
+      }
+      b.WriteString("\n")
+    }
+    b.WriteString("\nKeys can be given as key=value, key:value or --key value.  Quote values\n")
+    b.WriteString("that contain '=', or words that look like keys: title=\"a = b\".\n")
+  }
+
+  if len(sub.Examples) > 0 {
+    b.WriteString("\nExamples:\n")
+    // Examples are relative to the slash command.
+    command := strings.Fields(prefix)[0]
+    for _, example := range sub.Examples {
+      fmt.Fprintf(&b, "* %s %s\n", command, example)
+    }
+  }
+  return b.String()
+}
@@ -1,6 +1,8 @@
 package command
 
 import (
+  "context"
+
   "github.com/confyrm/gorest/slack"
   . "github.com/confyrm/gorest/errors"
   "github.com/confyrm/gorest/config"
@@ -9,21 +11,40 @@ import (
 // CommandHandlerFunc is mapped to a slash command. The function returns the
 // outcome as a string, or it returns a StatusError. If the CommandHandlerFunc
 // is not long running, then the StatusError is returned by the route handler.
-// If the CommandHandlerFunc is long running, then it is simply logged.
+// If the CommandHandlerFunc is long running, then it is run as a job, and
+// the StatusError is recorded as the job outcome.
 type CommandHandlerFunc func(config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError)
 
+// ContextHandlerFunc is a CommandHandlerFunc that also receives a context.
+// For short commands, the context is the request context.  For long running
+// commands it is detached from the request, but still carries the request ID
+// and is bounded by LONG_COMMAND_TIMEOUT.
+type ContextHandlerFunc func(ctx context.Context, config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError)
+
+// Adapt lets a CommandHandlerFunc be used as a Command.Handler.  The context
+// is simply dropped.
+func Adapt(h CommandHandlerFunc) ContextHandlerFunc {
+  return func(ctx context.Context, config *config.Config, sReq *slack.Request, command *slack.DevHubCommand) (*slack.Response, *StatusError) {
+    return h(config, sReq, command)
+  }
+}
+
 // Command, like Route, maps slash commands with command handlers
 type Command struct {
 
   // The slash command.  Such as /devhub
   Name string
 
-  // If this is a long command, it will be run as a goroutine.
+  // If this is a long command, it will be run as a job on the jobs pool.
   // The request MUST contain a response_url, or an error will be thrown
   IsLong bool
 
-  // The handler.
-  Handler CommandHandlerFunc
+  // The handler.  Use Adapt for handlers that do not take a context.
+  Handler ContextHandlerFunc
+
+  // Optional.  If set, the command is validated against the schema before
+  // the handler is called, and help is generated from it.
+  Schema *Schema
 }
 // Helper type.
 type Commands []Command
@@ -0,0 +1,158 @@
+package slacktest
+
+import (
+  "fmt"
+  "net/url"
+  "net/http"
+  "encoding/json"
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/router/handler"
+)
+
+// Conversation is one request Slack sent to the app, and everything the app
+// sent back because of it.  Each has its own response_url.
+type Conversation struct {
+  ResponseUrl string
+  TriggerId string
+  // Status and Body are the app's immediate HTTP reply.
+  Status int
+  Body string
+  // Reply is Body decoded, if it was a JSON slack.Response.
+  Reply *slack.Response
+
+  s *Server
+}
+
+// Slash sends a slash command, such as Slash("/devhub", "issue get 1").
+func (s *Server) Slash(command string, text string) (*Conversation, error) {
+  c := s.newConversation()
+  form := url.Values{
+    "token": {"legacy-token"},
+    "team_id": {s.TeamId},
+    "team_domain": {s.TeamDomain},
+    "channel_id": {s.ChannelId},
+    "channel_name": {s.ChannelName},
+    "user_id": {s.UserId},
+    "user_name": {s.UserName},
+    "command": {command},
+    "text": {text},
+    "response_url": {c.ResponseUrl},
+    "trigger_id": {c.TriggerId},
+  }
+  return c, c.post(SlashPath, "application/x-www-form-urlencoded", []byte(form.Encode()))
+}
+
+// Interact sends an interaction payload.  The team, user, channel,
+// response_url and trigger_id are filled in if they are not set.  Views have
+// no response_url.
+func (s *Server) Interact(interaction *slack.Interaction) (*Conversation, error) {
+  c := s.newConversation()
+  if interaction.Team.Id == "" {
+    interaction.Team = slack.InteractionTeam{Id: s.TeamId, Domain: s.TeamDomain}
+  }
+  if interaction.User.Id == "" {
+    interaction.User = slack.InteractionUser{Id: s.UserId, UserName: s.UserName}
+  }
+  if interaction.TriggerId == "" {
+    interaction.TriggerId = c.TriggerId
+  }
+  switch interaction.Type {
+  case slack.ViewSubmission, slack.ViewClosed:
+    c.ResponseUrl = ""
+  default:
+    if interaction.Channel == nil {
+      interaction.Channel = &slack.InteractionChannel{Id: s.ChannelId, Name: s.ChannelName}
+    }
+    if interaction.ResponseUrl == "" {
+      interaction.ResponseUrl = c.ResponseUrl
+    }
+    c.ResponseUrl = interaction.ResponseUrl
+  }
+
+  payload, err := json.Marshal(interaction)
+  if err != nil {
+    return nil, err
+  }
+  form := url.Values{"payload": {string(payload)}}
+  return c, c.post(InteractivePath, "application/x-www-form-urlencoded", []byte(form.Encode()))
+}
+
+// Click presses a Block Kit button, such as Click(commands.CloseIssue,
+// "o/r#1"), on a message in the Server's channel.
+func (s *Server) Click(actionId string, value string) (*Conversation, error) {
+  return s.Interact(&slack.Interaction{
+    Type: slack.BlockActions,
+    Actions: slack.Actions{{ActionId: actionId, Type: "button", Value: value}},
+  })
+}
+
+// Submit submits a modal.  view needs its CallbackId, PrivateMetadata and
+// the State of its inputs.
+func (s *Server) Submit(view *slack.View) (*Conversation, error) {
+  return s.Interact(&slack.Interaction{Type: slack.ViewSubmission, View: view})
+}
+
+// Event delivers an event_callback.  A random event id is used if it is not
+// set, since the app drops events it has already seen.  Events have no
+// response_url; look at the Web API calls instead.
+func (s *Server) Event(envelope *slack.EventEnvelope) (*Conversation, error) {
+  c := s.newConversation()
+  c.ResponseUrl = ""
+  if envelope.Type == "" {
+    envelope.Type = slack.EventCallback
+  }
+  if envelope.TeamId == "" {
+    envelope.TeamId = s.TeamId
+  }
+  if envelope.EventId == "" {
+    envelope.EventId = "Ev" + handler.NewRequestID()
+  }
+  body, err := json.Marshal(envelope)
+  if err != nil {
+    return nil, err
+  }
+  return c, c.post(EventsPath, "application/json", body)
+}
+
+func (s *Server) newConversation() *Conversation {
+  responseUrl := s.NewResponseUrl()
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.next++
+  return &Conversation{
+    ResponseUrl: responseUrl,
+    TriggerId: fmt.Sprintf("%d.trigger", s.next),
+    s: s,
+  }
+}
+
+func (c *Conversation) post(path string, contentType string, body []byte) error {
+  resp, content, err := c.s.Post(path, contentType, body)
+  if err != nil {
+    return err
+  }
+  c.Status = resp.StatusCode
+  c.Body = string(content)
+  if resp.StatusCode == http.StatusOK && len(content) > 0 && content[0] == '{' {
+    reply, err := DecodeResponse(content)
+    if err != nil {
+      return fmt.Errorf("Bad reply from %s: %s", path, err.Error())
+    }
+    c.Reply = reply
+  }
+  return nil
+}
+
+// Responses returns what the app has posted to the response_url so far.
+func (c *Conversation) Responses() []*slack.Response {
+  return c.s.Responses(c.ResponseUrl)
+}
+
+// Wait waits until the app has posted n responses to the response_url, and
+// returns them.
+func (c *Conversation) Wait(n int) ([]*slack.Response, error) {
+  if c.ResponseUrl == "" {
+    return nil, fmt.Errorf("There is no response_url to wait on")
+  }
+  return c.s.WaitForResponses(c.ResponseUrl, n)
+}
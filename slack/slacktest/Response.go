@@ -0,0 +1,67 @@
+package slacktest
+
+import (
+  "strings"
+  "encoding/json"
+  "github.com/confyrm/gorest/slack"
+)
+
+// RawBlock is a recorded Block Kit block.  slack.Blocks holds interfaces,
+// which JSON can not be decoded into, so recorded blocks are kept as JSON.
+type RawBlock struct {
+  Type string
+  JSON json.RawMessage
+}
+
+// BlockType is the "type" of the block.
+func (b *RawBlock) BlockType() string {
+  return b.Type
+}
+
+// Validate is a no-op.  The app already validated the block.
+func (b *RawBlock) Validate() error {
+  return nil
+}
+
+// MarshalJSON writes the block back out as it was recorded.
+func (b *RawBlock) MarshalJSON() ([]byte, error) {
+  return b.JSON, nil
+}
+
+// DecodeResponse decodes a slack.Response as the app sent it.  Its Blocks
+// are RawBlocks.
+func DecodeResponse(data []byte) (*slack.Response, error) {
+  var r struct {
+    slack.Response
+    Blocks []json.RawMessage `json:"blocks"`
+  }
+  if err := json.Unmarshal(data, &r); err != nil {
+    return nil, err
+  }
+  response := r.Response
+  for _, raw := range r.Blocks {
+    var block struct {
+      Type string `json:"type"`
+    }
+    if err := json.Unmarshal(raw, &block); err != nil {
+      return nil, err
+    }
+    response.Blocks = append(response.Blocks, &RawBlock{block.Type, raw})
+  }
+  return &response, nil
+}
+
+// Contains is true if s appears anywhere in the response: its text,
+// attachments or blocks.
+func Contains(response *slack.Response, s string) bool {
+  if response == nil {
+    return false
+  }
+  data, err := json.Marshal(response)
+  if err != nil {
+    return false
+  }
+  // Escape s the same way, so quotes and newlines match.
+  escaped, _ := json.Marshal(s)
+  return strings.Contains(string(data), string(escaped[1:len(escaped)-1]))
+}
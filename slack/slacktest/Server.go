@@ -0,0 +1,399 @@
+// Package slacktest is an in-process fake of Slack, for driving the app end
+// to end.  It sends signed slash commands, interactions and events to the
+// app, as Slack would, and records what the app sends back: the immediate
+// HTTP replies, the slack.Responses posted to response_urls, and the Web API
+// calls.  Point SLACK_API_URL (commands.SlackApiUrl) at ApiUrl to use it.
+package slacktest
+
+import (
+  "fmt"
+  "sync"
+  "time"
+  "bytes"
+  "strings"
+  "net/url"
+  "net/http"
+  "io/ioutil"
+  "encoding/json"
+  "net/http/httptest"
+  "github.com/gorilla/mux"
+  "github.com/confyrm/gorest/slack"
+  "github.com/confyrm/gorest/router/handler"
+)
+
+// Paths of the app routes that Slack calls.  See routes.RouteSet.
+const (
+  SlashPath = "/slack"
+  InteractivePath = "/interactive"
+  EventsPath = "/events"
+)
+
+// DefaultTimeout is how long the Wait methods wait.  Long commands are run
+// on the job pool, so their responses arrive after the slash command has
+// been answered.
+const DefaultTimeout = 5 * time.Second
+
+// Server is a fake Slack.  Set App to the url of the app under test before
+// sending it anything.
+type Server struct {
+  *httptest.Server
+  // SigningSecret signs every request.  The app's SLACK_SIGNING_SECRET
+  // must match.
+  SigningSecret string
+  // App is the base url of the app, such as an httptest.Server URL.
+  App string
+  // Timeout is how long the Wait methods wait.
+  Timeout time.Duration
+
+  // Who and where requests come from.
+  TeamId string
+  TeamDomain string
+  UserId string
+  UserName string
+  ChannelId string
+  ChannelName string
+
+  mu sync.Mutex
+  changed chan struct{}
+  responses map[string][]*slack.Response
+  calls []*Call
+  failures map[string]string
+  replies map[string][]slack.Event
+  users map[string]*slack.User
+  // Sign in with Slack codes, and the user tokens they were traded for,
+  // each to its user id.
+  signInCodes map[string]string
+  userTokens map[string]string
+  next int
+}
+
+// Call is a recorded Web API call.
+type Call struct {
+  Method string
+  // Body is the JSON request body.  It is nil for the methods that take
+  // form arguments, such as conversations.replies, which set Form instead.
+  Body json.RawMessage
+  Form url.Values
+  // Token is the bearer token the call was made with.
+  Token string
+}
+
+// Decode unmarshals the JSON body of the call into v.
+func (c *Call) Decode(v interface{}) error {
+  if c.Body == nil {
+    return fmt.Errorf("%s was called with a form, not JSON", c.Method)
+  }
+  return json.Unmarshal(c.Body, v)
+}
+
+// Message decodes a chat.postMessage call.
+func (c *Call) Message() (*slack.Message, error) {
+  var msg slack.Message
+  if err := c.Decode(&msg); err != nil {
+    return nil, err
+  }
+  return &msg, nil
+}
+
+// NewServer starts a fake Slack that signs with secret.  Close it when done.
+func NewServer(secret string) *Server {
+  s := &Server{
+    SigningSecret: secret,
+    Timeout: DefaultTimeout,
+    TeamId: "T0001",
+    TeamDomain: "example",
+    UserId: "U1",
+    UserName: "steve",
+    ChannelId: "C1",
+    ChannelName: "general",
+    changed: make(chan struct{}),
+    responses: make(map[string][]*slack.Response),
+    failures: make(map[string]string),
+    replies: make(map[string][]slack.Event),
+    users: make(map[string]*slack.User),
+    signInCodes: make(map[string]string),
+    userTokens: make(map[string]string),
+  }
+  s.Server = httptest.NewServer(s.router())
+  return s
+}
+
+// ApiUrl is the Web API base url, for SLACK_API_URL.
+func (s *Server) ApiUrl() string {
+  return s.URL + "/api/"
+}
+
+// NewResponseUrl returns a response_url that records what is posted to it.
+func (s *Server) NewResponseUrl() string {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.next++
+  return fmt.Sprintf("%s/response/%d", s.URL, s.next)
+}
+
+// Responses returns what has been posted to responseUrl so far.
+func (s *Server) Responses(responseUrl string) []*slack.Response {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return append([]*slack.Response{}, s.responses[responseUrl]...)
+}
+
+// WaitForResponses waits until at least n responses have been posted to
+// responseUrl, and returns them.
+func (s *Server) WaitForResponses(responseUrl string, n int) ([]*slack.Response, error) {
+  err := s.wait(func() bool { return len(s.responses[responseUrl]) >= n })
+  responses := s.Responses(responseUrl)
+  if err != nil {
+    return responses, fmt.Errorf("Got %d of %d responses to %s", len(responses), n, responseUrl)
+  }
+  return responses, nil
+}
+
+// Calls returns the calls made so far to the Web API method, such as
+// "chat.postMessage".  An empty method returns every call.
+func (s *Server) Calls(method string) []*Call {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return s.callsTo(method)
+}
+
+// WaitForCalls waits until at least n calls have been made to method, and
+// returns them.
+func (s *Server) WaitForCalls(method string, n int) ([]*Call, error) {
+  err := s.wait(func() bool { return len(s.callsTo(method)) >= n })
+  calls := s.Calls(method)
+  if err != nil {
+    return calls, fmt.Errorf("Got %d of %d calls to %s", len(calls), n, method)
+  }
+  return calls, nil
+}
+
+// Fail makes calls to method answer "ok": false with slackError, such as
+// "channel_not_found".
+func (s *Server) Fail(method string, slackError string) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.failures[method] = slackError
+}
+
+// SetReplies sets the messages conversations.replies returns for the thread
+// started by ts.  The first message should be the parent.  Messages decoded
+// from JSON are returned as their Raw JSON.
+func (s *Server) SetReplies(channel string, ts string, messages ...slack.Event) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.replies[channel + "/" + ts] = messages
+}
+
+// AddUser adds a user for users.info.
+func (s *Server) AddUser(user slack.User) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.users[user.Id] = &user
+}
+
+// SignInCode returns a code that Sign in with Slack would send to the
+// app's redirect url, once userId has signed in.  openid.connect.token
+// trades it for a user token, and openid.connect.userInfo says whose it is.
+func (s *Server) SignInCode(userId string) string {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.next++
+  code := fmt.Sprintf("code%d", s.next)
+  s.signInCodes[code] = userId
+  return code
+}
+
+func (s *Server) callsTo(method string) []*Call {
+  calls := []*Call{}
+  for _, c := range s.calls {
+    if method == "" || c.Method == method {
+      calls = append(calls, c)
+    }
+  }
+  return calls
+}
+
+// wait calls done, with the lock held, until it returns true, or Timeout
+// passes.
+func (s *Server) wait(done func() bool) error {
+  deadline := time.After(s.Timeout)
+  for {
+    s.mu.Lock()
+    ok := done()
+    changed := s.changed
+    s.mu.Unlock()
+    if ok {
+      return nil
+    }
+    select {
+    case <-changed:
+    case <-deadline:
+      return fmt.Errorf("Timed out after %s", s.Timeout)
+    }
+  }
+}
+
+// notify wakes up the waiters.  Call it with the lock held.
+func (s *Server) notify() {
+  close(s.changed)
+  s.changed = make(chan struct{})
+}
+
+// Sign adds the signature headers Slack would send with body.
+func (s *Server) Sign(req *http.Request, body []byte) {
+  timestamp := fmt.Sprintf("%d", time.Now().Unix())
+  req.Header.Set(handler.SlackTimestampHeader, timestamp)
+  req.Header.Set(handler.SlackSignatureHeader, handler.SlackSignature(s.SigningSecret, timestamp, body))
+}
+
+// Post sends a signed request to the app.
+func (s *Server) Post(path string, contentType string, body []byte) (*http.Response, []byte, error) {
+  if s.App == "" {
+    return nil, nil, fmt.Errorf("No App url to post to")
+  }
+  req, err := http.NewRequest("POST", strings.TrimRight(s.App, "/") + path, bytes.NewReader(body))
+  if err != nil {
+    return nil, nil, err
+  }
+  req.Header.Set("Content-Type", contentType)
+  s.Sign(req, body)
+  resp, err := http.DefaultClient.Do(req)
+  if err != nil {
+    return nil, nil, err
+  }
+  defer resp.Body.Close()
+  content, err := ioutil.ReadAll(resp.Body)
+  return resp, content, err
+}
+
+func (s *Server) router() http.Handler {
+  r := mux.NewRouter()
+  r.HandleFunc("/response/{id}", s.respond).Methods("POST")
+  r.HandleFunc("/api/{method}", s.api).Methods("POST")
+  return r
+}
+
+// respond records a response posted to a response_url.
+func (s *Server) respond(rw http.ResponseWriter, req *http.Request) {
+  body, err := ioutil.ReadAll(req.Body)
+  if err != nil {
+    http.Error(rw, err.Error(), http.StatusBadRequest)
+    return
+  }
+  response, err := DecodeResponse(body)
+  if err != nil {
+    http.Error(rw, fmt.Sprintf("Bad response: %s", err.Error()), http.StatusBadRequest)
+    return
+  }
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  key := s.URL + req.URL.Path
+  s.responses[key] = append(s.responses[key], response)
+  s.notify()
+  rw.WriteHeader(http.StatusOK)
+}
+
+// api records a Web API call, and answers it like Slack would.
+func (s *Server) api(rw http.ResponseWriter, req *http.Request) {
+  method := mux.Vars(req)["method"]
+  body, err := ioutil.ReadAll(req.Body)
+  if err != nil {
+    http.Error(rw, err.Error(), http.StatusBadRequest)
+    return
+  }
+  call := &Call{Method: method, Token: strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")}
+  if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+    call.Body = json.RawMessage(body)
+  } else if call.Form, err = url.ParseQuery(string(body)); err != nil {
+    http.Error(rw, err.Error(), http.StatusBadRequest)
+    return
+  }
+
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.calls = append(s.calls, call)
+  s.notify()
+
+  // openid.connect.token is authed by the app's client id, not a token.
+  if method == "openid.connect.token" {
+    if call.Form.Get("client_id") == "" || call.Form.Get("client_secret") == "" {
+      writeResult(rw, map[string]interface{}{"ok": false, "error": "invalid_client_id"})
+      return
+    }
+  } else if call.Token == "" {
+    writeResult(rw, map[string]interface{}{"ok": false, "error": "not_authed"})
+    return
+  }
+  if slackError, ok := s.failures[method]; ok {
+    writeResult(rw, map[string]interface{}{"ok": false, "error": slackError})
+    return
+  }
+  writeResult(rw, s.result(call))
+}
+
+// result is the reply to a successful call.  Called with the lock held.
+func (s *Server) result(call *Call) map[string]interface{} {
+  result := map[string]interface{}{"ok": true}
+  switch call.Method {
+  case "chat.postMessage":
+    var msg slack.Message
+    call.Decode(&msg)
+    result["channel"] = msg.Channel
+    result["ts"] = fmt.Sprintf("%d.000100", len(s.calls))
+  case "views.open", "views.update":
+    var args struct {
+      ViewId string `json:"view_id"`
+    }
+    call.Decode(&args)
+    if args.ViewId == "" {
+      args.ViewId = fmt.Sprintf("V%d", len(s.calls))
+    }
+    result["view"] = map[string]string{"id": args.ViewId, "hash": fmt.Sprintf("hash%d", len(s.calls))}
+  case "conversations.replies":
+    messages, ok := s.replies[call.Form.Get("channel") + "/" + call.Form.Get("ts")]
+    if !ok {
+      return map[string]interface{}{"ok": false, "error": "thread_not_found"}
+    }
+    // Send each message as it was given, so fields Event does not decode,
+    // such as attachments, reach the app.
+    raw := []json.RawMessage{}
+    for _, message := range messages {
+      if len(message.Raw) == 0 {
+        message.Raw, _ = json.Marshal(message)
+      }
+      raw = append(raw, message.Raw)
+    }
+    result["messages"] = raw
+  case "users.info":
+    user, ok := s.users[call.Form.Get("user")]
+    if !ok {
+      return map[string]interface{}{"ok": false, "error": "user_not_found"}
+    }
+    result["user"] = user
+  case "openid.connect.token":
+    userId, ok := s.signInCodes[call.Form.Get("code")]
+    if !ok {
+      return map[string]interface{}{"ok": false, "error": "invalid_code"}
+    }
+    delete(s.signInCodes, call.Form.Get("code"))
+    token := "xoxp-" + call.Form.Get("code")
+    s.userTokens[token] = userId
+    result["access_token"] = token
+    result["token_type"] = "Bearer"
+  case "openid.connect.userInfo":
+    userId, ok := s.userTokens[call.Token]
+    if !ok {
+      return map[string]interface{}{"ok": false, "error": "invalid_auth"}
+    }
+    result["sub"] = userId
+    result["https://slack.com/user_id"] = userId
+    result["https://slack.com/team_id"] = s.TeamId
+  }
+  return result
+}
+
+func writeResult(rw http.ResponseWriter, result map[string]interface{}) {
+  rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+  json.NewEncoder(rw).Encode(result)
+}
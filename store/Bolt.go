@@ -0,0 +1,98 @@
+package store
+
+import (
+  "os"
+  "fmt"
+  "time"
+  "path/filepath"
+  "github.com/boltdb/bolt"
+)
+
+// OpenTimeout is how long OpenBolt waits for another process to let go of
+// the file.
+const OpenTimeout = 5 * time.Second
+
+// Bolt is a Store kept in a single BoltDB file.
+type Bolt struct {
+  db *bolt.DB
+}
+
+// OpenBolt opens, or creates, the bolt file at path.  Only one process can
+// have it open at a time.
+func OpenBolt(path string) (*Bolt, error) {
+  if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+    return nil, fmt.Errorf("Could not create the store directory: %s", err.Error())
+  }
+  db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: OpenTimeout})
+  if err != nil {
+    return nil, fmt.Errorf("Could not open the store %s: %s", path, err.Error())
+  }
+  return &Bolt{db}, nil
+}
+
+func (b *Bolt) Get(bucket string, key string) ([]byte, error) {
+  var value []byte
+  err := b.db.View(func(tx *bolt.Tx) error {
+    bk := tx.Bucket([]byte(bucket))
+    if bk == nil {
+      return ErrNotFound
+    }
+    v := bk.Get([]byte(key))
+    if v == nil {
+      return ErrNotFound
+    }
+    // v is only good for the life of the transaction.
+    value = copyBytes(v)
+    return nil
+  })
+  return value, err
+}
+
+func (b *Bolt) Put(bucket string, key string, value []byte) error {
+  return b.db.Update(func(tx *bolt.Tx) error {
+    bk, err := tx.CreateBucketIfNotExists([]byte(bucket))
+    if err != nil {
+      return err
+    }
+    return bk.Put([]byte(key), value)
+  })
+}
+
+func (b *Bolt) Create(bucket string, key string, value []byte) error {
+  return b.db.Update(func(tx *bolt.Tx) error {
+    bk, err := tx.CreateBucketIfNotExists([]byte(bucket))
+    if err != nil {
+      return err
+    }
+    if bk.Get([]byte(key)) != nil {
+      return ErrExists
+    }
+    return bk.Put([]byte(key), value)
+  })
+}
+
+func (b *Bolt) Delete(bucket string, key string) error {
+  return b.db.Update(func(tx *bolt.Tx) error {
+    bk := tx.Bucket([]byte(bucket))
+    if bk == nil {
+      return nil
+    }
+    return bk.Delete([]byte(key))
+  })
+}
+
+func (b *Bolt) ForEach(bucket string, fn func(key string, value []byte) error) error {
+  return b.db.View(func(tx *bolt.Tx) error {
+    bk := tx.Bucket([]byte(bucket))
+    if bk == nil {
+      return nil
+    }
+    return bk.ForEach(func(k []byte, v []byte) error {
+      return fn(string(k), copyBytes(v))
+    })
+  })
+}
+
+func (b *Bolt) Close() error {
+  return b.db.Close()
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+  "os"
+  "errors"
+  "testing"
+  "io/ioutil"
+  "path/filepath"
+
+  . "github.com/smartystreets/goconvey/convey"
+)
+
+// testStore runs the same checks against any Store.
+func testStore(t *testing.T, name string, s Store) {
+  Convey("Given an empty " + name + " store", t, func() {
+    Convey("Get of a missing key is ErrNotFound", func() {
+      _, err := s.Get("missing", "key")
+      So(err, ShouldEqual, ErrNotFound)
+    })
+
+    Convey("Put then Get returns the value", func() {
+      So(s.Put("b", "k1", []byte("one")), ShouldBeNil)
+      value, err := s.Get("b", "k1")
+      So(err, ShouldBeNil)
+      So(string(value), ShouldEqual, "one")
+
+      Convey("Create does not replace it", func() {
+        So(s.Create("b", "k1", []byte("two")), ShouldEqual, ErrExists)
+        value, _ := s.Get("b", "k1")
+        So(string(value), ShouldEqual, "one")
+      })
+      Convey("Delete removes it", func() {
+        So(s.Delete("b", "k1"), ShouldBeNil)
+        _, err := s.Get("b", "k1")
+        So(err, ShouldEqual, ErrNotFound)
+        So(s.Delete("b", "k1"), ShouldBeNil)
+      })
+    })
+
+    Convey("ForEach walks the keys in order, and stops on an error", func() {
+      s.Put("walk", "b", []byte("2"))
+      s.Put("walk", "a", []byte("1"))
+      keys := []string{}
+      So(s.ForEach("walk", func(key string, value []byte) error {
+        keys = append(keys, key + "=" + string(value))
+        return nil
+      }), ShouldBeNil)
+      So(keys, ShouldResemble, []string{"a=1", "b=2"})
+
+      stop := errors.New("stop")
+      So(s.ForEach("walk", func(key string, value []byte) error { return stop }), ShouldEqual, stop)
+      So(s.ForEach("missing", func(key string, value []byte) error { return stop }), ShouldBeNil)
+    })
+
+    Convey("JSON values round trip", func() {
+      type link struct{ Login string }
+      So(PutJSON(s, "json", "U1", link{"steve"}), ShouldBeNil)
+      var got link
+      So(GetJSON(s, "json", "U1", &got), ShouldBeNil)
+      So(got.Login, ShouldEqual, "steve")
+    })
+
+    Convey("A Bucket hides ErrNotFound", func() {
+      b := Bucket{s, "bucket"}
+      _, ok, err := b.Get("nope")
+      So(ok, ShouldBeFalse)
+      So(err, ShouldBeNil)
+      b.Put("yes", []byte("y"))
+      value, ok, _ := b.Get("yes")
+      So(ok, ShouldBeTrue)
+      So(string(value), ShouldEqual, "y")
+    })
+  })
+}
+
+func TestMemory(t *testing.T) {
+  testStore(t, "memory", NewMemory())
+}
+
+func TestBolt(t *testing.T) {
+  dir, err := ioutil.TempDir("", "store")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  path := filepath.Join(dir, "sub", "test.db")
+
+  s, err := OpenBolt(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  testStore(t, "bolt", s)
+  s.Put("b", "kept", []byte("yes"))
+  s.Close()
+
+  Convey("Values survive a reopen", t, func() {
+    s, err := OpenBolt(path)
+    So(err, ShouldBeNil)
+    defer s.Close()
+    value, err := s.Get("b", "kept")
+    So(err, ShouldBeNil)
+    So(string(value), ShouldEqual, "yes")
+  })
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+  "sort"
+  "sync"
+)
+
+// Memory is a Store that keeps everything in maps, and forgets it on
+// restart.
+type Memory struct {
+  mu sync.RWMutex
+  buckets map[string]map[string][]byte
+}
+
+func NewMemory() *Memory {
+  return &Memory{buckets: make(map[string]map[string][]byte)}
+}
+
+func (m *Memory) Get(bucket string, key string) ([]byte, error) {
+  m.mu.RLock()
+  defer m.mu.RUnlock()
+  value, ok := m.buckets[bucket][key]
+  if !ok {
+    return nil, ErrNotFound
+  }
+  return copyBytes(value), nil
+}
+
+func (m *Memory) Put(bucket string, key string, value []byte) error {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  m.put(bucket, key, value)
+  return nil
+}
+
+func (m *Memory) Create(bucket string, key string, value []byte) error {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  if _, ok := m.buckets[bucket][key]; ok {
+    return ErrExists
+  }
+  m.put(bucket, key, value)
+  return nil
+}
+
+func (m *Memory) put(bucket string, key string, value []byte) {
+  b, ok := m.buckets[bucket]
+  if !ok {
+    b = make(map[string][]byte)
+    m.buckets[bucket] = b
+  }
+  b[key] = copyBytes(value)
+}
+
+func (m *Memory) Delete(bucket string, key string) error {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  delete(m.buckets[bucket], key)
+  return nil
+}
+
+func (m *Memory) ForEach(bucket string, fn func(key string, value []byte) error) error {
+  m.mu.RLock()
+  defer m.mu.RUnlock()
+  b := m.buckets[bucket]
+  keys := make([]string, 0, len(b))
+  for key := range b {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  for _, key := range keys {
+    if err := fn(key, copyBytes(b[key])); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (m *Memory) Close() error {
+  return nil
+}
+
+func copyBytes(b []byte) []byte {
+  if b == nil {
+    return nil
+  }
+  return append([]byte{}, b...)
+}
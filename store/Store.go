@@ -0,0 +1,126 @@
+// Package store keeps app state, such as linked GitHub accounts and channel
+// defaults, in named buckets of keys and values.  Bolt keeps them in a file
+// under APP_ROOT.  Memory is for tests, and for running without a disk.
+package store
+
+import (
+  "fmt"
+  "errors"
+  "context"
+  "path/filepath"
+  "encoding/json"
+  "github.com/confyrm/gorest/config"
+)
+
+// Config keys for the store.
+const (
+  // Driver is "bolt" or "memory".  Defaults to bolt.
+  Driver = "STORE_DRIVER"
+  // Path is the bolt file.  Defaults to APP_ROOT/devhub.db.
+  Path = "STORE_PATH"
+)
+
+// DefaultFile is the bolt file name used if STORE_PATH is not set.
+const DefaultFile = "devhub.db"
+
+// ErrNotFound is returned by Get for a missing key.
+var ErrNotFound = errors.New("Not found")
+
+// ErrExists is returned by Create for a key that is already set.
+var ErrExists = errors.New("Already exists")
+
+// Store is a set of buckets of keys and values.  Buckets are created the
+// first time they are written to.  Values are owned by the caller once
+// returned.  A Store is safe to use from many goroutines.
+type Store interface {
+  // Get returns ErrNotFound if the bucket or key does not exist.
+  Get(bucket string, key string) ([]byte, error)
+  Put(bucket string, key string, value []byte) error
+  // Create is Put, unless key is already set, when it returns ErrExists.
+  // It is atomic, so it can be used for idempotency keys.
+  Create(bucket string, key string, value []byte) error
+  // Delete does nothing if the key does not exist.
+  Delete(bucket string, key string) error
+  // ForEach calls fn for each key in the bucket, in key order.  An error
+  // from fn stops the walk, and is returned.  fn must not use the Store.
+  ForEach(bucket string, fn func(key string, value []byte) error) error
+  Close() error
+}
+
+// Open returns the store set by STORE_DRIVER.
+func Open(config *config.Config) (Store, error) {
+  app := config.App()
+  switch driver := app.Store.Driver; driver {
+  case "", "bolt":
+    path := app.Store.Path
+    if path == "" {
+      path = filepath.Join(app.Root, DefaultFile)
+    }
+    return OpenBolt(path)
+  case "memory":
+    return NewMemory(), nil
+  default:
+    return nil, fmt.Errorf("Unknown %s %q.  Use bolt or memory.", Driver, driver)
+  }
+}
+
+// GetJSON decodes the value of key into v.
+func GetJSON(s Store, bucket string, key string, v interface{}) error {
+  value, err := s.Get(bucket, key)
+  if err != nil {
+    return err
+  }
+  return json.Unmarshal(value, v)
+}
+
+// PutJSON stores v, encoded as JSON, as the value of key.
+func PutJSON(s Store, bucket string, key string, v interface{}) error {
+  value, err := json.Marshal(v)
+  if err != nil {
+    return err
+  }
+  return s.Put(bucket, key, value)
+}
+
+// Bucket is one bucket of a Store.  It is a githubclient.BlobStore.
+type Bucket struct {
+  Store Store
+  Name string
+}
+
+func (b Bucket) Get(key string) ([]byte, bool, error) {
+  value, err := b.Store.Get(b.Name, key)
+  switch err {
+  case nil:
+    return value, true, nil
+  case ErrNotFound:
+    return nil, false, nil
+  default:
+    return nil, false, err
+  }
+}
+
+func (b Bucket) Put(key string, value []byte) error {
+  return b.Store.Put(b.Name, key, value)
+}
+
+func (b Bucket) Delete(key string) error {
+  return b.Store.Delete(b.Name, key)
+}
+
+type contextKey struct{}
+
+// WithStore returns a copy of ctx that carries s.  handler.Handler does
+// this for every request, the way it passes down the config.
+func WithStore(ctx context.Context, s Store) context.Context {
+  return context.WithValue(ctx, contextKey{}, s)
+}
+
+// FromContext returns the Store carried by ctx, or nil.
+func FromContext(ctx context.Context) Store {
+  if ctx == nil {
+    return nil
+  }
+  s, _ := ctx.Value(contextKey{}).(Store)
+  return s
+}